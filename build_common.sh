@@ -93,6 +93,9 @@ build_x86_and_64() {
         go build -a -tags="${EZMQX_BUILD_MODE} ${IS_SECURED}" amlsubscriber.go
         go build -a -tags="${EZMQX_BUILD_MODE} ${IS_SECURED}" xmlsubscriber.go 
     fi
+    #build TNS server
+    cd ../ezmqx_tns/cmd/tnsserver
+    go build
 }
 
 build_armhf_native() {
@@ -114,6 +117,9 @@ build_armhf_native() {
         CGO_ENABLED=1 GOOS=linux GOARCH=arm go build -a -tags="${EZMQX_BUILD_MODE} ${IS_SECURED}" amlsubscriber.go
         CGO_ENABLED=1 GOOS=linux GOARCH=arm go build -a -tags="${EZMQX_BUILD_MODE} ${IS_SECURED}" xmlsubscriber.go 
     fi  
+    #build TNS server
+    cd ../ezmqx_tns/cmd/tnsserver
+    CGO_ENABLED=0 GOOS=linux GOARCH=arm go build
 }
 
 clean_ezmqx() {
@@ -170,6 +176,8 @@ build_ezmqx() {
     cp -r ezmqx_samples ./src/go
     # Copy ezmq-plus unit test cases
     cp -r ezmqx_unittests ./src/go
+    # Copy ezmq-plus TNS server
+    cp -r ezmqx_tns ./src/go
     
     # set flags for aml-go includes and libs 
     export CGO_CFLAGS=-I$PWD/dependencies/datamodel-aml-go/dependencies/datamodel-aml-c/include/
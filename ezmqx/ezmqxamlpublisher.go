@@ -19,74 +19,162 @@ package ezmqx
 
 import (
 	"container/list"
+	"context"
+	"errors"
 	"go/aml"
 	"go/ezmq"
+	"strconv"
+	"sync/atomic"
+	"time"
 )
 
 // Structure represents EZMQX publisher.
 type EZMQXAMLPublisher struct {
+	// Accessed atomically, kept first for 64-bit alignment.
+	sequence       uint64
 	publisher      *EZMQXPublisher
 	representation *aml.Representation
 	isSecured      bool
+	publisherId    string
+	envelope       bool
 }
 
 // Get EZMQX publisher instance.
 func GetAMLPublisher(topic string, modelInfo EZMQXAmlModelInfo, modelId string, optionalPort int) (*EZMQXAMLPublisher, EZMQXErrorCode) {
+	instance, err := getContextInstance().NewAMLPublisher(topic, modelInfo, modelId, optionalPort)
+	return instance, ErrorCodeOf(err)
+}
+
+// Get EZMQX publisher instance.
+// Topic registration request to TNS is cancelled when ctx is done.
+func GetAMLPublisherContext(ctx context.Context, topic string, modelInfo EZMQXAmlModelInfo, modelId string, optionalPort int) (*EZMQXAMLPublisher, error) {
+	return getContextInstance().NewAMLPublisherContext(ctx, topic, modelInfo, modelId, optionalPort)
+}
+
+// Create EZMQX publisher instance on this context.
+// On failure, it returns EZMQXError with cause of failure.
+func (ezmqxCtx *EZMQXContext) NewAMLPublisher(topic string, modelInfo EZMQXAmlModelInfo, modelId string, optionalPort int) (*EZMQXAMLPublisher, error) {
+	return ezmqxCtx.NewAMLPublisherContext(context.Background(), topic, modelInfo, modelId, optionalPort)
+}
+
+// Create EZMQX publisher instance on this context.
+// Topic registration request to TNS is cancelled when ctx is done.
+func (ezmqxCtx *EZMQXContext) NewAMLPublisherContext(ctx context.Context, topic string, modelInfo EZMQXAmlModelInfo, modelId string, optionalPort int) (*EZMQXAMLPublisher, error) {
+	return ezmqxCtx.NewAMLPublisherWithOptions(ctx, topic, modelInfo, modelId, optionalPort, EZMQXPublisherOptions{})
+}
+
+// Get EZMQX publisher instance with options.
+// With PendingRegistration option, it does not fail when TNS is not reachable.
+func GetAMLPublisherWithOptions(topic string, modelInfo EZMQXAmlModelInfo, modelId string, optionalPort int, options EZMQXPublisherOptions) (*EZMQXAMLPublisher, error) {
+	return getContextInstance().NewAMLPublisherWithOptions(context.Background(), topic, modelInfo, modelId, optionalPort, options)
+}
+
+// Create EZMQX publisher instance with options on this context.
+// Topic registration request to TNS is cancelled when ctx is done, ctx is not
+// used for background registration.
+func (ezmqxCtx *EZMQXContext) NewAMLPublisherWithOptions(ctx context.Context, topic string, modelInfo EZMQXAmlModelInfo, modelId string, optionalPort int, options EZMQXPublisherOptions) (*EZMQXAMLPublisher, error) {
 	var instance *EZMQXAMLPublisher
 	instance = &EZMQXAMLPublisher{}
-	instance.publisher = getPublisher()
+	instance.publisher = getPublisher(ezmqxCtx)
 	result := instance.publisher.initialize(optionalPort)
-	if result != EZMQX_OK {
+	if result != nil {
 		return nil, result
 	}
-	result = instance.registerTopic(topic, modelInfo, modelId, false)
-	if result != EZMQX_OK {
+	result = instance.registerTopic(ctx, topic, modelInfo, modelId, false, options)
+	if result != nil {
 		Logger.Error("Register topic failed, stopping ezmq publisher")
 		instance.publisher.ezmqPublisher.Stop()
 		return nil, result
 	}
 	instance.isSecured = false
-	return instance, EZMQX_OK
+	return instance, nil
+}
+
+// Check whether topic of publisher is registered to TNS.
+// It is false while registration is pending or if TNS is not used.
+func (instance *EZMQXAMLPublisher) IsRegistered() bool {
+	publisher := instance.publisher
+	if nil == publisher {
+		return false
+	}
+	return publisher.registered.Load().(bool)
 }
 
 // Publish AMLObject on the socket for subscribers.
 func (instance *EZMQXAMLPublisher) Publish(object *aml.AMLObject) EZMQXErrorCode {
+	return ErrorCodeOf(instance.PublishV2(object))
+}
+
+// Publish AMLObject on the socket for subscribers.
+// It is same as Publish, but returns EZMQXError with cause of failure.
+func (instance *EZMQXAMLPublisher) PublishV2(object *aml.AMLObject) error {
+	return instance.PublishWithHeaders(object, nil)
+}
+
+// Publish AMLObject with the given headers in message envelope.
+// Headers are sent only if publisher is created with Envelope option.
+func (instance *EZMQXAMLPublisher) PublishWithHeaders(object *aml.AMLObject, headers map[string]string) error {
 	publisher := instance.publisher
 	if nil == publisher {
 		Logger.Error("Publisher is null")
-		return EZMQX_UNKNOWN_STATE
+		return newError(EZMQX_UNKNOWN_STATE, "publish", errors.New("publisher is null"))
 	}
+	topic := publisher.topic.GetName()
 	if publisher.context.isCtxTerminated() {
 		Logger.Error("Context terminated")
 		instance.Terminate()
-		return EZMQX_TERMINATED
+		return newTopicError(EZMQX_TERMINATED, "publish", topic, nil)
 	}
 	byteData, errorCode := instance.representation.DataToByte(object)
 	if errorCode != aml.AML_OK {
 		Logger.Error("AML DataToByte failed")
-		return EZMQX_UNKNOWN_STATE
+		return newTopicError(EZMQX_UNKNOWN_STATE, "publish", topic, errors.New("AML DataToByte failed: "+strconv.Itoa(int(errorCode))))
+	}
+	if instance.envelope {
+		header := &EZMQXMessageHeader{Version: ENVELOPE_VERSION, PublisherId: instance.publisherId,
+			Sequence: atomic.AddUint64(&instance.sequence, 1), Timestamp: time.Now(),
+			DataModelId: publisher.topic.GetDataModel(), Headers: headers}
+		var err error
+		byteData, err = encodeEnvelope(header, byteData)
+		if err != nil {
+			Logger.Error("Encode envelope failed", logError(err))
+			return newTopicError(EZMQX_UNKNOWN_STATE, "publish", topic, err)
+		}
 	}
 	ezmqByteData := ezmq.EZMQByteData{byteData}
 	ezmqPublisher := publisher.ezmqPublisher
 	if nil == ezmqPublisher {
 		Logger.Error("Ezmq Publisher failed")
-		return EZMQX_UNKNOWN_STATE
+		return newTopicError(EZMQX_UNKNOWN_STATE, "publish", topic, errors.New("ezmq publisher is null"))
 	}
-	result := ezmqPublisher.PublishOnTopic(publisher.topic.GetName(), ezmqByteData)
+	result := ezmqPublisher.PublishOnTopic(topic, ezmqByteData)
 	if result != ezmq.EZMQ_OK {
 		Logger.Error("Publish failed")
-		return EZMQX_UNKNOWN_STATE
+		return newTopicError(EZMQX_UNKNOWN_STATE, "publish", topic, errors.New("ezmq publish failed: "+strconv.Itoa(int(result))))
 	}
-	return EZMQX_OK
+	publisher.context.metrics.addPublished(topic, len(byteData))
+	return nil
 }
 
 // Terminate EZMQX publisher.
 func (instance *EZMQXAMLPublisher) Terminate() EZMQXErrorCode {
+	return ErrorCodeOf(instance.TerminateV2())
+}
+
+// Terminate EZMQX publisher.
+// It is same as Terminate, but returns EZMQXError with cause of failure.
+func (instance *EZMQXAMLPublisher) TerminateV2() error {
+	return instance.TerminateContext(context.Background())
+}
+
+// Terminate EZMQX publisher.
+// Topic unregistration request to TNS is cancelled when ctx is done.
+func (instance *EZMQXAMLPublisher) TerminateContext(ctx context.Context) error {
 	publisher := instance.publisher
 	if nil == publisher {
-		return EZMQX_UNKNOWN_STATE
+		return newError(EZMQX_UNKNOWN_STATE, "terminate publisher", errors.New("publisher is null"))
 	}
-	return publisher.terminate()
+	return publisher.terminate(ctx)
 }
 
 // Check whether publisher is terminated or not.
@@ -98,6 +186,11 @@ func (instance *EZMQXAMLPublisher) IsTerminated() (bool, EZMQXErrorCode) {
 	return publisher.isTerminated(), EZMQX_OK
 }
 
+// Get id of publisher written in message header.
+func (instance *EZMQXAMLPublisher) GetPublisherId() string {
+	return instance.publisherId
+}
+
 // Get instance of Topic that used on this publisher.
 func (instance *EZMQXAMLPublisher) GetTopic() (*EZMQXTopic, EZMQXErrorCode) {
 	publisher := instance.publisher
@@ -112,45 +205,50 @@ func (instance *EZMQXAMLPublisher) IsSecured() (bool, EZMQXErrorCode) {
 	return instance.isSecured, EZMQX_OK
 }
 
-func (instance *EZMQXAMLPublisher) registerTopic(topic string, modelInfo EZMQXAmlModelInfo, modelId string, isSecured bool) EZMQXErrorCode {
-	var errorCode EZMQXErrorCode
+func (instance *EZMQXAMLPublisher) registerTopic(ctx context.Context, topic string, modelInfo EZMQXAmlModelInfo, modelId string, isSecured bool, options EZMQXPublisherOptions) error {
+	instance.publisherId = options.PublisherId
+	if 0 == len(instance.publisherId) {
+		instance.publisherId = newPublisherId()
+	}
+	instance.envelope = options.Envelope
+	var err error
 	publisher := instance.publisher
 	context := publisher.context
 	if AML_MODEL_ID == modelInfo {
-		instance.representation, errorCode = context.getAmlRep(modelId)
-		if errorCode != EZMQX_OK {
+		instance.representation, err = context.getAmlRep(modelId)
+		if err != nil {
 			Logger.Error("Get aml representation failed [AML_MODEL_ID]")
-			return errorCode
+			return err
 		}
 	} else if AML_FILE_PATH == modelInfo {
 		amlFilePath := list.New()
 		amlFilePath.PushBack(modelId)
 		idList, error := context.addAmlRep(*amlFilePath)
-		if error != EZMQX_OK {
+		if error != nil {
 			Logger.Error("Add aml representation failed")
 			return error
 		}
 		id := idList.Front().Value.(string)
 		instance.representation, error = context.getAmlRep(id)
-		if error != EZMQX_OK {
+		if error != nil {
 			Logger.Error("Get aml representation failed [AML_FILE_PATH]")
 			return error
 		}
 	} else {
 		Logger.Error("Unknown aml model info")
-		return EZMQX_UNKNOWN_STATE
+		return newTopicError(EZMQX_UNKNOWN_STATE, "register topic", topic, errors.New("unknown aml model info"))
 	}
 
 	repId, amlCode := instance.representation.GetRepresentationId()
 	if amlCode != aml.AML_OK {
 		Logger.Error("Get representation ID failed")
-		return EZMQX_UNKNOWN_STATE
+		return newTopicError(EZMQX_UNKNOWN_STATE, "register topic", topic, errors.New("get representation ID failed"))
 	}
-	hostEP, errorCode := context.getHostEp(publisher.localPort)
-	if errorCode != EZMQX_OK {
+	hostEP, err := context.getHostEp(publisher.localPort)
+	if err != nil {
 		Logger.Error("Get hostEP failed")
-		return EZMQX_UNKNOWN_STATE
+		return newTopicError(EZMQX_UNKNOWN_STATE, "register topic", topic, err)
 	}
-	ezmqxTopic := GetEZMQXTopic(topic, repId, isSecured, hostEP)
-	return publisher.registerTopic(ezmqxTopic)
+	ezmqxTopic := GetEZMQXTopicWithMetadata(topic, repId, isSecured, hostEP, options.Metadata)
+	return publisher.registerTopic(ctx, ezmqxTopic, options)
 }
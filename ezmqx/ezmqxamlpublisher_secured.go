@@ -1,25 +1,58 @@
+//go:build !unsecure
 // +build !unsecure
 
 package ezmqx
 
+import (
+	"context"
+)
+
 // Get Secured EZMQX publisher instance.
 //
 // Note:
 // (1) Key should be 40-character string encoded in the Z85 encoding format
 func GetSecuredAMLPublisher(topic string, serverPrivateKey string, modelInfo EZMQXAmlModelInfo, modelId string, optionalPort int) (*EZMQXAMLPublisher, EZMQXErrorCode) {
+	instance, err := getContextInstance().NewSecuredAMLPublisher(topic, serverPrivateKey, modelInfo, modelId, optionalPort)
+	return instance, ErrorCodeOf(err)
+}
+
+// Get Secured EZMQX publisher instance.
+// Topic registration request to TNS is cancelled when ctx is done.
+//
+// Note:
+// (1) Key should be 40-character string encoded in the Z85 encoding format
+func GetSecuredAMLPublisherContext(ctx context.Context, topic string, serverPrivateKey string, modelInfo EZMQXAmlModelInfo, modelId string, optionalPort int) (*EZMQXAMLPublisher, error) {
+	return getContextInstance().NewSecuredAMLPublisherContext(ctx, topic, serverPrivateKey, modelInfo, modelId, optionalPort)
+}
+
+// Create secured EZMQX publisher instance on this context.
+// On failure, it returns EZMQXError with cause of failure.
+//
+// Note:
+// (1) Key should be 40-character string encoded in the Z85 encoding format
+func (ezmqxCtx *EZMQXContext) NewSecuredAMLPublisher(topic string, serverPrivateKey string, modelInfo EZMQXAmlModelInfo, modelId string, optionalPort int) (*EZMQXAMLPublisher, error) {
+	return ezmqxCtx.NewSecuredAMLPublisherContext(context.Background(), topic, serverPrivateKey, modelInfo, modelId, optionalPort)
+}
+
+// Create secured EZMQX publisher instance on this context.
+// Topic registration request to TNS is cancelled when ctx is done.
+//
+// Note:
+// (1) Key should be 40-character string encoded in the Z85 encoding format
+func (ezmqxCtx *EZMQXContext) NewSecuredAMLPublisherContext(ctx context.Context, topic string, serverPrivateKey string, modelInfo EZMQXAmlModelInfo, modelId string, optionalPort int) (*EZMQXAMLPublisher, error) {
 	var instance *EZMQXAMLPublisher
 	instance = &EZMQXAMLPublisher{}
-	instance.publisher = getPublisher()
+	instance.publisher = getPublisher(ezmqxCtx)
 	result := instance.publisher.initializeSecured(optionalPort, serverPrivateKey)
-	if result != EZMQX_OK {
+	if result != nil {
 		return nil, result
 	}
-	result = instance.registerTopic(topic, modelInfo, modelId, true)
-	if result != EZMQX_OK {
+	result = instance.registerTopic(ctx, topic, modelInfo, modelId, true, EZMQXPublisherOptions{})
+	if result != nil {
 		Logger.Error("Register topic failed, stopping ezmq publisher")
 		instance.publisher.ezmqPublisher.Stop()
 		return nil, result
 	}
 	instance.isSecured = true
-	return instance, EZMQX_OK
+	return instance, nil
 }
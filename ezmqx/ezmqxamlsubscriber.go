@@ -19,69 +19,212 @@ package ezmqx
 
 import (
 	"container/list"
-	"go.uber.org/zap"
+	"context"
+	"errors"
 	"go/aml"
 	"go/ezmq"
+	"time"
 )
 
 // Callback to get all the subscribed events for a specific topic.
 type EZMQXAmlSubCB func(topic string, amlObject aml.AMLObject)
 
+// Callback to get all the subscribed events for a specific topic with message header.
+// Header is nil for legacy payload published without message envelope.
+type EZMQXAmlHeaderSubCB func(topic string, header *EZMQXMessageHeader, amlObject aml.AMLObject)
+
 // Callback to get error for the subscribed topic.
 type EZMQXAmlErrorCB func(topic string, errorCode EZMQXErrorCode)
 
+// Options of AML subscriber.
+//
+// SubCallback, or HeaderCallback to get message header with every event, and
+// ErrorCallback are required, unless Channel is set.
+// If Channel is set, messages and errors are delivered on Messages and Errors
+// channels instead of callbacks.
+// If Watch is set, TNS is queried for the topic on every watch interval, topics
+// registered later are subscribed and topics removed from TNS are unsubscribed.
+// Failure of TNS query is reported as error. Watch is not available for
+// stand-alone subscriber.
+type EZMQXAMLSubscriberOptions struct {
+	SubCallback    EZMQXAmlSubCB
+	HeaderCallback EZMQXAmlHeaderSubCB
+	ErrorCallback  EZMQXAmlErrorCB
+	Channel        *EZMQXChannelOptions
+	Watch          *EZMQXWatchOptions
+}
+
 // Structure represents EZMQX AML subscriber.
 type EZMQXAMLSubscriber struct {
-	subscriber    *EZMQXSubscriber
-	subCallback   EZMQXAmlSubCB
-	errorCallback EZMQXAmlErrorCB
-	isSecured     bool
+	subscriber     *EZMQXSubscriber
+	subCallback    EZMQXAmlSubCB
+	headerCallback EZMQXAmlHeaderSubCB
+	errorCallback  EZMQXAmlErrorCB
+	isSecured      bool
+	channel        *subChannel[AMLMessage]
 }
 
 // Get AML subscriber instance for given topic.
 // It will work, if EZMQX is configured in docker mode.
+// Topic can be a pattern with wild cards like /plant/*/temperature or /plant/#.
 func GetAMLSubscriber(topic string, isHierarchical bool, subCallback EZMQXAmlSubCB, errorCallback EZMQXAmlErrorCB) (*EZMQXAMLSubscriber, EZMQXErrorCode) {
-	instance := createAmlSubscriber(subCallback, errorCallback)
-	result := instance.subscriber.initialize(topic, isHierarchical)
-	if result != EZMQX_OK {
-		Logger.Error("initialization failed", zap.Int("Error code:", int(result)))
+	instance, err := getContextInstance().NewAMLSubscriber(topic, isHierarchical, subCallback, errorCallback)
+	return instance, ErrorCodeOf(err)
+}
+
+// Get AML subscriber instance for given topic.
+// It will work, if EZMQX is configured in docker mode.
+// Topic query request to TNS is cancelled when ctx is done.
+func GetAMLSubscriberContext(ctx context.Context, topic string, isHierarchical bool, subCallback EZMQXAmlSubCB, errorCallback EZMQXAmlErrorCB) (*EZMQXAMLSubscriber, error) {
+	return getContextInstance().NewAMLSubscriberContext(ctx, topic, isHierarchical, subCallback, errorCallback)
+}
+
+// Create AML subscriber instance for given topic on this context.
+// It will work, if context is configured in docker mode.
+func (ezmqxCtx *EZMQXContext) NewAMLSubscriber(topic string, isHierarchical bool, subCallback EZMQXAmlSubCB, errorCallback EZMQXAmlErrorCB) (*EZMQXAMLSubscriber, error) {
+	return ezmqxCtx.NewAMLSubscriberContext(context.Background(), topic, isHierarchical, subCallback, errorCallback)
+}
+
+// Create AML subscriber instance for given topic on this context.
+// It will work, if context is configured in docker mode.
+// Topic query request to TNS is cancelled when ctx is done.
+func (ezmqxCtx *EZMQXContext) NewAMLSubscriberContext(ctx context.Context, topic string, isHierarchical bool, subCallback EZMQXAmlSubCB, errorCallback EZMQXAmlErrorCB) (*EZMQXAMLSubscriber, error) {
+	instance := createAmlSubscriber(ezmqxCtx, subCallback, errorCallback)
+	result := instance.subscriber.initialize(ctx, topic, isHierarchical)
+	if result != nil {
+		Logger.Error("initialization failed", logError(result))
 		return nil, result
 	}
 	instance.isSecured = false
-	return instance, result
+	return instance, nil
+}
+
+// Get AML subscriber instance for given topic with options.
+// It will work, if EZMQX is configured in docker mode.
+func GetAMLSubscriberWithOptions(topic string, isHierarchical bool, options EZMQXAMLSubscriberOptions) (*EZMQXAMLSubscriber, error) {
+	return getContextInstance().NewAMLSubscriberWithOptionsContext(context.Background(), topic, isHierarchical, options)
+}
+
+// Get AML subscriber instance for given topic with options.
+// It will work, if EZMQX is configured in docker mode.
+// Topic query request to TNS is cancelled when ctx is done.
+func GetAMLSubscriberWithOptionsContext(ctx context.Context, topic string, isHierarchical bool, options EZMQXAMLSubscriberOptions) (*EZMQXAMLSubscriber, error) {
+	return getContextInstance().NewAMLSubscriberWithOptionsContext(ctx, topic, isHierarchical, options)
+}
+
+// Create AML subscriber instance for given topic with options on this context.
+// It will work, if context is configured in docker mode.
+func (ezmqxCtx *EZMQXContext) NewAMLSubscriberWithOptions(topic string, isHierarchical bool, options EZMQXAMLSubscriberOptions) (*EZMQXAMLSubscriber, error) {
+	return ezmqxCtx.NewAMLSubscriberWithOptionsContext(context.Background(), topic, isHierarchical, options)
+}
+
+// Create AML subscriber instance for given topic with options on this context.
+// It will work, if context is configured in docker mode.
+// Topic query request to TNS is cancelled when ctx is done.
+func (ezmqxCtx *EZMQXContext) NewAMLSubscriberWithOptionsContext(ctx context.Context, topic string, isHierarchical bool, options EZMQXAMLSubscriberOptions) (*EZMQXAMLSubscriber, error) {
+	instance, watch, result := createAmlSubscriberWithOptions(ezmqxCtx, options, false)
+	if result != nil {
+		return nil, result
+	}
+	result = instance.subscriber.initialize(ctx, topic, isHierarchical)
+	if result != nil {
+		Logger.Error("initialization failed", logError(result))
+		if nil != instance.channel {
+			instance.channel.close()
+		}
+		return nil, result
+	}
+	if nil != watch {
+		instance.subscriber.startWatch(topic, isHierarchical, *watch)
+	}
+	return instance, nil
 }
 
 // Get AML subscriber instance for given topic.
 // It will work, if EZMQX is configured in standalone mode.
 func GetAMLStandAloneSubscriber(topic EZMQXTopic, subCallback EZMQXAmlSubCB, errorCallback EZMQXAmlErrorCB) (*EZMQXAMLSubscriber, EZMQXErrorCode) {
-	instance := createAmlSubscriber(subCallback, errorCallback)
+	instance, err := getContextInstance().NewAMLStandAloneSubscriber(topic, subCallback, errorCallback)
+	return instance, ErrorCodeOf(err)
+}
+
+// Create AML subscriber instance for given topic on this context.
+// It will work, if context is configured in standalone mode.
+func (ezmqxCtx *EZMQXContext) NewAMLStandAloneSubscriber(topic EZMQXTopic, subCallback EZMQXAmlSubCB, errorCallback EZMQXAmlErrorCB) (*EZMQXAMLSubscriber, error) {
+	instance := createAmlSubscriber(ezmqxCtx, subCallback, errorCallback)
 	ezmqxTopicList := list.New()
 	ezmqxTopicList.PushBack(topic)
 	result := instance.subscriber.storeTopics(*ezmqxTopicList)
-	if result != EZMQX_OK {
-		Logger.Error("Store topic failed", zap.Int("Error code:", int(result)))
+	if result != nil {
+		Logger.Error("Store topic failed", logError(result))
 		return nil, result
 	}
 	instance.isSecured = false
-	return instance, result
+	return instance, nil
+}
+
+// Get AML subscriber instance for given topic with options.
+// It will work, if EZMQX is configured in standalone mode.
+func GetAMLStandAloneSubscriberWithOptions(topic EZMQXTopic, options EZMQXAMLSubscriberOptions) (*EZMQXAMLSubscriber, error) {
+	return getContextInstance().NewAMLStandAloneSubscriberWithOptions(topic, options)
+}
+
+// Create AML subscriber instance for given topic with options on this context.
+// It will work, if context is configured in standalone mode.
+func (ezmqxCtx *EZMQXContext) NewAMLStandAloneSubscriberWithOptions(topic EZMQXTopic, options EZMQXAMLSubscriberOptions) (*EZMQXAMLSubscriber, error) {
+	instance, _, result := createAmlSubscriberWithOptions(ezmqxCtx, options, true)
+	if result != nil {
+		return nil, result
+	}
+	ezmqxTopicList := list.New()
+	ezmqxTopicList.PushBack(topic)
+	result = instance.subscriber.storeTopics(*ezmqxTopicList)
+	if result != nil {
+		Logger.Error("Store topic failed", logError(result))
+		if nil != instance.channel {
+			instance.channel.close()
+		}
+		return nil, result
+	}
+	return instance, nil
 }
 
 // Get AML subscriber instance for given topic list.
 // It will work, if EZMQX is configured in standalone mode.
 func GetAMLStandAloneSubscriber1(topics list.List, subCallback EZMQXAmlSubCB, errorCallback EZMQXAmlErrorCB) (*EZMQXAMLSubscriber, EZMQXErrorCode) {
-	instance := createAmlSubscriber(subCallback, errorCallback)
+	instance, err := getContextInstance().NewAMLStandAloneSubscriber1(topics, subCallback, errorCallback)
+	return instance, ErrorCodeOf(err)
+}
+
+// Create AML subscriber instance for given topic list on this context.
+// It will work, if context is configured in standalone mode.
+func (ezmqxCtx *EZMQXContext) NewAMLStandAloneSubscriber1(topics list.List, subCallback EZMQXAmlSubCB, errorCallback EZMQXAmlErrorCB) (*EZMQXAMLSubscriber, error) {
+	instance := createAmlSubscriber(ezmqxCtx, subCallback, errorCallback)
 	result := instance.subscriber.storeTopics(topics)
-	if result != EZMQX_OK {
-		Logger.Error("Store topic failed", zap.Int("Error code:", int(result)))
+	if result != nil {
+		Logger.Error("Store topic failed", logError(result))
 		return nil, result
 	}
 	instance.isSecured = false
-	return instance, result
+	return instance, nil
 }
 
 // Terminate EZMQX AML subscriber.
 func (instance *EZMQXAMLSubscriber) Terminate() EZMQXErrorCode {
-	return instance.subscriber.terminate()
+	return ErrorCodeOf(instance.TerminateV2())
+}
+
+// Terminate EZMQX AML subscriber.
+// It is same as Terminate, but returns EZMQXError with cause of failure.
+// For channel based subscriber, message and error channels are closed.
+func (instance *EZMQXAMLSubscriber) TerminateV2() error {
+	channel := instance.channel
+	if nil == channel {
+		return instance.subscriber.terminate()
+	}
+	channel.stop()
+	result := instance.subscriber.terminate()
+	channel.close()
+	return result
 }
 
 // Check whether subscriber is terminated or not.
@@ -99,23 +242,148 @@ func (instance *EZMQXAMLSubscriber) IsSecured() (bool, EZMQXErrorCode) {
 	return instance.isSecured, EZMQX_OK
 }
 
-func createAmlSubscriber(subCallback EZMQXAmlSubCB, errorCallback EZMQXAmlErrorCB) *EZMQXAMLSubscriber {
+// Subscribe given topic on this subscriber without re-creating it.
+// Topic is queried from TNS, so it will work, if EZMQX is configured in docker mode.
+// Topics that are already subscribed are skipped.
+func (instance *EZMQXAMLSubscriber) Subscribe(topic string, isHierarchical bool) error {
+	return instance.SubscribeContext(context.Background(), topic, isHierarchical)
+}
+
+// Subscribe given topic on this subscriber without re-creating it.
+// Topic query request to TNS is cancelled when ctx is done.
+func (instance *EZMQXAMLSubscriber) SubscribeContext(ctx context.Context, topic string, isHierarchical bool) error {
+	if instance.isSecured {
+		return newTopicError(EZMQX_INVALID_PARAM, "subscribe", topic, errors.New("subscriber is secured"))
+	}
+	return instance.subscriber.subscribeTopics(ctx, topic, isHierarchical)
+}
+
+// Subscribe given topic on this subscriber without re-creating it.
+// It will work, if EZMQX is configured in standalone mode.
+func (instance *EZMQXAMLSubscriber) SubscribeTopic(topic EZMQXTopic) error {
+	if instance.isSecured {
+		return newTopicError(EZMQX_INVALID_PARAM, "subscribe", topic.GetName(), errors.New("subscriber is secured"))
+	}
+	var topicList list.List
+	topicList.PushBack(topic)
+	return instance.subscriber.addTopics(topicList)
+}
+
+// Unsubscribe given topic.
+// If it is the last topic of its end point, subscriber disconnects from the end point.
+func (instance *EZMQXAMLSubscriber) Unsubscribe(topic string) error {
+	return instance.subscriber.removeTopic(topic)
+}
+
+// Enable automatic reconnect of subscribed topics.
+// Subscribed topics are resolved through TNS periodically or after silence, and
+// subscriber moves to the new end point of a topic, if it is changed in TNS.
+// Every move is reported on error callback with EZMQX_RECONNECTED.
+// It will work, if EZMQX is configured in docker mode.
+func (instance *EZMQXAMLSubscriber) EnableAutoReconnect(options EZMQXReconnectOptions) error {
+	if instance.isSecured {
+		return newError(EZMQX_INVALID_PARAM, "reconnect", errors.New("subscriber is secured"))
+	}
+	return instance.subscriber.startReconnect(options)
+}
+
+// Get counters of lost, duplicated and reordered messages per topic and publisher.
+// Loss is also reported on error callback with EZMQX_MESSAGE_LOSS, EZMQX_MESSAGE_DUPLICATED
+// and EZMQX_MESSAGE_REORDERED. Legacy payloads without message header are not counted.
+func (instance *EZMQXAMLSubscriber) GetSequenceStats() []EZMQXSequenceStats {
+	return instance.subscriber.sequences.getStats()
+}
+
+// Set options of publish to callback latency measurement.
+func (instance *EZMQXAMLSubscriber) SetLatencyOptions(options EZMQXLatencyOptions) {
+	instance.subscriber.latency.setOptions(options)
+}
+
+// Get publish to callback latency per topic.
+// Legacy payloads without message header are not measured.
+func (instance *EZMQXAMLSubscriber) GetLatencyStats() map[string]EZMQXLatencyStats {
+	return instance.subscriber.latency.getStats()
+}
+
+// Clear latency of all the topics, e.g. to measure latency of every period.
+func (instance *EZMQXAMLSubscriber) ResetLatencyStats() {
+	instance.subscriber.latency.reset()
+}
+
+// Get channel of received messages.
+// It returns nil, if subscriber is not created with Channel option.
+func (instance *EZMQXAMLSubscriber) Messages() <-chan AMLMessage {
+	if nil == instance.channel {
+		return nil
+	}
+	return instance.channel.messages
+}
+
+// Get channel of receive errors like EZMQX_BROKEN_PAYLOAD and EZMQX_MESSAGE_DROPPED.
+// It returns nil, if subscriber is not created with Channel option.
+func (instance *EZMQXAMLSubscriber) Errors() <-chan error {
+	if nil == instance.channel {
+		return nil
+	}
+	return instance.channel.errors
+}
+
+// Get number of errors which are not delivered on Errors channel, since it was full.
+// It returns 0, if subscriber is not created with Channel option.
+func (instance *EZMQXAMLSubscriber) GetDroppedErrorCount() uint64 {
+	if nil == instance.channel {
+		return 0
+	}
+	return instance.channel.getDroppedErrors()
+}
+
+// Create subscriber with validated options.
+// Validated watch options are returned to start watch after subscribing.
+func createAmlSubscriberWithOptions(ezmqxCtx *EZMQXContext, options EZMQXAMLSubscriberOptions, isStandAlone bool) (*EZMQXAMLSubscriber, *EZMQXWatchOptions, error) {
+	hasSubCallback := nil != options.SubCallback || nil != options.HeaderCallback
+	watch, channelOptions, result := validateSubscriberOptions(options.Watch, options.Channel, hasSubCallback, nil != options.ErrorCallback, isStandAlone)
+	if result != nil {
+		return nil, nil, result
+	}
+	if nil == channelOptions {
+		instance := createAmlSubscriber(ezmqxCtx, options.SubCallback, options.ErrorCallback)
+		instance.headerCallback = options.HeaderCallback
+		return instance, watch, nil
+	}
+	channel := newSubChannel(*channelOptions, func(message AMLMessage) string { return message.Topic })
+	instance := createAmlSubscriber(ezmqxCtx, nil,
+		func(topic string, errorCode EZMQXErrorCode) {
+			channel.pushError(errorCode, topic)
+		})
+	instance.headerCallback = func(topic string, header *EZMQXMessageHeader, amlObject aml.AMLObject) {
+		channel.push(AMLMessage{Topic: topic, Object: &amlObject, ReceivedTime: time.Now(), Header: header})
+	}
+	instance.channel = channel
+	return instance, watch, nil
+}
+
+func createAmlSubscriber(context *EZMQXContext, subCallback EZMQXAmlSubCB, errorCallback EZMQXAmlErrorCB) *EZMQXAMLSubscriber {
 	var instance *EZMQXAMLSubscriber
 	instance = &EZMQXAMLSubscriber{}
 	instance.subCallback = subCallback
 	instance.errorCallback = errorCallback
-	instance.subscriber = getEZMQXSubscriber()
+	instance.subscriber = getEZMQXSubscriber(context)
 	subscriber := instance.subscriber
+	subscriber.errorCB = func(topic string, errorCode EZMQXErrorCode) {
+		instance.errorCallback(topic, errorCode)
+	}
 	subscriber.internalCB = func(topic string, ezmqMsg ezmq.EZMQMessage) {
-		representation := subscriber.amlRepDic[topic]
-		if 0 == len(topic) || nil == representation {
-			instance.errorCallback(topic, EZMQX_UNKNOWN_TOPIC)
+		header, amlObject, _, errorCode := subscriber.decodeMessage(topic, ezmqMsg)
+		if errorCode != EZMQX_OK {
+			instance.errorCallback(topic, errorCode)
 			return
 		}
-		ezmqByteData := ezmqMsg.(ezmq.EZMQByteData)
-		amlObject, result := representation.ByteToData(ezmqByteData.ByteData)
-		if result != aml.AML_OK {
-			instance.errorCallback(topic, EZMQX_BROKEN_PAYLOAD)
+		if errorCode = subscriber.checkSequence(topic, header); errorCode != EZMQX_OK {
+			instance.errorCallback(topic, errorCode)
+		}
+		subscriber.latency.record(topic, header, time.Now())
+		if nil != instance.headerCallback {
+			instance.headerCallback(topic, header, *amlObject)
 			return
 		}
 		instance.subCallback(topic, *amlObject)
@@ -1,9 +1,10 @@
+//go:build !unsecure
 // +build !unsecure
 
 package ezmqx
 
 import (
-	"go.uber.org/zap"
+	"errors"
 )
 
 // Get secured AML subscriber instance for given topic.
@@ -11,17 +12,26 @@ import (
 // Note:
 // (1) Key should be 40-character string encoded in the Z85 encoding format
 func GetSecuredAMLSubscriber(topic EZMQXTopic, serverPublicKey string, clientPublicKey string, clientSecretKey string, subCallback EZMQXAmlSubCB, errorCallback EZMQXAmlErrorCB) (*EZMQXAMLSubscriber, EZMQXErrorCode) {
+	instance, err := getContextInstance().NewSecuredAMLSubscriber(topic, serverPublicKey, clientPublicKey, clientSecretKey, subCallback, errorCallback)
+	return instance, ErrorCodeOf(err)
+}
+
+// Create secured AML subscriber instance for given topic on this context.
+//
+// Note:
+// (1) Key should be 40-character string encoded in the Z85 encoding format
+func (ezmqxCtx *EZMQXContext) NewSecuredAMLSubscriber(topic EZMQXTopic, serverPublicKey string, clientPublicKey string, clientSecretKey string, subCallback EZMQXAmlSubCB, errorCallback EZMQXAmlErrorCB) (*EZMQXAMLSubscriber, error) {
 	if !topic.IsSecured() {
-		return nil, EZMQX_INVALID_PARAM
+		return nil, newTopicError(EZMQX_INVALID_PARAM, "create subscriber", topic.GetName(), errors.New("topic is not secured"))
 	}
-	instance := createAmlSubscriber(subCallback, errorCallback)
+	instance := createAmlSubscriber(ezmqxCtx, subCallback, errorCallback)
 	result := instance.subscriber.storeSecuredTopics(topic, serverPublicKey, clientPublicKey, clientSecretKey)
-	if result != EZMQX_OK {
-		Logger.Error("Store topic failed", zap.Int("Error code:", int(result)))
+	if result != nil {
+		Logger.Error("Store topic failed", logError(result))
 		return nil, result
 	}
 	instance.isSecured = true
-	return instance, result
+	return instance, nil
 }
 
 // Get secured AML subscriber instance for given topic.
@@ -29,20 +39,32 @@ func GetSecuredAMLSubscriber(topic EZMQXTopic, serverPublicKey string, clientPub
 // Note:
 // (1) Key should be 40-character string encoded in the Z85 encoding format
 func GetSecuredAMLSubscriber1(topicKeyMap map[EZMQXTopic]string, clientPublicKey string, clientSecretKey string, subCallback EZMQXAmlSubCB, errorCallback EZMQXAmlErrorCB) (*EZMQXAMLSubscriber, EZMQXErrorCode) {
+	instance, err := getContextInstance().NewSecuredAMLSubscriber1(topicKeyMap, clientPublicKey, clientSecretKey, subCallback, errorCallback)
+	return instance, ErrorCodeOf(err)
+}
+
+// Create secured AML subscriber instance for given topic on this context.
+//
+// Note:
+// (1) Key should be 40-character string encoded in the Z85 encoding format
+func (ezmqxCtx *EZMQXContext) NewSecuredAMLSubscriber1(topicKeyMap map[EZMQXTopic]string, clientPublicKey string, clientSecretKey string, subCallback EZMQXAmlSubCB, errorCallback EZMQXAmlErrorCB) (*EZMQXAMLSubscriber, error) {
+	if 0 == len(topicKeyMap) {
+		return nil, newError(EZMQX_INVALID_PARAM, "create subscriber", errors.New("topic key map is empty"))
+	}
 	for topic, _ := range topicKeyMap {
 		if !topic.IsSecured() {
-			return nil, EZMQX_INVALID_PARAM
+			return nil, newTopicError(EZMQX_INVALID_PARAM, "create subscriber", topic.GetName(), errors.New("topic is not secured"))
 		}
 	}
-	instance := createAmlSubscriber(subCallback, errorCallback)
-	var result EZMQXErrorCode = EZMQX_INVALID_PARAM
+	instance := createAmlSubscriber(ezmqxCtx, subCallback, errorCallback)
+	var result error
 	for topic, serverKey := range topicKeyMap {
 		result = instance.subscriber.storeSecuredTopics(topic, serverKey, clientPublicKey, clientSecretKey)
-		if result != EZMQX_OK {
-			Logger.Error("Store topic failed", zap.Int("Error code:", int(result)))
+		if result != nil {
+			Logger.Error("Store topic failed", logError(result))
 			return nil, result
 		}
 	}
 	instance.isSecured = true
-	return instance, result
+	return instance, nil
 }
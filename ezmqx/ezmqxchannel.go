@@ -0,0 +1,207 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"errors"
+	"go/aml"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type EZMQXOverflowPolicy int
+
+// Constants represents overflow policy of channel based subscriber.
+const (
+	OVERFLOW_BLOCK       EZMQXOverflowPolicy = 0
+	OVERFLOW_DROP_OLDEST EZMQXOverflowPolicy = 1
+	OVERFLOW_DROP_NEWEST EZMQXOverflowPolicy = 2
+)
+
+const DEFAULT_CHANNEL_BUFFER_SIZE = 100
+
+// Options for channel based subscriber.
+//
+// BufferSize is capacity of message and error channels, if it is 0 then
+// DEFAULT_CHANNEL_BUFFER_SIZE is used.
+// OverflowPolicy decides what to do when message channel is full:
+// OVERFLOW_BLOCK waits for the reader, OVERFLOW_DROP_OLDEST discards the oldest
+// buffered message and OVERFLOW_DROP_NEWEST discards the received message.
+// Dropped messages are reported on error channel with EZMQX_MESSAGE_DROPPED.
+// Errors which do not fit in error channel are counted, see GetDroppedErrorCount.
+type EZMQXChannelOptions struct {
+	BufferSize     int
+	OverflowPolicy EZMQXOverflowPolicy
+}
+
+// Structure represents AML message received by channel based subscriber.
+type AMLMessage struct {
+	Topic        string
+	Object       *aml.AMLObject
+	ReceivedTime time.Time
+	// Header of message, it is nil for legacy payload.
+	Header *EZMQXMessageHeader
+}
+
+// Structure represents XML message received by channel based subscriber.
+type XMLMessage struct {
+	Topic        string
+	Data         string
+	ReceivedTime time.Time
+	// Header of message, it is nil for legacy payload.
+	Header *EZMQXMessageHeader
+}
+
+func validateChannelOptions(options *EZMQXChannelOptions) error {
+	if options.BufferSize < 0 {
+		return newError(EZMQX_INVALID_PARAM, "create subscriber", errors.New("buffer size is negative"))
+	}
+	if 0 == options.BufferSize {
+		options.BufferSize = DEFAULT_CHANNEL_BUFFER_SIZE
+	}
+	switch options.OverflowPolicy {
+	case OVERFLOW_BLOCK, OVERFLOW_DROP_OLDEST, OVERFLOW_DROP_NEWEST:
+		return nil
+	}
+	return newError(EZMQX_INVALID_PARAM, "create subscriber", errors.New("unknown overflow policy"))
+}
+
+// Channel of channel based subscriber, it is shared by AML and XML subscribers.
+// Errors never block, so that reception is never stalled by the reader of
+// error channel. Errors which do not fit in error channel are counted.
+// Mutex is held only to check closed state, pending sends are tracked by
+// senders group, so that channels are closed after every send is finished.
+type subChannel[T any] struct {
+	messages      chan T
+	errors        chan error
+	topicOf       func(message T) string
+	policy        EZMQXOverflowPolicy
+	done          chan struct{}
+	doneOnce      *sync.Once
+	mutex         *sync.Mutex
+	senders       *sync.WaitGroup
+	closed        bool
+	droppedErrors uint64
+}
+
+func newSubChannel[T any](options EZMQXChannelOptions, topicOf func(message T) string) *subChannel[T] {
+	var instance *subChannel[T]
+	instance = &subChannel[T]{}
+	instance.messages = make(chan T, options.BufferSize)
+	instance.errors = make(chan error, options.BufferSize)
+	instance.topicOf = topicOf
+	instance.policy = options.OverflowPolicy
+	instance.done = make(chan struct{})
+	instance.doneOnce = &sync.Once{}
+	instance.mutex = &sync.Mutex{}
+	instance.senders = &sync.WaitGroup{}
+	return instance
+}
+
+// Start sending on channels, it returns false if channels are closed.
+// endSend should be called when send is finished.
+func (instance *subChannel[T]) beginSend() bool {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	if instance.closed {
+		return false
+	}
+	instance.senders.Add(1)
+	return true
+}
+
+func (instance *subChannel[T]) endSend() {
+	instance.senders.Done()
+}
+
+func (instance *subChannel[T]) report(code EZMQXErrorCode, topic string) {
+	select {
+	case instance.errors <- newTopicError(code, "receive", topic, nil):
+	default:
+		atomic.AddUint64(&instance.droppedErrors, 1)
+	}
+}
+
+func (instance *subChannel[T]) push(message T) {
+	if !instance.beginSend() {
+		return
+	}
+	defer instance.endSend()
+	switch instance.policy {
+	case OVERFLOW_BLOCK:
+		select {
+		case instance.messages <- message:
+		case <-instance.done:
+		}
+	case OVERFLOW_DROP_NEWEST:
+		select {
+		case instance.messages <- message:
+		default:
+			instance.report(EZMQX_MESSAGE_DROPPED, instance.topicOf(message))
+		}
+	case OVERFLOW_DROP_OLDEST:
+		for {
+			select {
+			case instance.messages <- message:
+				return
+			default:
+			}
+			select {
+			case dropped := <-instance.messages:
+				instance.report(EZMQX_MESSAGE_DROPPED, instance.topicOf(dropped))
+			default:
+			}
+		}
+	}
+}
+
+func (instance *subChannel[T]) pushError(code EZMQXErrorCode, topic string) {
+	if !instance.beginSend() {
+		return
+	}
+	defer instance.endSend()
+	instance.report(code, topic)
+}
+
+// Get number of errors which are not reported since error channel was full.
+func (instance *subChannel[T]) getDroppedErrors() uint64 {
+	return atomic.LoadUint64(&instance.droppedErrors)
+}
+
+// Unblock pending push, it should be called before stopping ezmq subscriber.
+func (instance *subChannel[T]) stop() {
+	instance.doneOnce.Do(func() {
+		close(instance.done)
+	})
+}
+
+func (instance *subChannel[T]) close() {
+	instance.stop()
+	instance.mutex.Lock()
+	if instance.closed {
+		instance.mutex.Unlock()
+		return
+	}
+	instance.closed = true
+	instance.mutex.Unlock()
+	// Blocked sends are released by stop
+	instance.senders.Wait()
+	close(instance.messages)
+	close(instance.errors)
+}
@@ -20,6 +20,8 @@ package ezmqx
 
 import (
 	"container/list"
+	"context"
+	"errors"
 	"math/rand"
 	"sync"
 	"sync/atomic"
@@ -36,80 +38,192 @@ var configInstance *EZMQXConfig
 var configMutex = &sync.Mutex{}
 
 // Get EZMQX Config instance.
+//
+// It is the config of the default EZMQX context.
 func GetConfigInstance() *EZMQXConfig {
 	configMutex.Lock()
 	defer configMutex.Unlock()
 	if nil == configInstance {
-		configInstance = &EZMQXConfig{}
-		configInstance.context = getContextInstance()
-		configInstance.status = CREATED
-		rand.Seed(time.Now().UnixNano())
-		InitLogger()
-		factory := GetRestFactory()
+		configInstance = getContextInstance().GetConfig()
+		factory := configInstance.context.GetRestFactory()
 		factory.SetFactory(RestClientFactory{})
 	}
 	return configInstance
 }
 
+func newConfig(context *EZMQXContext) *EZMQXConfig {
+	var instance *EZMQXConfig
+	instance = &EZMQXConfig{}
+	instance.context = context
+	instance.status = CREATED
+	rand.Seed(time.Now().UnixNano())
+	return instance
+}
+
+// Get EZMQX context of this config.
+func (configInstance *EZMQXConfig) GetContext() *EZMQXContext {
+	return configInstance.context
+}
+
+// Set pharos node URL and host name file used by docker mode.
+// It should be called before starting docker mode.
+func (configInstance *EZMQXConfig) SetDockerModeOptions(options EZMQXDockerModeOptions) error {
+	if atomic.LoadUint32(&configInstance.status) != CREATED {
+		Logger.Error("Set docker mode options failed: Invalid state")
+		return newError(EZMQX_UNKNOWN_STATE, "set docker mode options", errInvalidState)
+	}
+	configInstance.context.setDockerModeOptions(options)
+	return nil
+}
+
+// Set callback to get keep alive status of the published topics.
+//
+// If keep alive request fails, all the published topics are registered
+// to TNS again and the result is reported through the callback.
+func (configInstance *EZMQXConfig) SetKeepAliveStatusCallback(statusCB EZMQXKeepAliveStatusCB) {
+	configInstance.context.setKeepAliveStatusCB(statusCB)
+}
+
+// Set retry policy of TNS register, unregister and query requests.
+func (configInstance *EZMQXConfig) SetRetryPolicy(policy EZMQXRetryPolicy) error {
+	return configInstance.context.setRetryPolicy(policy)
+}
+
+// Set options of discovery cache, which is shared by topic discovery and
+// subscribers. Cache is disabled if TTL is 0, and it is disabled by default.
+func (configInstance *EZMQXConfig) SetDiscoveryCacheOptions(options EZMQXDiscoveryCacheOptions) error {
+	return configInstance.context.setDiscoveryCacheOptions(options)
+}
+
+// Set options for multiple TNS servers, such as failover order and cool down
+// of failed server. In docker mode, every connected TNS node is used.
+func (configInstance *EZMQXConfig) SetTNSOptions(options EZMQXTNSOptions) error {
+	return configInstance.context.setTNSOptions(options)
+}
+
+// Set security options of REST requests to TNS and pharos, such as TLS
+// with custom CA, client certificate and bearer token or basic authentication.
+// It fails with EZMQX_INVALID_PARAM if certificate files can not be loaded.
+func (configInstance *EZMQXConfig) SetRestSecurity(security EZMQXRestSecurity) error {
+	return configInstance.context.restFactory.setSecurity(security)
+}
+
+// Set options of HTTP transport, which is shared by REST requests of the context
+// so that connections are reused.
+func (configInstance *EZMQXConfig) SetTransportOptions(options EZMQXTransportOptions) error {
+	return configInstance.context.restFactory.setTransportOptions(options)
+}
+
 // Start/Configure EZMQX in docker mode.
 // It works with Pharos system. In DockerMode, stack automatically use Tns service.
 func (configInstance *EZMQXConfig) StartDockerMode(tnsConfPath string) EZMQXErrorCode {
+	return ErrorCodeOf(configInstance.StartDockerModeV2(tnsConfPath))
+}
+
+// Start/Configure EZMQX in docker mode.
+// It is same as StartDockerMode, but returns EZMQXError with cause of failure.
+func (configInstance *EZMQXConfig) StartDockerModeV2(tnsConfPath string) error {
+	return configInstance.StartDockerModeContext(context.Background(), tnsConfPath)
+}
+
+// Start/Configure EZMQX in docker mode.
+// REST requests to pharos node and anchor are cancelled when ctx is done,
+// so total time of starting docker mode can be bounded with a deadline.
+func (configInstance *EZMQXConfig) StartDockerModeContext(ctx context.Context, tnsConfPath string) error {
 	if false == atomic.CompareAndSwapUint32(&configInstance.status, CREATED, INITIALIZING) {
 		Logger.Error("Initialize docker mode failed: Invalid state")
-		return EZMQX_UNKNOWN_STATE
+		return newError(EZMQX_UNKNOWN_STATE, "start docker mode", errInvalidState)
 	}
-	result := configInstance.context.initializeDockerMode(tnsConfPath)
-	if result != EZMQX_OK {
+	result := configInstance.context.initializeDockerMode(ctx, tnsConfPath)
+	if result != nil {
 		Logger.Error("Initialize docker mode failed")
 		atomic.StoreUint32(&configInstance.status, CREATED)
 		return result
 	}
 	atomic.StoreUint32(&configInstance.status, INITIALIZED)
 	Logger.Debug("Started docker mode")
-	return EZMQX_OK
+	return nil
 }
 
 // Start/Configure EZMQX in stand-alone mode.
 // It works without pharos system.
 // Note: TNS address should be complete Rest address of TNS.
+// Multiple TNS addresses can be given separated by TNS_ADDR_SEPARATOR.
 func (configInstance *EZMQXConfig) StartStandAloneMode(hostAddr string, useTns bool, tnsAddr string) EZMQXErrorCode {
+	return ErrorCodeOf(configInstance.StartStandAloneModeV2(hostAddr, useTns, tnsAddr))
+}
+
+// Start/Configure EZMQX in stand-alone mode.
+// It is same as StartStandAloneMode, but returns EZMQXError with cause of failure.
+func (configInstance *EZMQXConfig) StartStandAloneModeV2(hostAddr string, useTns bool, tnsAddr string) error {
 	if false == atomic.CompareAndSwapUint32(&configInstance.status, CREATED, INITIALIZING) {
 		Logger.Error("Initialize standalone mode failed: Invalid state")
-		return EZMQX_UNKNOWN_STATE
+		return newError(EZMQX_UNKNOWN_STATE, "start standalone mode", errInvalidState)
 	}
-	result := configInstance.context.initializeStandAloneMode(hostAddr, useTns, tnsAddr)
-	if result != EZMQX_OK {
+	return configInstance.startStandAloneMode(hostAddr, useTns, parseTnsAddrs(tnsAddr))
+}
+
+// Start/Configure EZMQX in stand-alone mode with multiple TNS servers.
+// Requests fail over to the next TNS server as set by SetTNSOptions.
+func (configInstance *EZMQXConfig) StartStandAloneModeWithTNS(hostAddr string, tnsAddrs []string) error {
+	if false == atomic.CompareAndSwapUint32(&configInstance.status, CREATED, INITIALIZING) {
+		Logger.Error("Initialize standalone mode failed: Invalid state")
+		return newError(EZMQX_UNKNOWN_STATE, "start standalone mode", errInvalidState)
+	}
+	if 0 == len(tnsAddrs) {
+		atomic.StoreUint32(&configInstance.status, CREATED)
+		return newError(EZMQX_INVALID_PARAM, "start standalone mode", errors.New("no TNS address"))
+	}
+	return configInstance.startStandAloneMode(hostAddr, true, tnsAddrs)
+}
+
+func (configInstance *EZMQXConfig) startStandAloneMode(hostAddr string, useTns bool, tnsAddrs []string) error {
+	result := configInstance.context.initializeStandAloneMode(hostAddr, useTns, tnsAddrs)
+	if result != nil {
 		Logger.Error("Initialize standalone mode failed")
 		atomic.StoreUint32(&configInstance.status, CREATED)
 		return result
 	}
 	atomic.StoreUint32(&configInstance.status, INITIALIZED)
 	Logger.Debug("Started standalone mode")
-	return EZMQX_OK
+	return nil
 }
 
 // Add aml model file for publish or subscribe AML data.
 func (configInstance *EZMQXConfig) AddAmlModel(amlFilePath list.List) (*list.List, EZMQXErrorCode) {
+	idList, err := configInstance.AddAmlModelV2(amlFilePath)
+	return idList, ErrorCodeOf(err)
+}
+
+// Add aml model file for publish or subscribe AML data.
+// It is same as AddAmlModel, but returns EZMQXError with cause of failure.
+func (configInstance *EZMQXConfig) AddAmlModelV2(amlFilePath list.List) (*list.List, error) {
 	if atomic.LoadUint32(&configInstance.status) != INITIALIZED {
 		Logger.Error("Not initialized")
-		return nil, EZMQX_NOT_INITIALIZED
+		return nil, newError(EZMQX_NOT_INITIALIZED, "add AML model", nil)
 	}
 	return configInstance.context.addAmlRep(amlFilePath)
 }
 
 // Reset/Terminate EZMQX stack.
 func (configInstance *EZMQXConfig) Reset() EZMQXErrorCode {
+	return ErrorCodeOf(configInstance.ResetV2())
+}
+
+// Reset/Terminate EZMQX stack.
+// It is same as Reset, but returns EZMQXError with cause of failure.
+func (configInstance *EZMQXConfig) ResetV2() error {
 	if false == atomic.CompareAndSwapUint32(&configInstance.status, INITIALIZED, TERMINATING) {
 		Logger.Error("Reset failed: invalid state")
-		return EZMQX_UNKNOWN_STATE
+		return newError(EZMQX_UNKNOWN_STATE, "reset", errInvalidState)
 	}
 	result := configInstance.context.terminate()
 	if result != EZMQX_OK {
 		Logger.Error("context terminate failed")
 		atomic.StoreUint32(&configInstance.status, INITIALIZED)
-		return result
+		return newError(result, "reset", nil)
 	}
 	atomic.StoreUint32(&configInstance.status, CREATED)
 	Logger.Debug("EZMQX reset done")
-	return EZMQX_OK
+	return nil
 }
@@ -18,13 +18,13 @@
 package ezmqx
 
 import (
-	"go.uber.org/zap"
 	"go/aml"
 	"go/ezmq"
 
 	"container/list"
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io/ioutil"
 	"strconv"
 	"strings"
@@ -32,6 +32,10 @@ import (
 	"sync/atomic"
 )
 
+// Structure represents an EZMQX context.
+//
+// Each context owns its own port pool, AML representation dictionary,
+// topic handler and REST factory, so several contexts can be used in one process.
 type EZMQXContext struct {
 	initialized         atomic.Value
 	terminated          atomic.Value
@@ -39,41 +43,234 @@ type EZMQXContext struct {
 	hostName            string
 	hostAddr            string
 	anchorAddr          string
-	tnsAddr             string
+	tnsServers          *tnsServers
+	tnsOptions          EZMQXTNSOptions
 	tnsImageName        string
+	nodeURL             string
+	hostNameFilePath    string
 	reverseProxyEnabled atomic.Value
 	tnsEnabled          bool
 	numOfPort           int
 	usedIdx             int
 	amlRepDic           map[string]*aml.Representation
 	usedPorts           map[int]bool
+	publishedTopics     map[string]bool
 	ports               map[int]int
+	config              *EZMQXConfig
+	restFactory         *RestFactory
+	topicHandler        *EZMQXTopicHandler
+	keepAliveStatusCB   EZMQXKeepAliveStatusCB
+	retryPolicy         EZMQXRetryPolicy
+	discoveryCache      *discoveryCache
+	metrics             *metrics
 	mutex               *sync.Mutex
 }
 
+// Options for creating an EZMQX context.
+type EZMQXContextOptions struct {
+	// Factory used for REST requests of this context.
+	// If nil, RestClientFactory is used.
+	RestClientFactory RestClientFactoryInterface
+	// Pharos node and host name file used in docker mode.
+	DockerMode EZMQXDockerModeOptions
+	// Retry policy of TNS requests. If it is invalid, requests are not retried.
+	RetryPolicy EZMQXRetryPolicy
+	// Options for multiple TNS servers. If it is invalid, default options are used.
+	TNS EZMQXTNSOptions
+	// Security options of REST requests. If it is invalid, they are not used.
+	RestSecurity EZMQXRestSecurity
+	// Options of HTTP transport shared by REST requests.
+	// If it is invalid, default options are used.
+	Transport EZMQXTransportOptions
+	// Discovery cache options. If it is invalid, cache is disabled.
+	DiscoveryCache EZMQXDiscoveryCacheOptions
+}
+
+// Options for docker mode.
+type EZMQXDockerModeOptions struct {
+	// URL of pharos node. If empty, NODE is used.
+	NodeURL string
+	// File to read container host name from. If empty, HOST_NAME_FILE_PATH is used.
+	HostNameFilePath string
+}
+
 var ctxInstance *EZMQXContext
+var ctxMutex = &sync.Mutex{}
+
+// ezmq-go keeps a single process wide context, so it is initialized by the
+// first started EZMQX context and terminated by the last one.
+var ezmqUsers int
+var ezmqMutex = &sync.Mutex{}
+
+// Create a new EZMQX context.
+//
+// Context should be started in docker or standalone mode using GetConfig()
+// before creating publishers and subscribers from it.
+func NewEZMQXContext(options EZMQXContextOptions) *EZMQXContext {
+	var instance *EZMQXContext
+	instance = &EZMQXContext{}
+	instance.initialized.Store(false)
+	instance.terminated.Store(false)
+	instance.reverseProxyEnabled.Store(false)
+	instance.standAlone = false
+	instance.amlRepDic = make(map[string]*aml.Representation)
+	instance.usedPorts = make(map[int]bool)
+	instance.publishedTopics = make(map[string]bool)
+	instance.ports = make(map[int]int)
+	instance.mutex = &sync.Mutex{}
+	factory := options.RestClientFactory
+	if nil == factory {
+		factory = RestClientFactory{}
+	}
+	instance.metrics = newMetrics()
+	instance.restFactory = newRestFactory(factory, instance.metrics)
+	instance.setDockerModeOptions(options.DockerMode)
+	instance.config = newConfig(instance)
+	err := instance.setRetryPolicy(options.RetryPolicy)
+	if err != nil {
+		Logger.Error("Invalid retry policy, TNS requests are not retried")
+		instance.setRetryPolicy(EZMQXRetryPolicy{})
+	}
+	err = instance.setDiscoveryCacheOptions(options.DiscoveryCache)
+	if err != nil {
+		Logger.Error("Invalid discovery cache options, cache is disabled")
+	}
+	err = instance.setTNSOptions(options.TNS)
+	if err != nil {
+		Logger.Error("Invalid TNS options, default options are used")
+		instance.setTNSOptions(EZMQXTNSOptions{})
+	}
+	err = instance.restFactory.setSecurity(options.RestSecurity)
+	if err != nil {
+		Logger.Error("Invalid REST security options, they are not used")
+	}
+	err = instance.restFactory.setTransportOptions(options.Transport)
+	if err != nil {
+		Logger.Error("Invalid transport options, default options are used")
+	}
+	return instance
+}
+
+func (cxtInstance *EZMQXContext) setDockerModeOptions(options EZMQXDockerModeOptions) {
+	cxtInstance.nodeURL = strings.TrimSuffix(options.NodeURL, SLASH)
+	if 0 == len(cxtInstance.nodeURL) {
+		cxtInstance.nodeURL = NODE
+	}
+	cxtInstance.hostNameFilePath = options.HostNameFilePath
+	if 0 == len(cxtInstance.hostNameFilePath) {
+		cxtInstance.hostNameFilePath = HOST_NAME_FILE_PATH
+	}
+}
+
+func (cxtInstance *EZMQXContext) setKeepAliveStatusCB(statusCB EZMQXKeepAliveStatusCB) {
+	cxtInstance.mutex.Lock()
+	defer cxtInstance.mutex.Unlock()
+	cxtInstance.keepAliveStatusCB = statusCB
+}
+
+func (cxtInstance *EZMQXContext) getKeepAliveStatusCB() EZMQXKeepAliveStatusCB {
+	cxtInstance.mutex.Lock()
+	defer cxtInstance.mutex.Unlock()
+	return cxtInstance.keepAliveStatusCB
+}
+
+func (cxtInstance *EZMQXContext) setRetryPolicy(policy EZMQXRetryPolicy) error {
+	err := validateRetryPolicy(&policy)
+	if err != nil {
+		return err
+	}
+	cxtInstance.mutex.Lock()
+	defer cxtInstance.mutex.Unlock()
+	cxtInstance.retryPolicy = policy
+	return nil
+}
+
+func (cxtInstance *EZMQXContext) getRetryPolicy() EZMQXRetryPolicy {
+	cxtInstance.mutex.Lock()
+	defer cxtInstance.mutex.Unlock()
+	return cxtInstance.retryPolicy
+}
+
+// Add topic published on this context, topic can be published only once.
+func (cxtInstance *EZMQXContext) addPublishedTopic(topic string) error {
+	cxtInstance.mutex.Lock()
+	defer cxtInstance.mutex.Unlock()
+	if cxtInstance.publishedTopics[topic] {
+		return newTopicError(EZMQX_DUPLICATED_TOPIC, "register topic", topic, errors.New("topic is already published"))
+	}
+	cxtInstance.publishedTopics[topic] = true
+	return nil
+}
+
+func (cxtInstance *EZMQXContext) removePublishedTopic(topic string) {
+	cxtInstance.mutex.Lock()
+	defer cxtInstance.mutex.Unlock()
+	delete(cxtInstance.publishedTopics, topic)
+}
 
 func getContextInstance() *EZMQXContext {
+	ctxMutex.Lock()
+	defer ctxMutex.Unlock()
 	if nil == ctxInstance {
-		ctxInstance = &EZMQXContext{}
-		ctxInstance.initialized.Store(false)
-		ctxInstance.reverseProxyEnabled.Store(false)
-		ctxInstance.standAlone = false
-		ctxInstance.amlRepDic = make(map[string]*aml.Representation)
-		ctxInstance.usedPorts = make(map[int]bool)
-		ctxInstance.ports = make(map[int]int)
-		ctxInstance.mutex = &sync.Mutex{}
+		ctxInstance = NewEZMQXContext(EZMQXContextOptions{})
 	}
 	return ctxInstance
 }
 
-func (cxtInstance *EZMQXContext) assignDynamicPort() (int, EZMQXErrorCode) {
-	ctxInstance.mutex.Lock()
-	defer ctxInstance.mutex.Unlock()
+// Get EZMQX config of this context.
+func (cxtInstance *EZMQXContext) GetConfig() *EZMQXConfig {
+	return cxtInstance.config
+}
+
+// Get REST factory used by this context.
+func (cxtInstance *EZMQXContext) GetRestFactory() *RestFactory {
+	return cxtInstance.restFactory
+}
+
+func (cxtInstance *EZMQXContext) getTopicHandler() *EZMQXTopicHandler {
+	cxtInstance.mutex.Lock()
+	defer cxtInstance.mutex.Unlock()
+	if nil == cxtInstance.topicHandler {
+		cxtInstance.topicHandler = newTopicHandler(cxtInstance)
+	}
+	return cxtInstance.topicHandler
+}
+
+func initializeEZMQ() error {
+	ezmqMutex.Lock()
+	defer ezmqMutex.Unlock()
+	if 0 == ezmqUsers {
+		if ezmq.GetInstance().Initialize() != ezmq.EZMQ_OK {
+			return newError(EZMQX_UNKNOWN_STATE, "initialize ezmq", nil)
+		}
+	}
+	ezmqUsers++
+	return nil
+}
+
+func terminateEZMQ() {
+	ezmqMutex.Lock()
+	defer ezmqMutex.Unlock()
+	if 0 == ezmqUsers {
+		return
+	}
+	ezmqUsers--
+	if 0 == ezmqUsers {
+		Logger.Debug("Try EZMQ API terminate")
+		if ezmq.EZMQ_OK != ezmq.GetInstance().Terminate() {
+			Logger.Debug("EZMQ API terminate failed")
+		}
+		Logger.Debug("EZMQ API terminated")
+	}
+}
+
+func (cxtInstance *EZMQXContext) assignDynamicPort() (int, error) {
+	cxtInstance.mutex.Lock()
+	defer cxtInstance.mutex.Unlock()
 	port := 0
 	for {
 		if cxtInstance.numOfPort >= LOCAL_PORT_MAX {
-			return -1, EZMQX_MAXIMUM_PORT_EXCEED
+			return -1, newError(EZMQX_MAXIMUM_PORT_EXCEED, "assign dynamic port", nil)
 		}
 		key := LOCAL_PORT_START + cxtInstance.usedIdx
 		if true == cxtInstance.usedPorts[key] {
@@ -88,13 +285,13 @@ func (cxtInstance *EZMQXContext) assignDynamicPort() (int, EZMQXErrorCode) {
 			break
 		}
 	}
-	Logger.Debug("Assigned dynamic Port", zap.Int("Port: ", port))
-	return port, EZMQX_OK
+	Logger.Debug("Assigned dynamic Port", logField("port", port))
+	return port, nil
 }
 
 func (contextInstance *EZMQXContext) releaseDynamicPort(port int) EZMQXErrorCode {
-	ctxInstance.mutex.Lock()
-	defer ctxInstance.mutex.Unlock()
+	contextInstance.mutex.Lock()
+	defer contextInstance.mutex.Unlock()
 	if false == contextInstance.usedPorts[port] {
 		return EZMQX_RELEASE_WRONG_PORT
 	}
@@ -104,52 +301,54 @@ func (contextInstance *EZMQXContext) releaseDynamicPort(port int) EZMQXErrorCode
 }
 
 func (contextInstance *EZMQXContext) setHostInfo(name string, address string) {
-	ctxInstance.hostName = name
+	contextInstance.hostName = name
 	contextInstance.hostAddr = address
 }
 
-func (cxtInstance *EZMQXContext) setTnsInfo(tnsAddr string) {
+func (cxtInstance *EZMQXContext) setTnsInfo(tnsAddrs []string) {
 	cxtInstance.tnsEnabled = true
-	cxtInstance.tnsAddr = tnsAddr
+	cxtInstance.mutex.Lock()
+	defer cxtInstance.mutex.Unlock()
+	cxtInstance.tnsServers = newTnsServers(tnsAddrs, cxtInstance.tnsOptions)
 }
 
-func (contextInstance *EZMQXContext) readImageName(tnsConfPath string) EZMQXErrorCode {
-	Logger.Debug("[readImageName] ", zap.String("File path: ", tnsConfPath))
+func (contextInstance *EZMQXContext) readImageName(tnsConfPath string) error {
+	Logger.Debug("[readImageName] ", logField("file_path", tnsConfPath))
 	fileData, error := ioutil.ReadFile(tnsConfPath)
 	if error != nil {
 		Logger.Error("[readImageName] Unable to read from file")
-		return EZMQX_UNKNOWN_STATE
+		return newError(EZMQX_UNKNOWN_STATE, "read image name", error)
 	}
 	var data interface{}
 	error = json.Unmarshal(fileData, &data)
 	if error != nil {
 		Logger.Error("[readImageName] Unable to unmarshal json")
-		return EZMQX_UNKNOWN_STATE
+		return newError(EZMQX_UNKNOWN_STATE, "read image name", error)
 	}
 	stringMap := data.(map[string]interface{})
 	contextInstance.tnsImageName = stringMap[CONFIG_ANCHOR_IMAGE_NAME].(string)
-	Logger.Debug("[readImageName] ", zap.String("imageName: ", contextInstance.tnsImageName))
-	return EZMQX_OK
+	Logger.Debug("[readImageName] ", logField("image_name", contextInstance.tnsImageName))
+	return nil
 }
 
-func (contextInstance *EZMQXContext) parseConfigData(response RestResponse) EZMQXErrorCode {
+func (contextInstance *EZMQXContext) parseConfigData(response RestResponse) error {
 	statusCode := response.GetStatusCode()
-	Logger.Debug("[Config] ", zap.Int(" Status code: ", statusCode))
+	Logger.Debug("[Config] ", logField(LOG_FIELD_STATUS, statusCode))
 	if statusCode != HTTP_OK {
-		return EZMQX_REST_ERROR
+		return newError(EZMQX_REST_ERROR, "parse config", &HTTPStatusError{statusCode})
 	}
 	data := response.GetResponse()
-	Logger.Debug("[Config] ", zap.String("Response: ", string(data)))
+	Logger.Debug("[Config] ", logField("response", string(data)))
 	configData := make(map[string][]interface{})
 	err := json.Unmarshal([]byte(data), &configData)
 	if err != nil {
 		Logger.Error("[Config] Json unmarshal failed")
-		return EZMQX_REST_ERROR
+		return newError(EZMQX_REST_ERROR, "parse config", err)
 	}
 	config, exists := configData[CONF_PROPS]
 	if !exists {
 		Logger.Error("[Config] No properties key in json response")
-		return EZMQX_REST_ERROR
+		return newError(EZMQX_REST_ERROR, "parse config", errors.New("no properties key in json response"))
 	}
 	anchorKeyExists := false
 	nodeKeyExists := false
@@ -157,29 +356,29 @@ func (contextInstance *EZMQXContext) parseConfigData(response RestResponse) EZMQ
 		stringMap := item.(map[string]interface{})
 		anchorAddress, exists := stringMap[CONF_ANCHOR_ADDR]
 		if exists {
-			Logger.Debug("[Config] ", zap.String("Anchor address: ", anchorAddress.(string)))
+			Logger.Debug("[Config] ", logField("anchor_address", anchorAddress.(string)))
 			contextInstance.anchorAddr = anchorAddress.(string)
 			anchorKeyExists = true
 		}
 		nodeAddress, exist := stringMap[CONF_NODE_ADDR]
 		if exist {
 			contextInstance.hostAddr = nodeAddress.(string)
-			Logger.Debug("[Config] ", zap.String("Node address: ", nodeAddress.(string)))
+			Logger.Debug("[Config] ", logField("node_address", nodeAddress.(string)))
 			nodeKeyExists = true
 		}
 	}
 	if !anchorKeyExists || !nodeKeyExists {
 		Logger.Error("[Config] Anchor address/ Node address key not exists")
-		return EZMQX_REST_ERROR
+		return newError(EZMQX_REST_ERROR, "parse config", errors.New("anchor address/node address key not exists"))
 	}
-	return EZMQX_OK
+	return nil
 }
 
-func (contextInstance *EZMQXContext) parseProperties(config map[string]interface{}) EZMQXErrorCode {
+func (contextInstance *EZMQXContext) parseProperties(config map[string]interface{}) error {
 	properties, exist := config[NODES_PROPS].([]interface{})
 	if !exist {
 		Logger.Error("[TNS info] Properties key not exist")
-		return EZMQX_REST_ERROR
+		return newError(EZMQX_REST_ERROR, "parse properties", errors.New("properties key not exist"))
 	}
 	var proxyKeyExist = false
 	for _, property := range properties {
@@ -193,93 +392,103 @@ func (contextInstance *EZMQXContext) parseProperties(config map[string]interface
 		}
 	}
 	if !proxyKeyExist {
-		return EZMQX_REST_ERROR
+		return newError(EZMQX_REST_ERROR, "parse properties", errors.New("reverse proxy key not exist"))
 	}
-	return EZMQX_OK
+	return nil
 }
 
-func (contextInstance *EZMQXContext) parseTnsInfoResponse(response RestResponse) EZMQXErrorCode {
+func (contextInstance *EZMQXContext) parseTnsInfoResponse(response RestResponse) error {
 	statusCode := response.GetStatusCode()
-	Logger.Debug("[TNS info] ", zap.Int(" Status code: ", statusCode))
+	Logger.Debug("[TNS info] ", logField(LOG_FIELD_STATUS, statusCode))
 	if statusCode != HTTP_OK {
-		return EZMQX_REST_ERROR
+		return newError(EZMQX_REST_ERROR, "parse TNS info", &HTTPStatusError{statusCode})
 	}
 	data := response.GetResponse()
-	Logger.Debug("[TNS info] ", zap.String("Response: ", string(data)))
+	Logger.Debug("[TNS info] ", logField("response", string(data)))
 
 	tnsInfoMap := make(map[string][]interface{})
 	err := json.Unmarshal([]byte(data), &tnsInfoMap)
 	if err != nil {
 		Logger.Error("[TNS info] Unmarshal error")
-		return EZMQX_REST_ERROR
+		return newError(EZMQX_REST_ERROR, "parse TNS info", err)
 	}
 	nodes, exists := tnsInfoMap[NODES]
 	if !exists {
 		Logger.Error("[TNS info] Node key not exist")
-		return EZMQX_REST_ERROR
+		return newError(EZMQX_REST_ERROR, "parse TNS info", errors.New("node key not exist"))
 	}
+	tnsAddrs := make([]string, 0)
 	for _, item := range nodes {
 		stringMap := item.(map[string]interface{})
 
 		connected, exists := stringMap[NODES_STATUS].(string)
 		if !exists {
 			Logger.Error("[TNS info] Status key not exist")
-			return EZMQX_REST_ERROR
+			return newError(EZMQX_REST_ERROR, "parse TNS info", errors.New("status key not exist"))
 		}
 		if strings.Compare("connected", connected) != 0 {
-			fmt.Println("[TNS info] Not connected")
+			Logger.Debug("[TNS info] Not connected", logField("ip", stringMap[NODES_IP]))
 			continue
 		}
 
-		contextInstance.tnsAddr, exists = stringMap[NODES_IP].(string)
+		tnsAddr, exists := stringMap[NODES_IP].(string)
 		if !exists {
 			Logger.Error("[TNS info] IP key not exist")
-			return EZMQX_REST_ERROR
+			return newError(EZMQX_REST_ERROR, "parse TNS info", errors.New("IP key not exist"))
 		}
 
 		config, exists := stringMap[NODES_CONF].(map[string]interface{})
 		if !exists {
 			Logger.Error("[TNS info] config key not exist")
-			return EZMQX_REST_ERROR
+			return newError(EZMQX_REST_ERROR, "parse TNS info", errors.New("config key not exist"))
 		}
 
-		if contextInstance.parseProperties(config) != EZMQX_OK {
+		if err := contextInstance.parseProperties(config); err != nil {
 			Logger.Error("[TNS info] Parse properties error")
-			return EZMQX_REST_ERROR
+			return newError(EZMQX_REST_ERROR, "parse TNS info", err)
 		}
-	}
 
-	if contextInstance.isReverseProxyEnabled() {
-		contextInstance.tnsAddr = HTTP_PREFIX + contextInstance.tnsAddr + COLON + REVERSE_PROXY_KNOWN_PORT + REVERSE_PROXY_PREFIX
-	} else {
-		contextInstance.tnsAddr = HTTP_PREFIX + contextInstance.tnsAddr + COLON + TNS_KNOWN_PORT
+		if contextInstance.isReverseProxyEnabled() {
+			tnsAddr = contextInstance.restFactory.getSchemePrefix() + tnsAddr + COLON + REVERSE_PROXY_KNOWN_PORT + REVERSE_PROXY_PREFIX
+		} else {
+			tnsAddr = contextInstance.restFactory.getSchemePrefix() + tnsAddr + COLON + TNS_KNOWN_PORT
+		}
+		Logger.Debug("[TNS info] ", logField(LOG_FIELD_URL, tnsAddr))
+		tnsAddrs = append(tnsAddrs, tnsAddr)
 	}
-	Logger.Debug("[TNS info] ", zap.String("TNS address: ", contextInstance.tnsAddr))
-	return EZMQX_OK
+	if 0 == len(tnsAddrs) {
+		Logger.Error("[TNS info] No connected TNS node")
+		return newError(EZMQX_TNS_NOT_AVAILABLE, "parse TNS info", errors.New("no connected TNS node"))
+	}
+	contextInstance.setTnsInfo(tnsAddrs)
+	return nil
 }
 
-func (contextInstance *EZMQXContext) readHostName(path string) EZMQXErrorCode {
-	Logger.Debug("[readFromFile] ", zap.String("File path: ", path))
+func (contextInstance *EZMQXContext) readHostName(path string) error {
+	Logger.Debug("[readFromFile] ", logField("file_path", path))
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		Logger.Error("[readFromFile] Unable to read from file")
-		return EZMQX_UNKNOWN_STATE
+		return newError(EZMQX_UNKNOWN_STATE, "read host name", err)
 	}
-	contextInstance.hostName = string(data)
 	//remove trailing /n
-	contextInstance.hostName = contextInstance.hostName[0 : len(contextInstance.hostName)-1]
-	Logger.Debug("[readFromFile] ", zap.String("hostName: ", contextInstance.hostName))
-	return EZMQX_OK
+	contextInstance.hostName = strings.TrimSpace(string(data))
+	if 0 == len(contextInstance.hostName) {
+		Logger.Error("[readFromFile] Host name is empty")
+		return newError(EZMQX_UNKNOWN_STATE, "read host name", errors.New("host name is empty"))
+	}
+	Logger.Debug("[readFromFile] ", logField("host_name", contextInstance.hostName))
+	return nil
 }
 
 func (contextInstance *EZMQXContext) parseAppsResponse(response RestResponse) *list.List {
 	statusCode := response.GetStatusCode()
-	Logger.Debug("[Running Apps] ", zap.Int(" Status code: ", statusCode))
+	Logger.Debug("[Running Apps] ", logField(LOG_FIELD_STATUS, statusCode))
 	if statusCode != HTTP_OK {
 		return nil
 	}
 	data := response.GetResponse()
-	Logger.Debug("[Running Apps] ", zap.String("Response: ", string(data)))
+	Logger.Debug("[Running Apps] ", logField("response", string(data)))
 	result := make(map[string][]interface{})
 	err := json.Unmarshal([]byte(data), &result)
 	if err != nil {
@@ -299,14 +508,14 @@ func (contextInstance *EZMQXContext) parseAppsResponse(response RestResponse) *l
 			Logger.Error("[Running Apps] App ID key not exists")
 			return nil
 		}
-		Logger.Debug("[Running Apps] ", zap.String("id: ", id.(string)))
+		Logger.Debug("[Running Apps] ", logField("id", id.(string)))
 		state, exists := stringMap[APPS_STATE]
 		if !exists {
 			Logger.Error("[Running Apps] App State key not exists")
 			return nil
 		}
 		stateString := state.(string)
-		Logger.Debug("[Running Apps] ", zap.String("state: ", stateString))
+		Logger.Debug("[Running Apps] ", logField("state", stateString))
 		if 0 == strings.Compare(stateString, APPS_STATE_RUNNING) {
 			idList.PushBack(id)
 		}
@@ -314,49 +523,49 @@ func (contextInstance *EZMQXContext) parseAppsResponse(response RestResponse) *l
 	return idList
 }
 
-func (contextInstance *EZMQXContext) parsePortInfo(port interface{}) EZMQXErrorCode {
+func (contextInstance *EZMQXContext) parsePortInfo(port interface{}) error {
 	ports := port.([]interface{})
 	for _, item := range ports {
 		stringMap := item.(map[string]interface{})
 		privatePort, exists := stringMap[PORTS_PRIVATE]
 		if !exists {
 			Logger.Error("[Running Apps] No private port key in json response")
-			return EZMQX_REST_ERROR
+			return newError(EZMQX_REST_ERROR, "parse port info", errors.New("no private port key in json response"))
 		}
 		priPort := strconv.FormatFloat(privatePort.(float64), 'f', -1, 64)
-		Logger.Debug("[Port info] ", zap.String("Private port: ", priPort))
+		Logger.Debug("[Port info] ", logField("private_port", priPort))
 		publicPort, exists := stringMap[PORTS_PUBLIC]
 		if !exists {
 			Logger.Error("[Running Apps] No public port key in json response")
-			return EZMQX_REST_ERROR
+			return newError(EZMQX_REST_ERROR, "parse port info", errors.New("no public port key in json response"))
 		}
 		pubPort := strconv.FormatFloat(publicPort.(float64), 'f', -1, 64)
-		Logger.Debug("[Port info] ", zap.String("Public Port: ", pubPort))
+		Logger.Debug("[Port info] ", logField("public_port", pubPort))
 		private, _ := strconv.Atoi(priPort)
 		public, _ := strconv.Atoi(pubPort)
 		contextInstance.ports[private] = public
 	}
-	return EZMQX_OK
+	return nil
 }
 
-func (contextInstance *EZMQXContext) parseAppInfo(response RestResponse) EZMQXErrorCode {
+func (contextInstance *EZMQXContext) parseAppInfo(response RestResponse) error {
 	statusCode := response.GetStatusCode()
-	Logger.Debug("[App info] ", zap.Int(" Status code: ", statusCode))
+	Logger.Debug("[App info] ", logField(LOG_FIELD_STATUS, statusCode))
 	if statusCode != HTTP_OK {
-		return EZMQX_REST_ERROR
+		return newError(EZMQX_REST_ERROR, "parse app info", &HTTPStatusError{statusCode})
 	}
 	data := response.GetResponse()
-	Logger.Debug("[App info] ", zap.String("Response: ", string(data)))
+	Logger.Debug("[App info] ", logField("response", string(data)))
 	appInfo := make(map[string]interface{})
 	err := json.Unmarshal([]byte(data), &appInfo)
 	if err != nil {
 		Logger.Error("[Running Apps] Unmarshal error")
-		return EZMQX_REST_ERROR
+		return newError(EZMQX_REST_ERROR, "parse app info", err)
 	}
 	services, exists := appInfo[SERVICES_PROPS]
 	if !exists {
 		Logger.Error("[Running Apps] No services key in json response")
-		return EZMQX_REST_ERROR
+		return newError(EZMQX_REST_ERROR, "parse app info", errors.New("no services key in json response"))
 	}
 	interfaces := services.([]interface{})
 	for _, service := range interfaces {
@@ -364,56 +573,49 @@ func (contextInstance *EZMQXContext) parseAppInfo(response RestResponse) EZMQXEr
 		cid, exists := serviceMap[SERVICES_CON_ID]
 		if !exists {
 			Logger.Error("[Running Apps] No id key in json response")
-			return EZMQX_REST_ERROR
+			return newError(EZMQX_REST_ERROR, "parse app info", errors.New("no id key in json response"))
 		}
 		hostName := contextInstance.hostName
 		containerId := cid.(string)
-		containerId = containerId[0:len(hostName)]
-		Logger.Debug("[App info] ", zap.String("Container Id: ", containerId))
-		Logger.Debug("[App info] ", zap.String("Host name: ", hostName))
-		if 0 == strings.Compare(containerId, hostName) {
+		Logger.Debug("[App info] ", logField("container_id", containerId))
+		Logger.Debug("[App info] ", logField("host_name", hostName))
+		if strings.HasPrefix(containerId, hostName) {
 			port, exists := serviceMap[SERVICES_CON_PORTS]
 			if !exists {
 				Logger.Error("[Running Apps] No ports key in json response")
-				return EZMQX_REST_ERROR
+				return newError(EZMQX_REST_ERROR, "parse app info", errors.New("no ports key in json response"))
 			}
-			result := contextInstance.parsePortInfo(port)
-			if result != EZMQX_OK {
+			err := contextInstance.parsePortInfo(port)
+			if err != nil {
 				Logger.Error("[Running Apps] Parse port info failed")
-				return EZMQX_REST_ERROR
+				return newError(EZMQX_REST_ERROR, "parse app info", err)
 			}
 		}
 	}
-	return EZMQX_OK
+	return nil
 }
 
-func (contextInstance *EZMQXContext) initializeDockerMode(tnsConfPath string) EZMQXErrorCode {
-	ezmqResult := ezmq.GetInstance().Initialize()
-	if ezmqResult != ezmq.EZMQ_OK {
-		Logger.Error("Could not initialize EZMQ")
-		return EZMQX_UNKNOWN_STATE
-	}
-
+func (contextInstance *EZMQXContext) initializeDockerMode(ctx context.Context, tnsConfPath string) error {
 	//Read image name from TNS config file
 	result := contextInstance.readImageName(tnsConfPath)
-	if result != EZMQX_OK {
+	if result != nil {
 		return result
 	}
 
-	restClient := GetRestFactory()
+	restClient := contextInstance.restFactory
 	var response *RestResponse
-	var err EZMQXErrorCode
+	var err error
 
 	// Configuration resource
-	configURL := NODE + PREFIX + API_CONFIG
-	Logger.Debug("[Config] ", zap.String("Rest URL: ", string(configURL)))
-	response, err = restClient.Get(configURL)
-	if err != EZMQX_OK {
+	configURL := contextInstance.nodeURL + PREFIX + API_CONFIG
+	Logger.Debug("[Config] ", logField(LOG_FIELD_URL, string(configURL)))
+	response, err = restClient.GetContext(ctx, configURL)
+	if err != nil {
 		Logger.Error("[Config] HTTP request failed")
-		return EZMQX_REST_ERROR
+		return err
 	}
 	result = contextInstance.parseConfigData(*response)
-	if result != EZMQX_OK {
+	if result != nil {
 		Logger.Error("[Config] Parse config data failed ")
 		return result
 	}
@@ -421,110 +623,114 @@ func (contextInstance *EZMQXContext) initializeDockerMode(tnsConfPath string) EZ
 	// Get TNS information
 	anchorTNSURL := contextInstance.anchorAddr + API_SEARCH_NODE
 	query := ANCHOR_IMAGE_NAME + contextInstance.tnsImageName
-	Logger.Debug("[TNS info] ", zap.String("Rest URL: ", string(anchorTNSURL)))
-	response, err = restClient.Get(anchorTNSURL + QUESTION_MARK + query)
-	if err != EZMQX_OK {
+	Logger.Debug("[TNS info] ", logField(LOG_FIELD_URL, string(anchorTNSURL)))
+	response, err = restClient.GetContext(ctx, anchorTNSURL+QUESTION_MARK+query)
+	if err != nil {
 		Logger.Error("[TNS info] HTTP request failed")
-		return EZMQX_REST_ERROR
+		return err
 	}
 	result = contextInstance.parseTnsInfoResponse(*response)
-	if result != EZMQX_OK {
+	if result != nil {
 		Logger.Error("[TNS info] Parse Tns info failed ")
 		return result
 	}
 
 	// Get Host Name
-	result = contextInstance.readHostName(HOST_NAME_FILE_PATH)
-	if result != EZMQX_OK {
+	result = contextInstance.readHostName(contextInstance.hostNameFilePath)
+	if result != nil {
 		Logger.Error("[Config] Read from file failed")
 		return result
 	}
 	// Applications resource
 	var idList *list.List = nil
-	appsURL := NODE + PREFIX + API_APPS
-	Logger.Debug("[Running Apps] ", zap.String("Rest URL: ", string(appsURL)))
-	response, err = restClient.Get(appsURL)
-	if err != EZMQX_OK {
+	appsURL := contextInstance.nodeURL + PREFIX + API_APPS
+	Logger.Debug("[Running Apps] ", logField(LOG_FIELD_URL, string(appsURL)))
+	response, err = restClient.GetContext(ctx, appsURL)
+	if err != nil {
 		Logger.Error("[Config] HTTP request failed")
-		return EZMQX_REST_ERROR
+		return err
 	}
 	idList = contextInstance.parseAppsResponse(*response)
 	if nil == idList {
 		Logger.Error("[Running Apps] Parse apps response failed")
-		return EZMQX_REST_ERROR
+		return newError(EZMQX_REST_ERROR, "start docker mode", errors.New("parse apps response failed"))
 	}
 	// APP info
-	appInfoURL := NODE + PREFIX + API_APPS + SLASH
+	appInfoURL := contextInstance.nodeURL + PREFIX + API_APPS + SLASH
 	for id := idList.Front(); id != nil; id = id.Next() {
 		appId := id.Value.(string)
 		url := appInfoURL + appId
-		Logger.Debug("[App Info] ", zap.String("Rest URL: ", url))
-		response, err = restClient.Get(url)
-		if err != EZMQX_OK {
+		Logger.Debug("[App Info] ", logField(LOG_FIELD_URL, url))
+		response, err = restClient.GetContext(ctx, url)
+		if err != nil {
 			Logger.Error("[App info] HTTP request failed")
-			return EZMQX_REST_ERROR
+			return err
 		}
 		contextInstance.parseAppInfo(*response)
 	}
+	if err = initializeEZMQ(); err != nil {
+		Logger.Error("Could not initialize EZMQ")
+		return err
+	}
 	contextInstance.initialized.Store(true)
 	contextInstance.terminated.Store(false)
 	contextInstance.tnsEnabled = true
 	Logger.Debug("EZMQX Context created")
-	return EZMQX_OK
+	return nil
 }
 
-func (contextInstance *EZMQXContext) initializeStandAloneMode(hostAddr string, useTns bool, tnsAddr string) EZMQXErrorCode {
-	result := ezmq.GetInstance().Initialize()
-	if result != ezmq.EZMQ_OK {
+func (contextInstance *EZMQXContext) initializeStandAloneMode(hostAddr string, useTns bool, tnsAddrs []string) error {
+	result := initializeEZMQ()
+	if result != nil {
 		Logger.Error("Could not start ezmq context")
-		return EZMQX_UNKNOWN_STATE
+		return result
 	}
-	ctxInstance.standAlone = true
-	ctxInstance.setHostInfo(LOCAL_HOST, hostAddr)
+	contextInstance.standAlone = true
+	contextInstance.setHostInfo(LOCAL_HOST, hostAddr)
 	if useTns {
-		ctxInstance.setTnsInfo(tnsAddr)
+		contextInstance.setTnsInfo(tnsAddrs)
 	}
-	ctxInstance.initialized.Store(true)
-	ctxInstance.terminated.Store(false)
+	contextInstance.initialized.Store(true)
+	contextInstance.terminated.Store(false)
 	Logger.Debug("EZMQX Context created")
-	return EZMQX_OK
+	return nil
 }
 
-func (cxtInstance *EZMQXContext) getAmlRep(amlModelId string) (*aml.Representation, EZMQXErrorCode) {
-	ctxInstance.mutex.Lock()
-	defer ctxInstance.mutex.Unlock()
+func (cxtInstance *EZMQXContext) getAmlRep(amlModelId string) (*aml.Representation, error) {
+	cxtInstance.mutex.Lock()
+	defer cxtInstance.mutex.Unlock()
 	rep := cxtInstance.amlRepDic[amlModelId]
 	if nil == rep {
 		Logger.Error("No representation found for model ID")
-		return nil, EZMQX_UNKNOWN_AML_MODEL
+		return nil, newError(EZMQX_UNKNOWN_AML_MODEL, "get AML representation", errors.New("no representation found for model ID"))
 	}
-	return rep, EZMQX_OK
+	return rep, nil
 }
 
-func (cxtInstance *EZMQXContext) addAmlRep(amlFilePath list.List) (*list.List, EZMQXErrorCode) {
+func (cxtInstance *EZMQXContext) addAmlRep(amlFilePath list.List) (*list.List, error) {
 	modelId := list.New()
-	ctxInstance.mutex.Lock()
-	defer ctxInstance.mutex.Unlock()
+	cxtInstance.mutex.Lock()
+	defer cxtInstance.mutex.Unlock()
 	for filePath := amlFilePath.Front(); filePath != nil; filePath = filePath.Next() {
 		repObject, err := aml.CreateRepresentation(filePath.Value.(string))
 		if err != aml.AML_OK {
 			Logger.Error("Create representation failed")
-			return modelId, EZMQX_INVALID_AML_MODEL
+			return modelId, newError(EZMQX_INVALID_AML_MODEL, "add AML model", errors.New("create representation failed"))
 		}
 		amlModelId, err := repObject.GetRepresentationId()
 		if err != aml.AML_OK {
 			Logger.Error("Get representation Id failed")
-			return modelId, EZMQX_INVALID_PARAM
+			return modelId, newError(EZMQX_INVALID_PARAM, "add AML model", errors.New("get representation Id failed"))
 		}
 		if nil == cxtInstance.amlRepDic[amlModelId] {
 			cxtInstance.amlRepDic[amlModelId] = repObject
 		}
 		modelId.PushBack(amlModelId)
 	}
-	return modelId, EZMQX_OK
+	return modelId, nil
 }
 
-func (cxtInstance *EZMQXContext) getHostEp(port int) (*EZMQXEndpoint, EZMQXErrorCode) {
+func (cxtInstance *EZMQXContext) getHostEp(port int) (*EZMQXEndpoint, error) {
 	hostPort := 0
 	if cxtInstance.isCtxStandAlone() {
 		hostPort = port
@@ -533,11 +739,11 @@ func (cxtInstance *EZMQXContext) getHostEp(port int) (*EZMQXEndpoint, EZMQXError
 			hostPort = cxtInstance.ports[port]
 		}
 		if 0 == hostPort {
-			return nil, EZMQX_UNKNOWN_STATE
+			return nil, newError(EZMQX_UNKNOWN_STATE, "get host endpoint", errors.New("no public port for local port "+strconv.Itoa(port)))
 		}
 	}
 	endPoint := GetEZMQXEndPoint1(cxtInstance.hostAddr, hostPort)
-	return endPoint, EZMQX_OK
+	return endPoint, nil
 }
 
 func (cxtInstance *EZMQXContext) terminate() EZMQXErrorCode {
@@ -547,9 +753,10 @@ func (cxtInstance *EZMQXContext) terminate() EZMQXErrorCode {
 	}
 
 	//terminate topic handler
-	topicHandler := getTopicHandler()
-	topicHandler.terminateHandler()
-	Logger.Debug("Terminated handler")
+	if nil != cxtInstance.topicHandler {
+		cxtInstance.topicHandler.terminateHandler()
+		Logger.Debug("Terminated handler")
+	}
 
 	//clear maps
 	for key := range cxtInstance.ports {
@@ -561,19 +768,24 @@ func (cxtInstance *EZMQXContext) terminate() EZMQXErrorCode {
 	for key := range cxtInstance.amlRepDic {
 		delete(cxtInstance.amlRepDic, key)
 	}
+	cxtInstance.mutex.Lock()
+	for key := range cxtInstance.publishedTopics {
+		delete(cxtInstance.publishedTopics, key)
+	}
+	if nil != cxtInstance.discoveryCache {
+		cxtInstance.discoveryCache = newDiscoveryCache(cxtInstance.discoveryCache.options)
+	}
+	cxtInstance.tnsServers = nil
+	cxtInstance.mutex.Unlock()
+	cxtInstance.restFactory.closeIdleConnections()
 	cxtInstance.hostName = ""
 	cxtInstance.hostAddr = ""
 	cxtInstance.anchorAddr = ""
-	cxtInstance.tnsAddr = ""
 	cxtInstance.usedIdx = 0
 	cxtInstance.numOfPort = 0
 	cxtInstance.standAlone = false
 	cxtInstance.tnsEnabled = false
-	Logger.Debug("Try EZMQ API terminate")
-	if ezmq.EZMQ_OK != ezmq.GetInstance().Terminate() {
-		Logger.Debug("EZMQ API terminate failed")
-	}
-	Logger.Debug("EZMQ API terminated")
+	terminateEZMQ()
 	cxtInstance.terminated.Store(true)
 	cxtInstance.initialized.Store(false)
 	Logger.Debug("EZMQX Context terminated")
@@ -599,7 +811,3 @@ func (cxtInstance *EZMQXContext) isCtxTnsEnabled() bool {
 func (cxtInstance *EZMQXContext) isReverseProxyEnabled() bool {
 	return (cxtInstance.reverseProxyEnabled.Load()).(bool)
 }
-
-func (cxtInstance *EZMQXContext) ctxGetTnsAddr() string {
-	return cxtInstance.tnsAddr
-}
@@ -0,0 +1,251 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options for client side cache of TNS query responses.
+//
+// Cache is shared by topic discovery and subscribers of a context, and keyed
+// by queried topic, hierarchical option and filters of query.
+type EZMQXDiscoveryCacheOptions struct {
+	// Time to keep response with topics. Cache is disabled if it is 0.
+	TTL time.Duration
+	// Time to keep response without any topic or not found response.
+	// If it is 0, such response is not cached. It is usually shorter than
+	// TTL, so that new topics are found soon.
+	NegativeTTL time.Duration
+	// If true, last response is used for up to TTL after it expires, when
+	// TNS is not reachable.
+	StaleWhileError bool
+}
+
+type discoveryCacheEntry struct {
+	topic          string
+	isHierarchical bool
+	data           []byte
+	notFound       bool
+	expiry         time.Time
+}
+
+type discoveryCache struct {
+	options EZMQXDiscoveryCacheOptions
+	entries map[string]*discoveryCacheEntry
+	mutex   *sync.Mutex
+}
+
+func validateDiscoveryCacheOptions(options EZMQXDiscoveryCacheOptions) error {
+	if options.TTL < 0 || options.NegativeTTL < 0 {
+		return newError(EZMQX_INVALID_PARAM, "set discovery cache options", errors.New("negative TTL"))
+	}
+	return nil
+}
+
+func newDiscoveryCache(options EZMQXDiscoveryCacheOptions) *discoveryCache {
+	var instance *discoveryCache
+	instance = &discoveryCache{}
+	instance.options = options
+	instance.entries = make(map[string]*discoveryCacheEntry)
+	instance.mutex = &sync.Mutex{}
+	return instance
+}
+
+// Get cached response data, or error of cached not found response.
+func (entry *discoveryCacheEntry) getResponse(op string) ([]byte, error) {
+	if entry.notFound {
+		return nil, newTopicError(EZMQX_REST_ERROR, op, entry.topic, &HTTPStatusError{HTTP_NOT_FOUND})
+	}
+	return entry.data, nil
+}
+
+// Get time until which entry is kept. Expired entry is kept for TTL more,
+// if it can be used while TNS is not reachable.
+func (cache *discoveryCache) getRetention(entry *discoveryCacheEntry) time.Time {
+	if cache.options.StaleWhileError {
+		return entry.expiry.Add(cache.options.TTL)
+	}
+	return entry.expiry
+}
+
+// Get cached entry and whether it is not expired yet.
+func (cache *discoveryCache) get(key string) (*discoveryCacheEntry, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entry, exists := cache.entries[key]
+	if !exists {
+		return nil, false
+	}
+	now := time.Now()
+	if !now.Before(cache.getRetention(entry)) {
+		delete(cache.entries, key)
+		return nil, false
+	}
+	return entry, now.Before(entry.expiry)
+}
+
+func (cache *discoveryCache) put(key string, topic string, isHierarchical bool, data []byte, notFound bool) {
+	ttl := cache.options.TTL
+	if notFound || isEmptyTNSResponse(data) {
+		ttl = cache.options.NegativeTTL
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.prune(time.Now())
+	if ttl <= 0 {
+		delete(cache.entries, key)
+		return
+	}
+	cache.entries[key] = &discoveryCacheEntry{topic: topic, isHierarchical: isHierarchical, data: data, notFound: notFound, expiry: time.Now().Add(ttl)}
+}
+
+// Remove entries which can not be used any more.
+// It should be called with mutex locked.
+func (cache *discoveryCache) prune(now time.Time) {
+	for key, entry := range cache.entries {
+		if !now.Before(cache.getRetention(entry)) {
+			delete(cache.entries, key)
+		}
+	}
+}
+
+// Remove cached responses which may contain the given topic.
+// Empty topic or TOPIC_ROOT removes all the responses.
+func (cache *discoveryCache) invalidate(topic string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for key, entry := range cache.entries {
+		if 0 == len(topic) || TOPIC_ROOT == topic || entry.topic == topic ||
+			(entry.isHierarchical && (TOPIC_ROOT == entry.topic || strings.HasPrefix(topic, entry.topic+F_SLASH))) {
+			delete(cache.entries, key)
+		}
+	}
+}
+
+// Check whether TNS response has no topic.
+func isEmptyTNSResponse(data []byte) bool {
+	var response struct {
+		Topics []json.RawMessage `json:"topics"`
+	}
+	err := json.Unmarshal(data, &response)
+	return nil == err && 0 == len(response.Topics)
+}
+
+// Check whether TNS responded that the topic is not found.
+func isNotFoundError(err error) bool {
+	var statusError *HTTPStatusError
+	return errors.As(err, &statusError) && HTTP_NOT_FOUND == statusError.StatusCode
+}
+
+func getDiscoveryCacheKey(topic string, isHierarchical bool, extraQuery string) string {
+	var hierarchical string
+	if true == isHierarchical {
+		hierarchical = QUERY_TRUE
+	} else {
+		hierarchical = QUERY_FALSE
+	}
+	return topic + QUERY_HIERARCHICAL + hierarchical + extraQuery
+}
+
+func (cxtInstance *EZMQXContext) setDiscoveryCacheOptions(options EZMQXDiscoveryCacheOptions) error {
+	err := validateDiscoveryCacheOptions(options)
+	if err != nil {
+		return err
+	}
+	cxtInstance.mutex.Lock()
+	defer cxtInstance.mutex.Unlock()
+	if 0 == options.TTL {
+		cxtInstance.discoveryCache = nil
+		return nil
+	}
+	cxtInstance.discoveryCache = newDiscoveryCache(options)
+	return nil
+}
+
+func (cxtInstance *EZMQXContext) getDiscoveryCache() *discoveryCache {
+	cxtInstance.mutex.Lock()
+	defer cxtInstance.mutex.Unlock()
+	return cxtInstance.discoveryCache
+}
+
+func (cxtInstance *EZMQXContext) invalidateDiscoveryCache(topic string) {
+	cache := cxtInstance.getDiscoveryCache()
+	if nil != cache {
+		cache.invalidate(topic)
+	}
+}
+
+// Send topic query to TNS and get response data.
+//
+// If discovery cache is enabled and useCache is true, cached response is
+// used until it expires. Response is cached regardless of useCache.
+func (cxtInstance *EZMQXContext) queryTNS(ctx context.Context, op string, topic string, isHierarchical bool, extraQuery string, useCache bool) ([]byte, error) {
+	key := getDiscoveryCacheKey(topic, isHierarchical, extraQuery)
+	cache := cxtInstance.getDiscoveryCache()
+	var cached *discoveryCacheEntry
+	if nil != cache {
+		var isFresh bool
+		cached, isFresh = cache.get(key)
+		if nil != cached && isFresh && useCache {
+			Logger.Debug("[TNS query] cached response", logField(LOG_FIELD_TOPIC, topic))
+			return cached.getResponse(op)
+		}
+	}
+
+	query := QUERY_NAME + key
+	client := cxtInstance.GetRestFactory()
+	var response *RestResponse
+	err := cxtInstance.tnsRequest(ctx, cxtInstance.getRetryPolicy(), func(ctx context.Context, tnsAddr string) error {
+		tnsURL := tnsAddr + PREFIX + TOPIC
+		Logger.Debug("[TNS query]", logField(LOG_FIELD_URL, tnsURL), logField("query", query))
+		var err error
+		response, err = client.GetContext(ctx, tnsURL+QUESTION_MARK+query)
+		if err != nil {
+			Logger.Error("[TNS query] request failed")
+			return newTopicError(EZMQX_REST_ERROR, op, topic, err)
+		}
+		if response.GetStatusCode() != HTTP_OK {
+			Logger.Error("[TNS query] Response code is not HTTP_OK")
+			return newTopicError(EZMQX_REST_ERROR, op, topic, &HTTPStatusError{response.GetStatusCode()})
+		}
+		return nil
+	})
+	if err != nil {
+		if nil != cache && isNotFoundError(err) {
+			cache.put(key, topic, isHierarchical, nil, true)
+			return nil, err
+		}
+		if nil != cached && cache.options.StaleWhileError && nil == ctx.Err() {
+			Logger.Debug("[TNS query] TNS is not reachable, stale response is used", logField(LOG_FIELD_TOPIC, topic))
+			return cached.getResponse(op)
+		}
+		return nil, err
+	}
+	data := response.GetResponse()
+	Logger.Debug("[TNS query]", logField("response", string(data)))
+	if nil != cache {
+		cache.put(key, topic, isHierarchical, data, false)
+	}
+	return data, nil
+}
@@ -0,0 +1,109 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Version of message envelope written by publishers.
+const ENVELOPE_VERSION = 1
+
+// Envelope starts with a zero byte, which never starts an AML payload as
+// protobuf has no field number 0, so that legacy payloads can be told apart.
+var envelopeMagic = []byte{0x00, 'E', 'Z', 'X'}
+
+// Magic, version and header length.
+const envelopePrefixSize = 4 + 1 + 4
+
+// Header of a message published by EZMQXAMLPublisher.
+type EZMQXMessageHeader struct {
+	// Version of envelope.
+	Version int `json:"-"`
+	// Id of publisher, it is unique for every publisher instance.
+	PublisherId string `json:"publisherId"`
+	// Sequence number of message, it starts from 1 for every publisher.
+	Sequence uint64 `json:"sequence"`
+	// Time when message is published.
+	Timestamp time.Time `json:"-"`
+	// Data model id of AML payload.
+	DataModelId string `json:"dataModelId"`
+	// Free-form headers given by PublishWithHeaders.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type envelopeHeader struct {
+	EZMQXMessageHeader
+	Timestamp int64 `json:"timestamp"`
+}
+
+func newPublisherId() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Wrap payload in envelope:
+// magic (4 bytes), version (1 byte), header length (4 bytes, big endian),
+// JSON header and payload.
+func encodeEnvelope(header *EZMQXMessageHeader, payload []byte) ([]byte, error) {
+	headerData, err := json.Marshal(envelopeHeader{*header, header.Timestamp.UnixNano()})
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, envelopePrefixSize, envelopePrefixSize+len(headerData)+len(payload))
+	copy(data, envelopeMagic)
+	data[len(envelopeMagic)] = ENVELOPE_VERSION
+	binary.BigEndian.PutUint32(data[len(envelopeMagic)+1:], uint32(len(headerData)))
+	data = append(data, headerData...)
+	return append(data, payload...), nil
+}
+
+// Get header and payload of message.
+// Legacy payload without envelope is returned as it is with nil header.
+func decodeEnvelope(data []byte) (*EZMQXMessageHeader, []byte, error) {
+	if !bytes.HasPrefix(data, envelopeMagic) {
+		return nil, data, nil
+	}
+	if len(data) < envelopePrefixSize {
+		return nil, nil, errors.New("truncated envelope")
+	}
+	version := int(data[len(envelopeMagic)])
+	if version < 1 || version > ENVELOPE_VERSION {
+		return nil, nil, errors.New("unsupported envelope version")
+	}
+	headerSize := binary.BigEndian.Uint32(data[len(envelopeMagic)+1:])
+	if uint64(headerSize) > uint64(len(data)-envelopePrefixSize) {
+		return nil, nil, errors.New("truncated envelope header")
+	}
+	var header envelopeHeader
+	err := json.Unmarshal(data[envelopePrefixSize:envelopePrefixSize+int(headerSize)], &header)
+	if err != nil {
+		return nil, nil, err
+	}
+	result := header.EZMQXMessageHeader
+	result.Version = version
+	result.Timestamp = time.Unix(0, header.Timestamp)
+	return &result, data[envelopePrefixSize+int(headerSize):], nil
+}
@@ -0,0 +1,174 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Structure represents EZMQX error.
+//
+// It carries the EZMQX error code, the failed operation, the topic or URL
+// the operation was working on and the underlying cause.
+// Error code can be checked using errors.Is:
+//
+//	errors.Is(err, EZMQXErrorCode(EZMQX_REST_ERROR))
+type EZMQXError struct {
+	Code  EZMQXErrorCode
+	Op    string
+	Topic string
+	URL   string
+	Err   error
+}
+
+// Structure represents unexpected HTTP status code in a REST response.
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+// Structure represents REST response which can not be parsed, like invalid
+// JSON or missing key. Such response is not retried.
+type ResponseParseError struct {
+	Err error
+}
+
+var errInvalidState = errors.New("invalid state")
+
+// Failure of request sent by client which implements only RestClientInterface.
+var errRequestFailed = errors.New("request failed")
+
+var errorCodeNames = map[EZMQXErrorCode]string{
+	EZMQX_OK:                  "EZMQX_OK",
+	EZMQX_INVALID_PARAM:       "EZMQX_INVALID_PARAM",
+	EZMQX_INITIALIZED:         "EZMQX_INITIALIZED",
+	EZMQX_NOT_INITIALIZED:     "EZMQX_NOT_INITIALIZED",
+	EZMQX_TERMINATED:          "EZMQX_TERMINATED",
+	EZMQX_UNKNOWN_STATE:       "EZMQX_UNKNOWN_STATE",
+	EZMQX_SERVICE_UNAVAILABLE: "EZMQX_SERVICE_UNAVAILABLE",
+	EZMQX_INVALID_TOPIC:       "EZMQX_INVALID_TOPIC",
+	EZMQX_DUPLICATED_TOPIC:    "EZMQX_DUPLICATED_TOPIC",
+	EZMQX_UNKNOWN_TOPIC:       "EZMQX_UNKNOWN_TOPIC",
+	EZMQX_INVALID_ENDPOINT:    "EZMQX_INVALID_ENDPOINT",
+	EZMQX_BROKEN_PAYLOAD:      "EZMQX_BROKEN_PAYLOAD",
+	EZMQX_REST_ERROR:          "EZMQX_REST_ERROR",
+	EZMQX_MAXIMUM_PORT_EXCEED: "EZMQX_MAXIMUM_PORT_EXCEED",
+	EZMQX_RELEASE_WRONG_PORT:  "EZMQX_RELEASE_WRONG_PORT",
+	EZMQX_NO_TOPIC_MATCHED:    "EZMQX_NO_TOPIC_MATCHED",
+	EZMQX_TNS_NOT_AVAILABLE:   "EZMQX_TNS_NOT_AVAILABLE",
+	EZMQX_UNKNOWN_AML_MODEL:   "EZMQX_UNKNOWN_AML_MODEL",
+	EZMQX_INVALID_AML_MODEL:   "EZMQX_INVALID_AML_MODEL",
+	EZMQX_SESSION_UNAVAILABLE: "EZMQX_SESSION_UNAVAILABLE",
+	EZMQX_MESSAGE_DROPPED:     "EZMQX_MESSAGE_DROPPED",
+	EZMQX_RECONNECTED:         "EZMQX_RECONNECTED",
+	EZMQX_MESSAGE_LOSS:        "EZMQX_MESSAGE_LOSS",
+	EZMQX_MESSAGE_DUPLICATED:  "EZMQX_MESSAGE_DUPLICATED",
+	EZMQX_MESSAGE_REORDERED:   "EZMQX_MESSAGE_REORDERED",
+}
+
+// Get name of error code.
+func (code EZMQXErrorCode) String() string {
+	name, exists := errorCodeNames[code]
+	if !exists {
+		return "EZMQX_ERROR(" + strconv.Itoa(int(code)) + ")"
+	}
+	return name
+}
+
+// Error code can be used as a target of errors.Is.
+func (code EZMQXErrorCode) Error() string {
+	return code.String()
+}
+
+// Get error message.
+func (e *EZMQXError) Error() string {
+	message := "ezmqx: " + e.Op
+	if len(e.Topic) > 0 {
+		message += " [" + e.Topic + "]"
+	}
+	if len(e.URL) > 0 {
+		message += " [" + e.URL + "]"
+	}
+	message += ": " + e.Code.String()
+	if nil != e.Err {
+		message += ": " + e.Err.Error()
+	}
+	return message
+}
+
+// Get underlying cause of the error.
+func (e *EZMQXError) Unwrap() error {
+	return e.Err
+}
+
+// Check whether target is the same EZMQX error code.
+func (e *EZMQXError) Is(target error) bool {
+	switch t := target.(type) {
+	case EZMQXErrorCode:
+		return e.Code == t
+	case *EZMQXError:
+		return e.Code == t.Code
+	}
+	return false
+}
+
+// Get error message.
+func (e *HTTPStatusError) Error() string {
+	return "unexpected HTTP status " + strconv.Itoa(e.StatusCode)
+}
+
+// Get error message.
+func (e *ResponseParseError) Error() string {
+	return "invalid response: " + e.Err.Error()
+}
+
+// Get underlying cause of the error.
+func (e *ResponseParseError) Unwrap() error {
+	return e.Err
+}
+
+// Get EZMQX error code of the given error.
+//
+// It returns EZMQX_OK for nil error and EZMQX_UNKNOWN_STATE for error that
+// does not carry any EZMQX error code.
+func ErrorCodeOf(err error) EZMQXErrorCode {
+	if nil == err {
+		return EZMQX_OK
+	}
+	var ezmqxError *EZMQXError
+	if errors.As(err, &ezmqxError) {
+		return ezmqxError.Code
+	}
+	var code EZMQXErrorCode
+	if errors.As(err, &code) {
+		return code
+	}
+	return EZMQX_UNKNOWN_STATE
+}
+
+func newError(code EZMQXErrorCode, op string, err error) *EZMQXError {
+	return &EZMQXError{Code: code, Op: op, Err: err}
+}
+
+func newTopicError(code EZMQXErrorCode, op string, topic string, err error) *EZMQXError {
+	return &EZMQXError{Code: code, Op: op, Topic: topic, Err: err}
+}
+
+func newURLError(code EZMQXErrorCode, op string, url string, err error) *EZMQXError {
+	return &EZMQXError{Code: code, Op: op, URL: url, Err: err}
+}
@@ -41,4 +41,9 @@ const (
 	EZMQX_UNKNOWN_AML_MODEL   = 17
 	EZMQX_INVALID_AML_MODEL   = 18
 	EZMQX_SESSION_UNAVAILABLE = 19
+	EZMQX_MESSAGE_DROPPED     = 20
+	EZMQX_RECONNECTED         = 21
+	EZMQX_MESSAGE_LOSS        = 22
+	EZMQX_MESSAGE_DUPLICATED  = 23
+	EZMQX_MESSAGE_REORDERED   = 24
 )
@@ -0,0 +1,209 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Latency histogram has LATENCY_BUCKETS_PER_DOUBLING buckets for every doubling
+// of latency from LATENCY_HISTOGRAM_MIN, so that percentiles are within 19 percent.
+const LATENCY_HISTOGRAM_MIN = time.Microsecond
+const LATENCY_BUCKETS_PER_DOUBLING = 4
+const latencyBucketCount = LATENCY_BUCKETS_PER_DOUBLING * 27
+
+// Options of publish to callback latency measurement of subscriber.
+//
+// Latency is measured with publish time in message header, so clock of
+// publisher host is assumed to be synced with clock of subscriber host.
+// Otherwise offset of publisher clock from subscriber clock should be given,
+// or estimated with EstimateClockOffset.
+type EZMQXLatencyOptions struct {
+	// Offset of publisher clock from subscriber clock. It is positive if
+	// clock of publisher is ahead.
+	ClockOffset time.Duration
+	// Clock offsets per publisher id, they override ClockOffset.
+	PublisherClockOffsets map[string]time.Duration
+	// If true, remaining clock offset of every publisher is estimated as the
+	// minimum latency seen from it, so latency is measured above the fastest delivery.
+	EstimateClockOffset bool
+}
+
+// Publish to callback latency of messages on a topic.
+// Percentiles are upper bounds of histogram buckets, Min and Max are exact.
+type EZMQXLatencyStats struct {
+	Count uint64
+	// Messages with negative latency, they are counted as 0.
+	// It means that clock offset is needed.
+	Negative uint64
+	Min      time.Duration
+	Max      time.Duration
+	Mean     time.Duration
+	P50      time.Duration
+	P95      time.Duration
+	P99      time.Duration
+}
+
+type latencyHistogram struct {
+	buckets  [latencyBucketCount]uint64
+	count    uint64
+	sum      time.Duration
+	min      time.Duration
+	max      time.Duration
+	negative uint64
+}
+
+// Tracker of latency histograms per topic.
+type latencyTracker struct {
+	histograms map[string]*latencyHistogram
+	options    EZMQXLatencyOptions
+	// Minimum latency per publisher id, used as estimated clock offset.
+	minLatency map[string]time.Duration
+	mutex      *sync.Mutex
+}
+
+func newLatencyTracker() *latencyTracker {
+	var instance *latencyTracker
+	instance = &latencyTracker{}
+	instance.histograms = make(map[string]*latencyHistogram)
+	instance.minLatency = make(map[string]time.Duration)
+	instance.mutex = &sync.Mutex{}
+	return instance
+}
+
+func getLatencyBucket(latency time.Duration) int {
+	if latency <= LATENCY_HISTOGRAM_MIN {
+		return 0
+	}
+	index := int(math.Ceil(math.Log2(float64(latency)/float64(LATENCY_HISTOGRAM_MIN))*LATENCY_BUCKETS_PER_DOUBLING)) - 1
+	if index >= latencyBucketCount {
+		return latencyBucketCount - 1
+	}
+	return index
+}
+
+// Get upper bound of bucket.
+func getLatencyBucketBound(index int) time.Duration {
+	return time.Duration(float64(LATENCY_HISTOGRAM_MIN) * math.Exp2(float64(index+1)/LATENCY_BUCKETS_PER_DOUBLING))
+}
+
+func (instance *latencyTracker) setOptions(options EZMQXLatencyOptions) {
+	offsets := make(map[string]time.Duration, len(options.PublisherClockOffsets))
+	for publisherId, offset := range options.PublisherClockOffsets {
+		offsets[publisherId] = offset
+	}
+	options.PublisherClockOffsets = offsets
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	instance.options = options
+	instance.minLatency = make(map[string]time.Duration)
+}
+
+// Record latency of message received now.
+// Legacy payload without message header is not recorded.
+func (instance *latencyTracker) record(topic string, header *EZMQXMessageHeader, now time.Time) {
+	if nil == header || header.Timestamp.IsZero() {
+		return
+	}
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	latency := now.Sub(header.Timestamp)
+	if offset, exists := instance.options.PublisherClockOffsets[header.PublisherId]; exists {
+		latency += offset
+	} else {
+		latency += instance.options.ClockOffset
+	}
+	if instance.options.EstimateClockOffset {
+		minLatency, exists := instance.minLatency[header.PublisherId]
+		if !exists || latency < minLatency {
+			minLatency = latency
+			instance.minLatency[header.PublisherId] = minLatency
+		}
+		latency -= minLatency
+	}
+	histogram, exists := instance.histograms[topic]
+	if !exists {
+		histogram = &latencyHistogram{}
+		instance.histograms[topic] = histogram
+	}
+	if latency < 0 {
+		histogram.negative++
+		latency = 0
+	}
+	if 0 == histogram.count || latency < histogram.min {
+		histogram.min = latency
+	}
+	if latency > histogram.max {
+		histogram.max = latency
+	}
+	histogram.buckets[getLatencyBucket(latency)]++
+	histogram.count++
+	histogram.sum += latency
+}
+
+// Get latency of given quantile, it is not more than max.
+func (histogram *latencyHistogram) getPercentile(quantile float64) time.Duration {
+	rank := uint64(math.Ceil(quantile * float64(histogram.count)))
+	var count uint64
+	for index, bucketCount := range histogram.buckets {
+		count += bucketCount
+		if count >= rank {
+			bound := getLatencyBucketBound(index)
+			if bound > histogram.max {
+				return histogram.max
+			}
+			return bound
+		}
+	}
+	return histogram.max
+}
+
+func (instance *latencyTracker) getStats() map[string]EZMQXLatencyStats {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	stats := make(map[string]EZMQXLatencyStats, len(instance.histograms))
+	for topic, histogram := range instance.histograms {
+		stats[topic] = EZMQXLatencyStats{
+			Count:    histogram.count,
+			Negative: histogram.negative,
+			Min:      histogram.min,
+			Max:      histogram.max,
+			Mean:     histogram.sum / time.Duration(histogram.count),
+			P50:      histogram.getPercentile(0.50),
+			P95:      histogram.getPercentile(0.95),
+			P99:      histogram.getPercentile(0.99),
+		}
+	}
+	return stats
+}
+
+// Clear latency histograms of all the topics.
+func (instance *latencyTracker) reset() {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	instance.histograms = make(map[string]*latencyHistogram)
+}
+
+// Forget latency of topic, e.g. when it is unsubscribed.
+func (instance *latencyTracker) removeTopic(topic string) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	delete(instance.histograms, topic)
+}
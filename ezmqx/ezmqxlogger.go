@@ -0,0 +1,218 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"log/slog"
+	"sync/atomic"
+)
+
+// Log level of EZMQX library.
+type EZMQXLogLevel int32
+
+// Log level of EZMQX library.
+const (
+	LOG_LEVEL_DEBUG EZMQXLogLevel = iota
+	LOG_LEVEL_INFO
+	LOG_LEVEL_WARN
+	LOG_LEVEL_ERROR
+	// No log is written.
+	LOG_LEVEL_OFF
+)
+
+// Keys of common log fields.
+const LOG_FIELD_TOPIC = "topic"
+const LOG_FIELD_ENDPOINT = "endpoint"
+const LOG_FIELD_URL = "url"
+const LOG_FIELD_STATUS = "status"
+const LOG_FIELD_ERROR = "error"
+
+// Structured field of log.
+type EZMQXLogField struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger of EZMQX library, which can be set by SetLogger.
+// Log is called only for levels enabled by SetLogLevel.
+type EZMQXLogger interface {
+	Log(level EZMQXLogLevel, msg string, fields []EZMQXLogField)
+}
+
+type zapLogger struct {
+	logger *zap.Logger
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// Writes logs to EZMQXLogger which is enabled by log level.
+type logWriter struct {
+	logger atomic.Value
+	level  int32
+}
+
+type loggerHolder struct {
+	logger EZMQXLogger
+}
+
+// Logger used inside EZMQX library. It is shared by all the contexts.
+var Logger = newLogWriter()
+
+// Get EZMQXLogger which writes logs to zap logger.
+func NewZapLogger(logger *zap.Logger) EZMQXLogger {
+	return &zapLogger{logger: logger}
+}
+
+func (instance *zapLogger) Log(level EZMQXLogLevel, msg string, fields []EZMQXLogField) {
+	zapFields := make([]zap.Field, len(fields))
+	for i, field := range fields {
+		zapFields[i] = zap.Any(field.Key, field.Value)
+	}
+	switch level {
+	case LOG_LEVEL_DEBUG:
+		instance.logger.Debug(msg, zapFields...)
+	case LOG_LEVEL_INFO:
+		instance.logger.Info(msg, zapFields...)
+	case LOG_LEVEL_WARN:
+		instance.logger.Warn(msg, zapFields...)
+	default:
+		instance.logger.Error(msg, zapFields...)
+	}
+}
+
+// Get EZMQXLogger which writes logs to slog logger.
+func NewSlogLogger(logger *slog.Logger) EZMQXLogger {
+	return &slogLogger{logger: logger}
+}
+
+func (instance *slogLogger) Log(level EZMQXLogLevel, msg string, fields []EZMQXLogField) {
+	attrs := make([]slog.Attr, len(fields))
+	for i, field := range fields {
+		attrs[i] = slog.Any(field.Key, field.Value)
+	}
+	var slogLevel slog.Level
+	switch level {
+	case LOG_LEVEL_DEBUG:
+		slogLevel = slog.LevelDebug
+	case LOG_LEVEL_INFO:
+		slogLevel = slog.LevelInfo
+	case LOG_LEVEL_WARN:
+		slogLevel = slog.LevelWarn
+	default:
+		slogLevel = slog.LevelError
+	}
+	instance.logger.LogAttrs(context.Background(), slogLevel, msg, attrs...)
+}
+
+func newLogWriter() *logWriter {
+	var instance *logWriter
+	instance = &logWriter{}
+	logger, level := getDefaultLogger()
+	instance.setLogger(logger)
+	instance.setLevel(level)
+	return instance
+}
+
+// Reset logger and log level to the defaults of build.
+// Logger is ready when package is loaded, so it is kept only for compatibility.
+func InitLogger() {
+	logger, level := getDefaultLogger()
+	Logger.setLogger(logger)
+	Logger.setLevel(level)
+}
+
+// Set logger of EZMQX library. If logger is nil, no log is written.
+// Logger is global, it is shared by all the contexts of the process.
+// NewZapLogger and NewSlogLogger can be used to write logs to zap or slog.
+func SetLogger(logger EZMQXLogger) {
+	Logger.setLogger(logger)
+}
+
+// Set log level of EZMQX library, it can be changed at runtime.
+// Log level is global, it is shared by all the contexts of the process.
+func SetLogLevel(level EZMQXLogLevel) error {
+	err := validateLogLevel(level)
+	if err != nil {
+		return err
+	}
+	Logger.setLevel(level)
+	return nil
+}
+
+// Get log level of EZMQX library.
+func GetLogLevel() EZMQXLogLevel {
+	return Logger.getLevel()
+}
+
+func validateLogLevel(level EZMQXLogLevel) error {
+	if level < LOG_LEVEL_DEBUG || level > LOG_LEVEL_OFF {
+		return newError(EZMQX_INVALID_PARAM, "set log level", errors.New("invalid log level"))
+	}
+	return nil
+}
+
+func (instance *logWriter) setLogger(logger EZMQXLogger) {
+	instance.logger.Store(loggerHolder{logger: logger})
+}
+
+func (instance *logWriter) setLevel(level EZMQXLogLevel) {
+	atomic.StoreInt32(&instance.level, int32(level))
+}
+
+func (instance *logWriter) getLevel() EZMQXLogLevel {
+	return EZMQXLogLevel(atomic.LoadInt32(&instance.level))
+}
+
+func (instance *logWriter) log(level EZMQXLogLevel, msg string, fields []EZMQXLogField) {
+	if level < instance.getLevel() {
+		return
+	}
+	holder := instance.logger.Load().(loggerHolder)
+	if nil != holder.logger {
+		holder.logger.Log(level, msg, fields)
+	}
+}
+
+func (instance *logWriter) Debug(msg string, fields ...EZMQXLogField) {
+	instance.log(LOG_LEVEL_DEBUG, msg, fields)
+}
+
+func (instance *logWriter) Info(msg string, fields ...EZMQXLogField) {
+	instance.log(LOG_LEVEL_INFO, msg, fields)
+}
+
+func (instance *logWriter) Warn(msg string, fields ...EZMQXLogField) {
+	instance.log(LOG_LEVEL_WARN, msg, fields)
+}
+
+func (instance *logWriter) Error(msg string, fields ...EZMQXLogField) {
+	instance.log(LOG_LEVEL_ERROR, msg, fields)
+}
+
+func logField(key string, value interface{}) EZMQXLogField {
+	return EZMQXLogField{Key: key, Value: value}
+}
+
+func logError(err error) EZMQXLogField {
+	return EZMQXLogField{Key: LOG_FIELD_ERROR, Value: err}
+}
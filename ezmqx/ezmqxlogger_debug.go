@@ -1,19 +1,17 @@
+//go:build debug
 // +build debug
 
 package ezmqx
 
 import (
 	"go.uber.org/zap"
-
-	"fmt"
 )
 
-var Logger *zap.Logger
-
-func InitLogger() {
-	var err error
-	Logger, err = zap.NewDevelopment()
+// Debug build writes all the logs to zap development logger.
+func getDefaultLogger() (EZMQXLogger, EZMQXLogLevel) {
+	logger, err := zap.NewDevelopment()
 	if nil != err {
-		_ = fmt.Errorf("\nlogger creation failed")
+		return nil, LOG_LEVEL_OFF
 	}
+	return NewZapLogger(logger), LOG_LEVEL_DEBUG
 }
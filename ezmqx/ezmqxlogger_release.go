@@ -1,19 +1,9 @@
+//go:build !debug
 // +build !debug
 
 package ezmqx
 
-import (
-	"go.uber.org/zap"
-
-	"fmt"
-)
-
-var Logger *zap.Logger
-
-func InitLogger() {
-	var err error
-	Logger = zap.NewNop()
-	if nil != err {
-		_ = fmt.Errorf("\nlogger creation failed")
-	}
+// Release build writes no log until logger is set by SetLogger.
+func getDefaultLogger() (EZMQXLogger, EZMQXLogLevel) {
+	return nil, LOG_LEVEL_INFO
 }
@@ -0,0 +1,331 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Upper bounds in seconds of latency buckets of REST requests.
+var METRICS_LATENCY_BUCKETS = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Content type of Prometheus text format.
+const METRICS_CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+// Counters of a topic.
+type EZMQXTopicMetrics struct {
+	// Messages and bytes published by EZMQXAMLPublisher.
+	MessagesPublished uint64
+	BytesPublished    uint64
+	// Messages and bytes received by subscribers.
+	MessagesReceived uint64
+	BytesReceived    uint64
+	// Received messages which failed with EZMQX_BROKEN_PAYLOAD.
+	BrokenPayloads uint64
+	// Received messages which are dropped with EZMQX_UNKNOWN_TOPIC.
+	UnknownTopics uint64
+	// Messages reported with EZMQX_MESSAGE_LOSS, EZMQX_MESSAGE_DUPLICATED
+	// and EZMQX_MESSAGE_REORDERED. Lost messages which arrive later are
+	// counted in both LostMessages and ReorderedMessages.
+	LostMessages       uint64
+	DuplicatedMessages uint64
+	ReorderedMessages  uint64
+}
+
+// Counters of REST requests to TNS and pharos with the same method and path.
+type EZMQXRequestMetrics struct {
+	Method string
+	Path   string
+	// Number of responses per status code. Requests failed without
+	// response are counted with status code 0.
+	StatusCodes map[int]uint64
+	Count       uint64
+	LatencySum  time.Duration
+	// Cumulative number of requests per upper bound of METRICS_LATENCY_BUCKETS.
+	LatencyBuckets []uint64
+}
+
+// Snapshot of metrics of a context.
+type EZMQXMetricsSnapshot struct {
+	Topics             map[string]EZMQXTopicMetrics
+	Requests           []EZMQXRequestMetrics
+	KeepAliveSuccesses uint64
+	KeepAliveFailures  uint64
+}
+
+type topicMetrics struct {
+	messagesPublished uint64
+	bytesPublished    uint64
+	messagesReceived  uint64
+	bytesReceived     uint64
+	brokenPayloads    uint64
+	unknownTopics     uint64
+	lostMessages      uint64
+	duplicated        uint64
+	reordered         uint64
+}
+
+type requestKey struct {
+	method string
+	path   string
+}
+
+type metrics struct {
+	topics             map[string]*topicMetrics
+	requests           map[requestKey]*EZMQXRequestMetrics
+	keepAliveSuccesses uint64
+	keepAliveFailures  uint64
+	mutex              *sync.RWMutex
+}
+
+func newMetrics() *metrics {
+	var instance *metrics
+	instance = &metrics{}
+	instance.topics = make(map[string]*topicMetrics)
+	instance.requests = make(map[requestKey]*EZMQXRequestMetrics)
+	instance.mutex = &sync.RWMutex{}
+	return instance
+}
+
+func (instance *metrics) getTopic(topic string) *topicMetrics {
+	instance.mutex.RLock()
+	counters, exists := instance.topics[topic]
+	instance.mutex.RUnlock()
+	if exists {
+		return counters
+	}
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	counters, exists = instance.topics[topic]
+	if !exists {
+		counters = &topicMetrics{}
+		instance.topics[topic] = counters
+	}
+	return counters
+}
+
+func (instance *metrics) addPublished(topic string, size int) {
+	counters := instance.getTopic(topic)
+	atomic.AddUint64(&counters.messagesPublished, 1)
+	atomic.AddUint64(&counters.bytesPublished, uint64(size))
+}
+
+func (instance *metrics) addReceived(topic string, size int) {
+	counters := instance.getTopic(topic)
+	atomic.AddUint64(&counters.messagesReceived, 1)
+	atomic.AddUint64(&counters.bytesReceived, uint64(size))
+}
+
+// Count receive error of topic.
+func (instance *metrics) addReceiveError(topic string, errorCode EZMQXErrorCode) {
+	counters := instance.getTopic(topic)
+	switch errorCode {
+	case EZMQX_BROKEN_PAYLOAD:
+		atomic.AddUint64(&counters.brokenPayloads, 1)
+	case EZMQX_UNKNOWN_TOPIC:
+		atomic.AddUint64(&counters.unknownTopics, 1)
+	}
+}
+
+// Count sequence error of topic.
+func (instance *metrics) addSequenceError(topic string, errorCode EZMQXErrorCode, count uint64) {
+	counters := instance.getTopic(topic)
+	switch errorCode {
+	case EZMQX_MESSAGE_LOSS:
+		atomic.AddUint64(&counters.lostMessages, count)
+	case EZMQX_MESSAGE_DUPLICATED:
+		atomic.AddUint64(&counters.duplicated, count)
+	case EZMQX_MESSAGE_REORDERED:
+		atomic.AddUint64(&counters.reordered, count)
+	}
+}
+
+func (instance *metrics) addKeepAlive(success bool) {
+	if success {
+		atomic.AddUint64(&instance.keepAliveSuccesses, 1)
+	} else {
+		atomic.AddUint64(&instance.keepAliveFailures, 1)
+	}
+}
+
+// Count REST request, status code is 0 if it failed without response.
+func (instance *metrics) addRequest(method string, rawURL string, statusCode int, latency time.Duration) {
+	path := rawURL
+	if parsed, err := url.Parse(rawURL); nil == err {
+		path = parsed.Path
+	}
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	key := requestKey{method: method, path: path}
+	counters, exists := instance.requests[key]
+	if !exists {
+		counters = &EZMQXRequestMetrics{Method: method, Path: path}
+		counters.StatusCodes = make(map[int]uint64)
+		counters.LatencyBuckets = make([]uint64, len(METRICS_LATENCY_BUCKETS))
+		instance.requests[key] = counters
+	}
+	counters.StatusCodes[statusCode]++
+	counters.Count++
+	counters.LatencySum += latency
+	for i, bound := range METRICS_LATENCY_BUCKETS {
+		if latency.Seconds() <= bound {
+			counters.LatencyBuckets[i]++
+		}
+	}
+}
+
+func (instance *metrics) getSnapshot() EZMQXMetricsSnapshot {
+	instance.mutex.RLock()
+	defer instance.mutex.RUnlock()
+	snapshot := EZMQXMetricsSnapshot{}
+	snapshot.Topics = make(map[string]EZMQXTopicMetrics, len(instance.topics))
+	for topic, counters := range instance.topics {
+		snapshot.Topics[topic] = EZMQXTopicMetrics{
+			MessagesPublished:  atomic.LoadUint64(&counters.messagesPublished),
+			BytesPublished:     atomic.LoadUint64(&counters.bytesPublished),
+			MessagesReceived:   atomic.LoadUint64(&counters.messagesReceived),
+			BytesReceived:      atomic.LoadUint64(&counters.bytesReceived),
+			BrokenPayloads:     atomic.LoadUint64(&counters.brokenPayloads),
+			UnknownTopics:      atomic.LoadUint64(&counters.unknownTopics),
+			LostMessages:       atomic.LoadUint64(&counters.lostMessages),
+			DuplicatedMessages: atomic.LoadUint64(&counters.duplicated),
+			ReorderedMessages:  atomic.LoadUint64(&counters.reordered),
+		}
+	}
+	snapshot.Requests = make([]EZMQXRequestMetrics, 0, len(instance.requests))
+	for _, counters := range instance.requests {
+		request := *counters
+		request.StatusCodes = make(map[int]uint64, len(counters.StatusCodes))
+		for statusCode, count := range counters.StatusCodes {
+			request.StatusCodes[statusCode] = count
+		}
+		request.LatencyBuckets = append([]uint64(nil), counters.LatencyBuckets...)
+		snapshot.Requests = append(snapshot.Requests, request)
+	}
+	sort.Slice(snapshot.Requests, func(i, j int) bool {
+		if snapshot.Requests[i].Path != snapshot.Requests[j].Path {
+			return snapshot.Requests[i].Path < snapshot.Requests[j].Path
+		}
+		return snapshot.Requests[i].Method < snapshot.Requests[j].Method
+	})
+	snapshot.KeepAliveSuccesses = atomic.LoadUint64(&instance.keepAliveSuccesses)
+	snapshot.KeepAliveFailures = atomic.LoadUint64(&instance.keepAliveFailures)
+	return snapshot
+}
+
+// Get snapshot of metrics of the default EZMQX context.
+func GetMetrics() EZMQXMetricsSnapshot {
+	return getContextInstance().GetMetrics()
+}
+
+// Get http.Handler which writes metrics of the default EZMQX context.
+func GetMetricsHandler() http.Handler {
+	return getContextInstance().MetricsHandler()
+}
+
+// Get snapshot of metrics of this context.
+func (cxtInstance *EZMQXContext) GetMetrics() EZMQXMetricsSnapshot {
+	return cxtInstance.metrics.getSnapshot()
+}
+
+// Get http.Handler which writes metrics of this context in Prometheus text format.
+func (cxtInstance *EZMQXContext) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", METRICS_CONTENT_TYPE)
+		writeMetrics(w, cxtInstance.GetMetrics())
+	})
+}
+
+// Write snapshot in Prometheus text format.
+func writeMetrics(w io.Writer, snapshot EZMQXMetricsSnapshot) {
+	topics := make([]string, 0, len(snapshot.Topics))
+	for topic := range snapshot.Topics {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	topicCounters := []struct {
+		name  string
+		help  string
+		value func(counters EZMQXTopicMetrics) uint64
+	}{
+		{"ezmqx_messages_published_total", "Messages published per topic.", func(counters EZMQXTopicMetrics) uint64 { return counters.MessagesPublished }},
+		{"ezmqx_bytes_published_total", "Bytes published per topic.", func(counters EZMQXTopicMetrics) uint64 { return counters.BytesPublished }},
+		{"ezmqx_messages_received_total", "Messages received per topic.", func(counters EZMQXTopicMetrics) uint64 { return counters.MessagesReceived }},
+		{"ezmqx_bytes_received_total", "Bytes received per topic.", func(counters EZMQXTopicMetrics) uint64 { return counters.BytesReceived }},
+		{"ezmqx_broken_payloads_total", "Received messages which could not be decoded per topic.", func(counters EZMQXTopicMetrics) uint64 { return counters.BrokenPayloads }},
+		{"ezmqx_unknown_topics_total", "Received messages dropped as unknown topic per topic.", func(counters EZMQXTopicMetrics) uint64 { return counters.UnknownTopics }},
+		{"ezmqx_messages_lost_total", "Messages missing in sequence per topic.", func(counters EZMQXTopicMetrics) uint64 { return counters.LostMessages }},
+		{"ezmqx_messages_duplicated_total", "Messages received more than once per topic.", func(counters EZMQXTopicMetrics) uint64 { return counters.DuplicatedMessages }},
+		{"ezmqx_messages_reordered_total", "Messages received out of order per topic.", func(counters EZMQXTopicMetrics) uint64 { return counters.ReorderedMessages }},
+	}
+	for _, counter := range topicCounters {
+		writeMetricHeader(w, counter.name, counter.help, "counter")
+		for _, topic := range topics {
+			fmt.Fprintf(w, "%s{topic=\"%s\"} %d\n", counter.name, escapeLabel(topic), counter.value(snapshot.Topics[topic]))
+		}
+	}
+
+	writeMetricHeader(w, "ezmqx_rest_requests_total", "REST requests to TNS and pharos per status code, 0 if no response.", "counter")
+	for _, request := range snapshot.Requests {
+		statusCodes := make([]int, 0, len(request.StatusCodes))
+		for statusCode := range request.StatusCodes {
+			statusCodes = append(statusCodes, statusCode)
+		}
+		sort.Ints(statusCodes)
+		for _, statusCode := range statusCodes {
+			fmt.Fprintf(w, "ezmqx_rest_requests_total{%s,status=\"%d\"} %d\n", requestLabels(request), statusCode, request.StatusCodes[statusCode])
+		}
+	}
+	writeMetricHeader(w, "ezmqx_rest_request_duration_seconds", "Latency of REST requests to TNS and pharos.", "histogram")
+	for _, request := range snapshot.Requests {
+		labels := requestLabels(request)
+		for i, bound := range METRICS_LATENCY_BUCKETS {
+			fmt.Fprintf(w, "ezmqx_rest_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, strconv.FormatFloat(bound, 'g', -1, 64), request.LatencyBuckets[i])
+		}
+		fmt.Fprintf(w, "ezmqx_rest_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, request.Count)
+		fmt.Fprintf(w, "ezmqx_rest_request_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(request.LatencySum.Seconds(), 'g', -1, 64))
+		fmt.Fprintf(w, "ezmqx_rest_request_duration_seconds_count{%s} %d\n", labels, request.Count)
+	}
+
+	writeMetricHeader(w, "ezmqx_keepalive_total", "Keep alive requests to TNS per result.", "counter")
+	fmt.Fprintf(w, "ezmqx_keepalive_total{result=\"success\"} %d\n", snapshot.KeepAliveSuccesses)
+	fmt.Fprintf(w, "ezmqx_keepalive_total{result=\"failure\"} %d\n", snapshot.KeepAliveFailures)
+}
+
+func writeMetricHeader(w io.Writer, name string, help string, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func requestLabels(request EZMQXRequestMetrics) string {
+	return "method=\"" + escapeLabel(request.Method) + "\",path=\"" + escapeLabel(request.Path) + "\""
+}
+
+var labelEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -18,91 +18,125 @@
 package ezmqx
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"go.uber.org/zap"
+	"errors"
 	"go/ezmq"
 	"sync/atomic"
 )
 
+// Callback to get result of background topic registration.
+type EZMQXRegistrationCB func(topic string, err error)
+
+// Options for creating publisher.
+type EZMQXPublisherOptions struct {
+	// If true, publisher is created without waiting for TNS. It publishes
+	// right away and topic is registered to TNS in background, retrying
+	// with the retry policy until TNS is reachable or publisher is terminated.
+	PendingRegistration bool
+	// Called when background registration succeeds or fails with error
+	// which can not be retried. It can be nil.
+	RegistrationCallback EZMQXRegistrationCB
+	// If true, topic registered to TNS by another publisher is unregistered
+	// and registered again with end point of this publisher. It is used to
+	// replace a publisher intentionally, e.g. one that is moved to another host.
+	// Topic published by this process is not taken over.
+	Takeover bool
+	// Optional metadata registered to TNS with the topic.
+	Metadata EZMQXTopicMetadata
+	// Id of publisher written in message header. If empty, a random id is used.
+	PublisherId string
+	// If true, AML payload is published in message envelope with message
+	// header. Subscribers older than the envelope and ezmq plus of other
+	// languages can not decode it, so it is false by default.
+	Envelope bool
+}
+
 type EZMQXPublisher struct {
-	ezmqPublisher *ezmq.EZMQPublisher
-	context       *EZMQXContext
-	topic         *EZMQXTopic
-	topicHandler  *EZMQXTopicHandler
-	localPort     int
-	status        uint32
+	ezmqPublisher  *ezmq.EZMQPublisher
+	context        *EZMQXContext
+	topic          *EZMQXTopic
+	topicHandler   *EZMQXTopicHandler
+	localPort      int
+	registered     atomic.Value
+	registerCancel context.CancelFunc
+	registerDone   chan struct{}
+	status         uint32
 }
 
-func getPublisher() *EZMQXPublisher {
+func getPublisher(context *EZMQXContext) *EZMQXPublisher {
 	var instance *EZMQXPublisher
 	instance = &EZMQXPublisher{}
-	instance.context = getContextInstance()
+	instance.context = context
+	instance.registered.Store(false)
 	instance.status = CREATED
 	return instance
 }
 
-func (instance *EZMQXPublisher) initialize(optionalPort int) EZMQXErrorCode {
+func (instance *EZMQXPublisher) initialize(optionalPort int) error {
 	if !instance.context.isCtxInitialized() {
-		return EZMQX_NOT_INITIALIZED
+		return newError(EZMQX_NOT_INITIALIZED, "create publisher", nil)
 	}
 	if instance.context.isCtxStandAlone() {
 		instance.localPort = optionalPort
 	} else {
-		var error EZMQXErrorCode
-		instance.localPort, error = instance.context.assignDynamicPort()
-		if error != EZMQX_OK {
-			return error
+		var err error
+		instance.localPort, err = instance.context.assignDynamicPort()
+		if err != nil {
+			return err
 		}
 	}
 	// create ezmq publisher
 	instance.ezmqPublisher = ezmq.GetEZMQPublisher(instance.localPort, nil, nil, nil)
 	if nil == instance.ezmqPublisher {
 		Logger.Error("Could not create ezmq publisher")
-		return EZMQX_UNKNOWN_STATE
+		return newError(EZMQX_UNKNOWN_STATE, "create publisher", errors.New("could not create ezmq publisher"))
 	}
 	// Start ezmq publisher
 	if ezmq.EZMQ_OK != instance.ezmqPublisher.Start() {
 		Logger.Error("Could not start ezmq publisher")
-		return EZMQX_UNKNOWN_STATE
+		return newError(EZMQX_UNKNOWN_STATE, "create publisher", errors.New("could not start ezmq publisher"))
 	}
 	// Init topic handler
 	if instance.context.isCtxTnsEnabled() {
-		instance.topicHandler = getTopicHandler()
+		instance.topicHandler = instance.context.getTopicHandler()
 		instance.topicHandler.initHandler()
 		Logger.Debug("Initialized topic handler")
 	}
 	atomic.StoreUint32(&instance.status, INITIALIZED)
-	return EZMQX_OK
+	return nil
 }
 
-func (instance *EZMQXPublisher) parseTopicResponse(response RestResponse) EZMQXErrorCode {
+func (instance *EZMQXPublisher) parseTopicResponse(response RestResponse) error {
 	statusCode := response.GetStatusCode()
-	Logger.Debug("parseTopicResponse ", zap.Int(" Status code: ", statusCode))
+	Logger.Debug("parseTopicResponse ", logField(LOG_FIELD_STATUS, statusCode))
+	if statusCode == HTTP_CONFLICT {
+		Logger.Error("parseTopicResponse, topic is already registered")
+		return newTopicError(EZMQX_DUPLICATED_TOPIC, "register topic", instance.topic.GetName(), &HTTPStatusError{statusCode})
+	}
 	if statusCode != HTTP_CREATED {
 		Logger.Error("parseTopicResponse, status code is not HTTP_CREATED")
-		return EZMQX_REST_ERROR
+		return newTopicError(EZMQX_REST_ERROR, "register topic", instance.topic.GetName(), &HTTPStatusError{statusCode})
 	}
 	data := response.GetResponse()
 	result := make(map[string]int)
 	err := json.Unmarshal([]byte(data), &result)
 	if err != nil {
 		Logger.Error("Unmarshal error")
-		return EZMQX_REST_ERROR
+		return newTopicError(EZMQX_REST_ERROR, "register topic", instance.topic.GetName(), &ResponseParseError{Err: err})
 	}
 	interval, exists := result[PAYLOAD_KEEPALIVE_INTERVAL]
 	if !exists {
 		Logger.Error("No keep alive interval key in json response")
-		return EZMQX_REST_ERROR
+		return newTopicError(EZMQX_REST_ERROR, "register topic", instance.topic.GetName(), &ResponseParseError{Err: errors.New("no keep alive interval key in json response")})
 	}
 	if interval < 1 {
 		Logger.Error("Invalid keepAlive interval")
-		return EZMQX_REST_ERROR
+		return newTopicError(EZMQX_REST_ERROR, "register topic", instance.topic.GetName(), &ResponseParseError{Err: errors.New("invalid keepAlive interval")})
 	}
-	Logger.Debug("Keep alive interval", zap.Int("Interval: ", interval))
+	Logger.Debug("Keep alive interval", logField("interval", interval))
 	topicHandler := instance.topicHandler
-	// fmt.println is used as logger is not supporting for atomic values
-	fmt.Println("[parseTopicResponse] Current Keep Alive interval:", topicHandler.getKeepAliveInterval())
+	Logger.Debug("[parseTopicResponse] Current keep alive interval", logField("interval", topicHandler.getKeepAliveInterval()))
 	if topicHandler.getKeepAliveInterval() < 0 {
 		topicHandler.updateKeepAliveInterval(int64(interval))
 	}
@@ -110,87 +144,201 @@ func (instance *EZMQXPublisher) parseTopicResponse(response RestResponse) EZMQXE
 		result := topicHandler.send(KEEPALIVE, "")
 		if result != EZMQX_OK {
 			Logger.Error("Topic handler send failed")
-			return result
+			return newTopicError(result, "register topic", instance.topic.GetName(), errors.New("topic handler send failed"))
 		}
 	}
-	return EZMQX_OK
+	return nil
+}
+
+// Get JSON payload of TNS register topic request.
+func getRegisterPayload(topic *EZMQXTopic) ([]byte, error) {
+	jsonData := map[string]interface{}{PAYLOAD_NAME: topic.GetName(), PAYLOAD_DATAMODEL: topic.GetDataModel(), PAYLOAD_ENDPOINT: topic.GetEndPoint().ToString(), PAYLOAD_SECURED: topic.IsSecured()}
+	addMetadataPayload(jsonData, topic.GetMetadata())
+	payload := make(map[string]interface{})
+	payload[PAYLOAD_TOPIC] = jsonData
+	return json.Marshal(payload)
 }
 
-func (instance *EZMQXPublisher) registerTopic(topic *EZMQXTopic) EZMQXErrorCode {
+// Parse JSON payload of TNS register topic request.
+func parseRegisterPayload(data []byte) (*EZMQXTopic, error) {
+	payload := make(map[string]map[string]interface{})
+	err := json.Unmarshal(data, &payload)
+	if err != nil {
+		return nil, err
+	}
+	jsonData, exists := payload[PAYLOAD_TOPIC]
+	if !exists {
+		return nil, errors.New("no topic key exists in payload")
+	}
+	name, _ := jsonData[PAYLOAD_NAME].(string)
+	dataModel, _ := jsonData[PAYLOAD_DATAMODEL].(string)
+	endPoint, _ := jsonData[PAYLOAD_ENDPOINT].(string)
+	isSecured, _ := jsonData[PAYLOAD_SECURED].(bool)
+	return GetEZMQXTopicWithMetadata(name, dataModel, isSecured, GetEZMQXEndPoint(endPoint), parseMetadataPayload(jsonData)), nil
+}
+
+func (instance *EZMQXPublisher) setTopic(topic *EZMQXTopic) error {
 	isValid := validateTopic(topic.GetName())
 	if false == isValid {
 		Logger.Error("Topic validation failed")
-		return EZMQX_INVALID_TOPIC
+		return newTopicError(EZMQX_INVALID_TOPIC, "register topic", topic.GetName(), errors.New("topic validation failed"))
+	}
+	err := instance.context.addPublishedTopic(topic.GetName())
+	if err != nil {
+		Logger.Error("Topic is already published")
+		return err
 	}
 	instance.topic = topic
+	return nil
+}
+
+func (instance *EZMQXPublisher) registerTopic(ctx context.Context, topic *EZMQXTopic, options EZMQXPublisherOptions) error {
+	err := instance.setTopic(topic)
+	if err != nil {
+		return err
+	}
 	context := instance.context
 	if !context.isCtxTnsEnabled() {
-		return EZMQX_OK
+		return nil
+	}
+	if options.PendingRegistration {
+		instance.registerInBackground(topic, options)
+		return nil
+	}
+	err = instance.registerToTNS(ctx, topic, context.getRetryPolicy(), options.Takeover)
+	if err != nil {
+		context.removePublishedTopic(topic.GetName())
+	}
+	return err
+}
+
+// Register topic in background until it succeeds or publisher is terminated.
+func (instance *EZMQXPublisher) registerInBackground(topic *EZMQXTopic, options EZMQXPublisherOptions) {
+	ctx, cancel := context.WithCancel(context.Background())
+	instance.registerCancel = cancel
+	instance.registerDone = make(chan struct{})
+	policy := instance.context.getRetryPolicy()
+	policy.MaxAttempts = -1
+	policy.Deadline = 0
+	go func() {
+		defer close(instance.registerDone)
+		err := instance.registerToTNS(ctx, topic, policy, options.Takeover)
+		if nil != ctx.Err() {
+			Logger.Debug("Background registration cancelled", logField(LOG_FIELD_TOPIC, topic.GetName()))
+			return
+		}
+		if err != nil {
+			Logger.Error("Background registration failed", logField(LOG_FIELD_TOPIC, topic.GetName()), logError(err))
+		}
+		if nil != options.RegistrationCallback {
+			options.RegistrationCallback(topic.GetName(), err)
+		}
+	}()
+}
+
+// Stop background registration and wait for it.
+func (instance *EZMQXPublisher) stopRegistration() {
+	if nil == instance.registerCancel {
+		return
 	}
+	instance.registerCancel()
+	<-instance.registerDone
+	instance.registerCancel = nil
+}
+
+func (instance *EZMQXPublisher) registerToTNS(ctx context.Context, topic *EZMQXTopic, policy EZMQXRetryPolicy, takeover bool) error {
+	ezmqxCtx := instance.context
 	// Send post request to TNS server
-	jsonData := map[string]interface{}{PAYLOAD_NAME: topic.GetName(), PAYLOAD_DATAMODEL: topic.GetDataModel(), PAYLOAD_ENDPOINT: topic.GetEndPoint().ToString(), PAYLOAD_SECURED: topic.IsSecured()}
-	payload := make(map[string]interface{})
-	payload[PAYLOAD_TOPIC] = jsonData
-	fmt.Println("TNS register topic payload: \n\n", payload)
-	jsonValue, err := json.Marshal(payload)
+	jsonValue, err := getRegisterPayload(topic)
 	if err != nil {
-		Logger.Error("TNS register topic: Json marshal failed")
-		return EZMQX_REST_ERROR
-	}
-	client := GetRestFactory()
-	topicURL := context.ctxGetTnsAddr() + PREFIX + TOPIC
-	Logger.Debug("[TNS register topic] ", zap.String("Rest URL: ", string(topicURL)))
-	response, error := client.Post(topicURL, jsonValue)
-	if error != EZMQX_OK {
-		Logger.Error("TNS register topic: Post request failed")
-		return EZMQX_REST_ERROR
-	}
-	result := instance.parseTopicResponse(*response)
-	if result != EZMQX_OK {
-		Logger.Error("TNS register topic: Parse response failed")
-		return result
+		Logger.Error("TNS register topic: Json marshal failed", logField(LOG_FIELD_TOPIC, topic.GetName()))
+		return newTopicError(EZMQX_REST_ERROR, "register topic", topic.GetName(), err)
 	}
+	Logger.Debug("TNS register topic payload", logField(LOG_FIELD_TOPIC, topic.GetName()), logField("payload", string(jsonValue)))
+	client := ezmqxCtx.GetRestFactory()
+	postTopic := func(ctx context.Context, tnsAddr string) error {
+		topicURL := tnsAddr + PREFIX + TOPIC
+		Logger.Debug("[TNS register topic] ", logField(LOG_FIELD_URL, string(topicURL)))
+		response, err := client.PostContext(ctx, topicURL, jsonValue)
+		if err != nil {
+			Logger.Error("TNS register topic: Post request failed")
+			return newTopicError(EZMQX_REST_ERROR, "register topic", topic.GetName(), err)
+		}
+		return instance.parseTopicResponse(*response)
+	}
+	err = ezmqxCtx.tnsRequest(ctx, policy, postTopic)
+	if takeover && EZMQX_DUPLICATED_TOPIC == ErrorCodeOf(err) {
+		Logger.Debug("TNS register topic: taking over topic", logField(LOG_FIELD_TOPIC, topic.GetName()))
+		err = instance.deleteTopic(ctx, topic.GetName(), policy)
+		if err == nil {
+			err = ezmqxCtx.tnsRequest(ctx, policy, postTopic)
+		}
+	}
+	if err != nil {
+		Logger.Error("TNS register topic: failed")
+		return err
+	}
+	ezmqxCtx.invalidateDiscoveryCache(topic.GetName())
 	//send a request to topic handler to add topic to topic list
-	result = getTopicHandler().send(REGISTER, topic.GetName())
+	//whole topic is sent, so that topic handler can register it again
+	result := instance.topicHandler.send(REGISTER, string(jsonValue))
 	if result != EZMQX_OK {
 		Logger.Error("Topic handler send failed")
-		return result
+		return newTopicError(result, "register topic", topic.GetName(), errors.New("topic handler send failed"))
 	}
-	Logger.Debug("Sent request to topic handler to add topic to list: ", zap.String("Topic: ", topic.GetName()))
-	return EZMQX_OK
+	instance.registered.Store(true)
+	Logger.Debug("Sent request to topic handler to add topic to list: ", logField(LOG_FIELD_TOPIC, topic.GetName()))
+	return nil
 }
 
-func (instance *EZMQXPublisher) unRegisterTopic(topic *EZMQXTopic) EZMQXErrorCode {
-	context := instance.context
-	if !context.isCtxTnsEnabled() {
-		return EZMQX_OK
+func (instance *EZMQXPublisher) unRegisterTopic(ctx context.Context, topic *EZMQXTopic) error {
+	ezmqxCtx := instance.context
+	if !ezmqxCtx.isCtxTnsEnabled() {
+		return nil
 	}
-	topicURL := context.ctxGetTnsAddr() + PREFIX + TOPIC
-	query := QUERY_NAME + topic.GetName()
-	Logger.Debug("[TNS unregister topic]", zap.String("Rest URL: ", string(topicURL)))
-	Logger.Debug("[TNS unregister topic]", zap.String("Query: ", string(query)))
-
-	client := GetRestFactory()
-	response, _ := client.Delete(topicURL+QUESTION_MARK+query, nil)
-	Logger.Debug("[TNS unregister topic]", zap.Int("Status: ", response.GetStatusCode()))
-	if response.GetStatusCode() != HTTP_OK {
-		return EZMQX_REST_ERROR
+	err := instance.deleteTopic(ctx, topic.GetName(), ezmqxCtx.getRetryPolicy())
+	if err != nil {
+		return err
 	}
 
 	//send request to topic handler to remove from topic list
-	result := getTopicHandler().send(UNREGISTER, topic.GetName())
+	result := instance.topicHandler.send(UNREGISTER, topic.GetName())
 	if result != EZMQX_OK {
 		Logger.Error("Topic handler send failed")
-		return result
+		return newTopicError(result, "unregister topic", topic.GetName(), errors.New("topic handler send failed"))
 	}
-	Logger.Debug("Sent request to topic handler to remove topic from list: ", zap.String("Topic: ", topic.GetName()))
-	return EZMQX_OK
+	Logger.Debug("Sent request to topic handler to remove topic from list: ", logField(LOG_FIELD_TOPIC, topic.GetName()))
+	return nil
 }
 
-func (instance *EZMQXPublisher) terminate() EZMQXErrorCode {
+// Send delete request of the given topic to TNS server.
+func (instance *EZMQXPublisher) deleteTopic(ctx context.Context, topic string, policy EZMQXRetryPolicy) error {
+	ezmqxCtx := instance.context
+	query := QUERY_NAME + topic
+	Logger.Debug("[TNS unregister topic]", logField("query", string(query)))
+
+	client := ezmqxCtx.GetRestFactory()
+	defer ezmqxCtx.invalidateDiscoveryCache(topic)
+	return ezmqxCtx.tnsRequest(ctx, policy, func(ctx context.Context, tnsAddr string) error {
+		topicURL := tnsAddr + PREFIX + TOPIC
+		Logger.Debug("[TNS unregister topic]", logField(LOG_FIELD_URL, string(topicURL)))
+		response, err := client.DeleteContext(ctx, topicURL+QUESTION_MARK+query, nil)
+		if err != nil {
+			Logger.Error("[TNS unregister topic] Delete request failed")
+			return newTopicError(EZMQX_REST_ERROR, "unregister topic", topic, err)
+		}
+		Logger.Debug("[TNS unregister topic]", logField(LOG_FIELD_STATUS, response.GetStatusCode()))
+		if response.GetStatusCode() != HTTP_OK {
+			return newTopicError(EZMQX_REST_ERROR, "unregister topic", topic, &HTTPStatusError{response.GetStatusCode()})
+		}
+		return nil
+	})
+}
+
+func (instance *EZMQXPublisher) terminate(ctx context.Context) error {
 	if false == atomic.CompareAndSwapUint32(&instance.status, INITIALIZED, TERMINATING) {
 		Logger.Error("terminate failed : Not initialized")
-		return EZMQX_UNKNOWN_STATE
+		return newError(EZMQX_UNKNOWN_STATE, "terminate publisher", errInvalidState)
 	}
 	context := instance.context
 	if !context.isCtxStandAlone() {
@@ -201,25 +349,28 @@ func (instance *EZMQXPublisher) terminate() EZMQXErrorCode {
 			Logger.Debug("Released local port")
 		}
 	}
-	if context.isCtxTnsEnabled() {
-		result := instance.unRegisterTopic(instance.topic)
-		if result != EZMQX_OK {
+	instance.stopRegistration()
+	if context.isCtxTnsEnabled() && true == instance.registered.Load() {
+		result := instance.unRegisterTopic(ctx, instance.topic)
+		if result != nil {
 			Logger.Error("Unregister topic: failed")
 		} else {
+			instance.registered.Store(false)
 			Logger.Debug("Unregistered topic on TNS")
 		}
 	}
+	context.removePublishedTopic(instance.topic.GetName())
 	if nil != instance.ezmqPublisher {
 		result := instance.ezmqPublisher.Stop()
 		if result != EZMQX_OK {
 			Logger.Error("Stop EZMQ publisher: failed")
 			atomic.StoreUint32(&instance.status, INITIALIZED)
-			return EZMQX_UNKNOWN_STATE
+			return newError(EZMQX_UNKNOWN_STATE, "terminate publisher", errors.New("stop EZMQ publisher failed"))
 		}
 		Logger.Debug("Stopped EZMQ publisher")
 	}
 	atomic.StoreUint32(&instance.status, CREATED)
-	return EZMQX_OK
+	return nil
 }
 
 func (instance *EZMQXPublisher) isTerminated() bool {
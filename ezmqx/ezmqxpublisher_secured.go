@@ -1,47 +1,49 @@
+//go:build !unsecure
 // +build !unsecure
 
 package ezmqx
 
 import (
+	"errors"
 	"go/ezmq"
 	"sync/atomic"
 )
 
-func (instance *EZMQXPublisher) initializeSecured(optionalPort int, serverPrivateKey string) EZMQXErrorCode {
+func (instance *EZMQXPublisher) initializeSecured(optionalPort int, serverPrivateKey string) error {
 	if !instance.context.isCtxInitialized() {
-		return EZMQX_NOT_INITIALIZED
+		return newError(EZMQX_NOT_INITIALIZED, "create publisher", nil)
 	}
 	if instance.context.isCtxStandAlone() {
 		instance.localPort = optionalPort
 	} else {
-		var error EZMQXErrorCode
-		instance.localPort, error = instance.context.assignDynamicPort()
-		if error != EZMQX_OK {
-			return error
+		var err error
+		instance.localPort, err = instance.context.assignDynamicPort()
+		if err != nil {
+			return err
 		}
 	}
 	// create ezmq publisher
 	instance.ezmqPublisher = ezmq.GetEZMQPublisher(instance.localPort, nil, nil, nil)
 	if nil == instance.ezmqPublisher {
 		Logger.Error("Could not create ezmq publisher")
-		return EZMQX_UNKNOWN_STATE
+		return newError(EZMQX_UNKNOWN_STATE, "create publisher", errors.New("could not create ezmq publisher"))
 	}
 	//Set server key
 	result := instance.ezmqPublisher.SetServerPrivateKey([]byte(serverPrivateKey))
 	if result != ezmq.EZMQ_OK {
-		return EZMQX_INVALID_PARAM
+		return newError(EZMQX_INVALID_PARAM, "create publisher", errors.New("invalid server private key"))
 	}
 	// Start ezmq publisher
 	if ezmq.EZMQ_OK != instance.ezmqPublisher.Start() {
 		Logger.Error("Could not start ezmq publisher")
-		return EZMQX_UNKNOWN_STATE
+		return newError(EZMQX_UNKNOWN_STATE, "create publisher", errors.New("could not start ezmq publisher"))
 	}
 	// Init topic handler
 	if instance.context.isCtxTnsEnabled() {
-		instance.topicHandler = getTopicHandler()
+		instance.topicHandler = instance.context.getTopicHandler()
 		instance.topicHandler.initHandler()
 		Logger.Debug("Initialized topic handler")
 	}
 	atomic.StoreUint32(&instance.status, INITIALIZED)
-	return EZMQX_OK
+	return nil
 }
@@ -0,0 +1,160 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Options for automatic reconnect of subscriber.
+//
+// ResolveInterval is the period of TNS query for subscribed topics, if it is 0
+// then topics are resolved only after silence.
+// SilenceTimeout resolves subscribed topics when no message is received for the
+// given duration, if it is 0 then silence is not checked.
+// At least one of them should be set.
+type EZMQXReconnectOptions struct {
+	ResolveInterval time.Duration
+	SilenceTimeout  time.Duration
+}
+
+func validateReconnectOptions(options EZMQXReconnectOptions) error {
+	if options.ResolveInterval < 0 || options.SilenceTimeout < 0 {
+		return newError(EZMQX_INVALID_PARAM, "reconnect", errors.New("invalid reconnect interval"))
+	}
+	if 0 == options.ResolveInterval && 0 == options.SilenceTimeout {
+		return newError(EZMQX_INVALID_PARAM, "reconnect", errors.New("neither resolve interval nor silence timeout is set"))
+	}
+	return nil
+}
+
+// Start routine which resolves subscribed topics through TNS and moves them
+// to their new end point.
+func (instance *EZMQXSubscriber) startReconnect(options EZMQXReconnectOptions) error {
+	result := validateReconnectOptions(options)
+	if result != nil {
+		return result
+	}
+	if !instance.context.isCtxTnsEnabled() {
+		Logger.Error("TNS is not enabled")
+		return newError(EZMQX_TNS_NOT_AVAILABLE, "reconnect", errors.New("TNS is not enabled"))
+	}
+	if atomic.LoadUint32(&instance.status) != INITIALIZED {
+		return newError(EZMQX_TERMINATED, "reconnect", errInvalidState)
+	}
+	checkInterval := options.ResolveInterval
+	if 0 == checkInterval || (options.SilenceTimeout > 0 && options.SilenceTimeout < checkInterval) {
+		checkInterval = options.SilenceTimeout
+	}
+	lastResolved := time.Now()
+	instance.startPeriodicRoutine("Reconnect", checkInterval,
+		func(ctx context.Context, now time.Time) (*topicChanges, error) {
+			resolve := options.ResolveInterval > 0 && now.Sub(lastResolved) >= options.ResolveInterval
+			if !resolve && options.SilenceTimeout > 0 {
+				lastReceived := time.Unix(0, atomic.LoadInt64(&instance.lastReceived))
+				resolve = now.Sub(lastReceived) >= options.SilenceTimeout && now.Sub(lastResolved) >= options.SilenceTimeout
+			}
+			if !resolve {
+				return nil, nil
+			}
+			lastResolved = now
+			reconnected, err := instance.resolveTopics(ctx)
+			return &topicChanges{added: list.New(), removed: list.New(), reconnected: reconnected}, err
+		}, nil, nil)
+	return nil
+}
+
+// Query subscribed topics from TNS and move them, if their end point is changed.
+// Topics are queried hierarchically with one request per common prefix.
+// Topics which are not found in TNS are kept as they are, as publisher may be restarting.
+func (instance *EZMQXSubscriber) resolveTopics(ctx context.Context) (*list.List, error) {
+	reconnected := list.New()
+	var err error
+	for prefix, names := range groupTopicsByPrefix(instance.getTopicNames()) {
+		verified, result := instance.verifyTopics(ctx, prefix, true, false)
+		if result != nil {
+			if ctx.Err() != nil {
+				return reconnected, result
+			}
+			Logger.Error("[Reconnect] query topics failed", logField(LOG_FIELD_TOPIC, prefix), logError(result))
+			err = result
+			continue
+		}
+		subscribed := make(map[string]bool)
+		for _, name := range names {
+			subscribed[name] = true
+		}
+		for element := verified.Front(); element != nil; element = element.Next() {
+			ezmqxTopic := element.Value.(EZMQXTopic)
+			if !subscribed[ezmqxTopic.GetName()] {
+				continue
+			}
+			moved, result := instance.updateEndPoint(ezmqxTopic)
+			if result != nil {
+				Logger.Error("[Reconnect] reconnect failed", logField(LOG_FIELD_TOPIC, ezmqxTopic.GetName()), logError(result))
+				err = result
+			} else if moved {
+				reconnected.PushBack(ezmqxTopic)
+			}
+		}
+	}
+	return reconnected, err
+}
+
+func (instance *EZMQXSubscriber) updateEndPoint(topic EZMQXTopic) (bool, error) {
+	instance.topicMutex.Lock()
+	defer instance.topicMutex.Unlock()
+	if atomic.LoadUint32(&instance.status) != INITIALIZED {
+		return false, newTopicError(EZMQX_TERMINATED, "reconnect", topic.GetName(), errInvalidState)
+	}
+	return instance.updateEndPointLocked(topic)
+}
+
+// Move subscribed topic to the end point of the given topic.
+// It returns false, if topic is not subscribed or end point is not changed.
+func (instance *EZMQXSubscriber) updateEndPointLocked(topic EZMQXTopic) (bool, error) {
+	element := instance.findTopic(topic.GetName())
+	if nil == element {
+		return false, nil
+	}
+	stored := element.Value.(EZMQXTopic)
+	if stored.GetEndPoint().ToString() == topic.GetEndPoint().ToString() {
+		return false, nil
+	}
+	Logger.Debug("End point is changed", logField(LOG_FIELD_TOPIC, topic.GetName()),
+		logField("from", stored.GetEndPoint().ToString()), logField("to", topic.GetEndPoint().ToString()))
+	result := instance.removeTopicLocked(topic.GetName())
+	if result != nil {
+		return false, result
+	}
+	var topicList list.List
+	topicList.PushBack(topic)
+	result = instance.storeTopicsLocked(topicList)
+	if result != nil {
+		// Keep subscribing on the old end point
+		var storedList list.List
+		storedList.PushBack(stored)
+		instance.storeTopicsLocked(storedList)
+		return false, result
+	}
+	return true, nil
+}
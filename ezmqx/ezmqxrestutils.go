@@ -27,10 +27,18 @@ const TNS_KNOWN_PORT = "48323"
 const TOPIC = "/tns/topic"
 const TNS_KEEP_ALIVE = "/tns/keepalive"
 const HTTP_PREFIX = "http://"
+const HTTPS_PREFIX = "https://"
 const QUERY_NAME = "name="
 const QUERY_HIERARCHICAL = "&hierarchical="
 const QUERY_TRUE = "yes"
 const QUERY_FALSE = "no"
+const QUERY_DATAMODEL = "&datamodel="
+const QUERY_SECURED = "&secured="
+const QUERY_HOST = "&host="
+const QUERY_TAG = "&tag="
+const TAG_SEPARATOR = ":"
+const QUERY_OFFSET = "&offset="
+const QUERY_LIMIT = "&limit="
 const REVERSE_PROXY_KNOWN_PORT = "80"
 const API_SEARCH_NODE = "/search/nodes"
 const REVERSE_PROXY_PREFIX = "/tns-server"
@@ -55,10 +63,16 @@ const PORTS_PUBLIC = "PublicPort"
 const PAYLOAD_OPTION = "indentation"
 const PAYLOAD_TOPIC = "topic"
 const PAYLOAD_TOPICS = "topics"
+const PAYLOAD_TOTAL = "total"
 const PAYLOAD_NAME = "name"
 const PAYLOAD_ENDPOINT = "endpoint"
 const PAYLOAD_DATAMODEL = "datamodel"
 const PAYLOAD_SECURED = "secured"
+const PAYLOAD_DESCRIPTION = "description"
+const PAYLOAD_TAGS = "tags"
+const PAYLOAD_OWNER = "owner"
+const PAYLOAD_SCHEMA_VERSION = "schema_version"
+const PAYLOAD_RATE = "rate"
 const PAYLOAD_KEEPALIVE_INTERVAL = "ka_interval"
 const PAYLOAD_TOPIC_KA = "topic_names"
 const CONF_REVERSE_PROXY = "reverseproxy"
@@ -79,6 +93,10 @@ const HOST_NAME_FILE_PATH = "/etc/hostname"
 // HTTP status codes
 const HTTP_OK = 200
 const HTTP_CREATED = 201
+const HTTP_NOT_FOUND = 404
+const HTTP_REQUEST_TIMEOUT = 408
+const HTTP_CONFLICT = 409
+const HTTP_TOO_MANY_REQUESTS = 429
 const CONNECTION_TIMEOUT = 5
 
 // Strings
@@ -91,3 +109,6 @@ const UNREGISTER = "unregister"
 const KEEPALIVE = "keepalive"
 const SHUTDOWN = "shutdown"
 const APPLICATION_JSON = "application/json"
+const HEADER_AUTHORIZATION = "Authorization"
+const AUTH_BEARER = "Bearer "
+const AUTH_BASIC = "Basic "
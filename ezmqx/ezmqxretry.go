@@ -0,0 +1,140 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+)
+
+const DEFAULT_INITIAL_BACKOFF = 500 * time.Millisecond
+const DEFAULT_MAX_BACKOFF = 30 * time.Second
+const DEFAULT_BACKOFF_MULTIPLIER = 2.0
+
+// Retry policy of TNS register, unregister and query requests.
+//
+// Zero value sends a single request without retry.
+// Only transport errors and 408, 429 and 5xx status codes are retried.
+type EZMQXRetryPolicy struct {
+	// Maximum number of attempts. 0 means a single attempt and negative
+	// value means retry until Deadline passes or request context is done.
+	MaxAttempts int
+	// Backoff before the second attempt. If 0, DEFAULT_INITIAL_BACKOFF is used.
+	InitialBackoff time.Duration
+	// Upper bound of backoff. If 0, DEFAULT_MAX_BACKOFF is used.
+	MaxBackoff time.Duration
+	// Backoff is multiplied by it after every attempt.
+	// If 0, DEFAULT_BACKOFF_MULTIPLIER is used.
+	Multiplier float64
+	// Fraction of backoff, in range [0, 1], which is randomly added to or
+	// subtracted from every backoff.
+	Jitter float64
+	// Overall time limit of all the attempts. 0 means no limit.
+	Deadline time.Duration
+}
+
+func validateRetryPolicy(policy *EZMQXRetryPolicy) error {
+	if policy.InitialBackoff < 0 || policy.MaxBackoff < 0 || policy.Deadline < 0 {
+		return newError(EZMQX_INVALID_PARAM, "set retry policy", errors.New("negative duration"))
+	}
+	if policy.Multiplier != 0 && policy.Multiplier < 1 {
+		return newError(EZMQX_INVALID_PARAM, "set retry policy", errors.New("multiplier should not be less than 1"))
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return newError(EZMQX_INVALID_PARAM, "set retry policy", errors.New("jitter should be in range [0, 1]"))
+	}
+	if 0 == policy.InitialBackoff {
+		policy.InitialBackoff = DEFAULT_INITIAL_BACKOFF
+	}
+	if 0 == policy.MaxBackoff {
+		policy.MaxBackoff = DEFAULT_MAX_BACKOFF
+	}
+	if 0 == policy.Multiplier {
+		policy.Multiplier = DEFAULT_BACKOFF_MULTIPLIER
+	}
+	return nil
+}
+
+// Get backoff after the given attempt, which starts from 1.
+func (policy EZMQXRetryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(policy.InitialBackoff)
+	for i := 1; i < attempt && backoff < float64(policy.MaxBackoff); i++ {
+		backoff *= policy.Multiplier
+	}
+	if backoff > float64(policy.MaxBackoff) {
+		backoff = float64(policy.MaxBackoff)
+	}
+	if policy.Jitter > 0 {
+		backoff += backoff * policy.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(backoff)
+}
+
+func (policy EZMQXRetryPolicy) isLastAttempt(attempt int) bool {
+	if policy.MaxAttempts < 0 {
+		return false
+	}
+	return attempt >= policy.MaxAttempts
+}
+
+// Check whether failed TNS request can be retried.
+// Only transport failures and 408, 429 and 5xx status codes are retried, as
+// other failures like invalid response fail again with the same response.
+func isRetryable(err error) bool {
+	var statusError *HTTPStatusError
+	if errors.As(err, &statusError) {
+		statusCode := statusError.StatusCode
+		return statusCode >= 500 || HTTP_REQUEST_TIMEOUT == statusCode || HTTP_TOO_MANY_REQUESTS == statusCode
+	}
+	var parseError *ResponseParseError
+	if errors.As(err, &parseError) {
+		return false
+	}
+	var netError net.Error
+	return errors.As(err, &netError) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, errRequestFailed)
+}
+
+// Send request until it succeeds or fails with error that can not be retried.
+// Last error is returned, when attempts are exhausted or ctx is done.
+func retryRequest(ctx context.Context, policy EZMQXRetryPolicy, request func(ctx context.Context) error) error {
+	if policy.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Deadline)
+		defer cancel()
+	}
+	for attempt := 1; ; attempt++ {
+		err := request(ctx)
+		if nil == err || !isRetryable(err) || policy.isLastAttempt(attempt) || nil != ctx.Err() {
+			return err
+		}
+		backoff := policy.backoff(attempt)
+		Logger.Debug("[TNS request] retrying", logField("attempt", attempt), logField("backoff", backoff), logError(err))
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
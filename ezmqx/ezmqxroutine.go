@@ -0,0 +1,160 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// Topics changed by a background routine of subscriber.
+type topicChanges struct {
+	added       *list.List
+	removed     *list.List
+	reconnected *list.List
+}
+
+// Task of periodic routine, it is run on every tick of the routine.
+// Changes are returned even on failure, as some topics may have been changed.
+type routineTask func(ctx context.Context, now time.Time) (*topicChanges, error)
+
+// Start background routine of subscriber.
+// All the routines are cancelled and waited when subscriber is terminated.
+func (instance *EZMQXSubscriber) startRoutine(routine func(ctx context.Context)) {
+	instance.topicMutex.Lock()
+	defer instance.topicMutex.Unlock()
+	if nil == instance.routineCancel {
+		var ctx context.Context
+		ctx, instance.routineCancel = context.WithCancel(context.Background())
+		instance.routineCtx = ctx
+	}
+	ctx := instance.routineCtx
+	instance.routineGroup.Add(1)
+	go func() {
+		defer instance.routineGroup.Done()
+		routine(ctx)
+	}()
+}
+
+// Start background routine which runs task on every interval.
+// Changes made by task are reported on changeCallback and error callback.
+// Failure of task is logged and passed to errorHandler, if it is not nil.
+func (instance *EZMQXSubscriber) startPeriodicRoutine(name string, interval time.Duration, task routineTask,
+	errorHandler func(err error), changeCallback EZMQXTopicChangeCB) {
+	instance.startRoutine(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				changes, err := task(ctx, now)
+				if err != nil && ctx.Err() != nil {
+					return
+				}
+				routineId := getGoroutineId()
+				instance.setReportRoutine(routineId, true)
+				if err != nil {
+					Logger.Error("["+name+"] failed", logError(err))
+					if nil != errorHandler {
+						errorHandler(err)
+					}
+				}
+				instance.reportTopicChanges(changeCallback, changes)
+				instance.setReportRoutine(routineId, false)
+			}
+		}
+	})
+}
+
+// Stop background routines and wait until they are finished.
+// Routines are not waited, if subscriber is terminated from a callback of the
+// routine itself. Routines finish after the callback returns in that case.
+func (instance *EZMQXSubscriber) stopRoutines() {
+	instance.topicMutex.Lock()
+	cancel := instance.routineCancel
+	instance.routineCancel = nil
+	instance.routineCtx = nil
+	isReportRoutine := instance.reportRoutines[getGoroutineId()]
+	instance.topicMutex.Unlock()
+	if nil == cancel {
+		return
+	}
+	cancel()
+	if isReportRoutine {
+		return
+	}
+	instance.routineGroup.Wait()
+}
+
+// Mark goroutine of routine, while it is running callbacks.
+func (instance *EZMQXSubscriber) setReportRoutine(routineId uint64, isReporting bool) {
+	instance.topicMutex.Lock()
+	defer instance.topicMutex.Unlock()
+	if isReporting {
+		instance.reportRoutines[routineId] = true
+	} else {
+		delete(instance.reportRoutines, routineId)
+	}
+}
+
+// Get id of current goroutine from its stack trace header "goroutine <id> [...]".
+func getGoroutineId() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if index := bytes.IndexByte(buf, ' '); index >= 0 {
+		buf = buf[:index]
+	}
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
+
+// Report changes of topics on topic change callback, if it is not nil, and
+// every moved topic on error callback with EZMQX_RECONNECTED.
+func (instance *EZMQXSubscriber) reportTopicChanges(callback EZMQXTopicChangeCB, changes *topicChanges) {
+	if nil == changes {
+		return
+	}
+	if nil != callback {
+		for element := changes.removed.Front(); element != nil; element = element.Next() {
+			callback(TOPIC_REMOVED, element.Value.(EZMQXTopic))
+		}
+		for element := changes.added.Front(); element != nil; element = element.Next() {
+			callback(TOPIC_ADDED, element.Value.(EZMQXTopic))
+		}
+	}
+	for element := changes.reconnected.Front(); element != nil; element = element.Next() {
+		ezmqxTopic := element.Value.(EZMQXTopic)
+		instance.reportError(ezmqxTopic.GetName(), EZMQX_RECONNECTED)
+	}
+}
+
+// Report error of background routine on error callback.
+// It is not serialized with callbacks of end points, which may be running.
+func (instance *EZMQXSubscriber) reportError(topic string, errorCode EZMQXErrorCode) {
+	if nil == instance.errorCB {
+		return
+	}
+	instance.errorCB(topic, errorCode)
+}
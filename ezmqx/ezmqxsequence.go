@@ -0,0 +1,155 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"sort"
+	"sync"
+)
+
+// Number of sequence numbers before the latest one which are remembered to
+// tell late arrivals from duplicates. Older sequence number, or sequence number 1,
+// means that publisher is restarted with the same publisher id.
+const SEQUENCE_WINDOW_SIZE = 64
+
+// Sequence counters of messages from a publisher on a topic.
+type EZMQXSequenceStats struct {
+	Topic       string
+	PublisherId string
+	// Messages received with message header.
+	Received uint64
+	// Messages missing in sequence. Messages which arrived later out of
+	// order are not counted.
+	Lost uint64
+	// Messages received more than once.
+	Duplicated uint64
+	// Messages received after a later message.
+	Reordered uint64
+}
+
+type sequenceKey struct {
+	topic       string
+	publisherId string
+}
+
+type sequenceState struct {
+	stats EZMQXSequenceStats
+	last  uint64
+	// Bit i is set if message last-i is received.
+	received uint64
+	// Bit i is set if message last-i is counted as lost.
+	missing uint64
+}
+
+// Tracker of the last sequence number per topic and publisher.
+type sequenceTracker struct {
+	states map[sequenceKey]*sequenceState
+	mutex  *sync.Mutex
+}
+
+func newSequenceTracker() *sequenceTracker {
+	var instance *sequenceTracker
+	instance = &sequenceTracker{}
+	instance.states = make(map[sequenceKey]*sequenceState)
+	instance.mutex = &sync.Mutex{}
+	return instance
+}
+
+// Check sequence number of received message.
+// It returns EZMQX_MESSAGE_LOSS, EZMQX_MESSAGE_DUPLICATED or EZMQX_MESSAGE_REORDERED
+// with the number of affected messages, or EZMQX_OK.
+func (instance *sequenceTracker) check(topic string, header *EZMQXMessageHeader) (EZMQXErrorCode, uint64) {
+	if nil == header {
+		return EZMQX_OK, 0
+	}
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	key := sequenceKey{topic: topic, publisherId: header.PublisherId}
+	state, exists := instance.states[key]
+	sequence := header.Sequence
+	if !exists {
+		state = &sequenceState{stats: EZMQXSequenceStats{Topic: topic, PublisherId: header.PublisherId}}
+		instance.states[key] = state
+	}
+	state.stats.Received++
+	if !exists || 1 == sequence || sequence+SEQUENCE_WINDOW_SIZE <= state.last {
+		// Messages before the first one are missed by slow join, not lost.
+		state.last = sequence
+		state.received = 1
+		state.missing = 0
+		return EZMQX_OK, 0
+	}
+	if sequence > state.last {
+		shift := sequence - state.last
+		lost := shift - 1
+		if shift >= SEQUENCE_WINDOW_SIZE {
+			state.received = 0
+			state.missing = ^uint64(0)
+		} else {
+			state.received <<= shift
+			state.missing = state.missing<<shift | (uint64(1)<<shift - 2)
+		}
+		state.received |= 1
+		state.missing &^= 1
+		state.last = sequence
+		if lost > 0 {
+			state.stats.Lost += lost
+			return EZMQX_MESSAGE_LOSS, lost
+		}
+		return EZMQX_OK, 0
+	}
+	bit := uint64(1) << (state.last - sequence)
+	if 0 != state.received&bit {
+		state.stats.Duplicated++
+		return EZMQX_MESSAGE_DUPLICATED, 1
+	}
+	state.received |= bit
+	state.stats.Reordered++
+	if 0 != state.missing&bit {
+		state.missing &^= bit
+		state.stats.Lost--
+	}
+	return EZMQX_MESSAGE_REORDERED, 1
+}
+
+// Forget sequence numbers of topic, e.g. when it is unsubscribed.
+func (instance *sequenceTracker) removeTopic(topic string) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	for key := range instance.states {
+		if key.topic == topic {
+			delete(instance.states, key)
+		}
+	}
+}
+
+func (instance *sequenceTracker) getStats() []EZMQXSequenceStats {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	stats := make([]EZMQXSequenceStats, 0, len(instance.states))
+	for _, state := range instance.states {
+		stats = append(stats, state.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Topic != stats[j].Topic {
+			return stats[i].Topic < stats[j].Topic
+		}
+		return stats[i].PublisherId < stats[j].PublisherId
+	})
+	return stats
+}
@@ -19,227 +19,450 @@ package ezmqx
 
 import (
 	"container/list"
+	"context"
 	"encoding/json"
-	"fmt"
-	"go.uber.org/zap"
+	"errors"
 	"go/aml"
 	"go/ezmq"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type EZMQXSubCB func(topic string, ezmqMsg ezmq.EZMQMessage)
 
 type EZMQXSubscriber struct {
-	ezmqSubscriber *ezmq.EZMQSubscriber
-	context        *EZMQXContext
-	storedTopics   *list.List
-	amlRepDic      map[string]*aml.Representation
-	status         uint32
-	internalCB     EZMQXSubCB
+	// Accessed atomically, kept first for 64-bit alignment.
+	lastReceived    int64
+	ezmqSubscribers map[string]*ezmq.EZMQSubscriber
+	context         *EZMQXContext
+	storedTopics    *list.List
+	amlRepDic       map[string]*aml.Representation
+	status          uint32
+	internalCB      EZMQXSubCB
+	errorCB         func(topic string, errorCode EZMQXErrorCode)
+	routineCtx      context.Context
+	routineCancel   context.CancelFunc
+	routineGroup    *sync.WaitGroup
+	reportRoutines  map[uint64]bool // Goroutines of routines running callbacks.
+	topicMutex      *sync.Mutex
+	repMutex        *sync.RWMutex
+	sequences       *sequenceTracker
+	latency         *latencyTracker
 }
 
-func getEZMQXSubscriber() *EZMQXSubscriber {
+func getEZMQXSubscriber(context *EZMQXContext) *EZMQXSubscriber {
 	var instance *EZMQXSubscriber
 	instance = &EZMQXSubscriber{}
-	instance.context = getContextInstance()
+	instance.context = context
 	instance.storedTopics = list.New()
 	instance.amlRepDic = make(map[string]*aml.Representation)
-	instance.ezmqSubscriber = nil
+	instance.ezmqSubscribers = make(map[string]*ezmq.EZMQSubscriber)
+	instance.topicMutex = &sync.Mutex{}
+	instance.repMutex = &sync.RWMutex{}
+	instance.sequences = newSequenceTracker()
+	instance.latency = newLatencyTracker()
+	instance.routineGroup = &sync.WaitGroup{}
+	instance.reportRoutines = make(map[uint64]bool)
 	instance.status = CREATED
 	return instance
 }
 
-func (instance *EZMQXSubscriber) initialize(topic string, isHierarchical bool) EZMQXErrorCode {
+// Validate watch and channel options of subscriber and fill their default values.
+// Callbacks are required without channel, and not allowed with channel.
+// Options are copied, so that given options are not changed.
+func validateSubscriberOptions(watch *EZMQXWatchOptions, channel *EZMQXChannelOptions, hasSubCallback bool, hasErrorCallback bool, isStandAlone bool) (*EZMQXWatchOptions, *EZMQXChannelOptions, error) {
+	if nil != watch {
+		if isStandAlone {
+			return nil, nil, newError(EZMQX_INVALID_PARAM, "create subscriber", errors.New("watch is not available in standalone mode"))
+		}
+		watchOptions := *watch
+		if err := validateWatchOptions(&watchOptions); err != nil {
+			return nil, nil, err
+		}
+		watch = &watchOptions
+	}
+	if nil == channel {
+		if !hasSubCallback || !hasErrorCallback {
+			return nil, nil, newError(EZMQX_INVALID_PARAM, "create subscriber", errors.New("callback is nil"))
+		}
+		return watch, nil, nil
+	}
+	if hasSubCallback || hasErrorCallback {
+		return nil, nil, newError(EZMQX_INVALID_PARAM, "create subscriber", errors.New("callback is set with channel"))
+	}
+	channelOptions := *channel
+	if err := validateChannelOptions(&channelOptions); err != nil {
+		return nil, nil, err
+	}
+	return watch, &channelOptions, nil
+}
+
+func (instance *EZMQXSubscriber) initialize(ctx context.Context, topic string, isHierarchical bool) error {
+	verified, err := instance.queryTopics(ctx, topic, isHierarchical, true)
+	if err != nil {
+		return err
+	}
+	return instance.storeTopics(*verified)
+}
+
+// Query topics from TNS and subscribe them on a live subscriber.
+func (instance *EZMQXSubscriber) subscribeTopics(ctx context.Context, topic string, isHierarchical bool) error {
+	verified, err := instance.queryTopics(ctx, topic, isHierarchical, true)
+	if err != nil {
+		return err
+	}
+	if 0 == verified.Len() {
+		return newTopicError(EZMQX_NO_TOPIC_MATCHED, "subscribe", topic, nil)
+	}
+	return instance.addTopics(*verified)
+}
+
+// Query topics from TNS, cached response is not used if useCache is false.
+func (instance *EZMQXSubscriber) queryTopics(ctx context.Context, topic string, isHierarchical bool, useCache bool) (*list.List, error) {
 	context := instance.context
 	if false == context.isCtxInitialized() {
 		Logger.Error("Context is not initialized")
-		return EZMQX_NOT_INITIALIZED
+		return nil, newTopicError(EZMQX_NOT_INITIALIZED, "subscribe", topic, errors.New("context is not initialized"))
 	}
-	result := validateTopic(topic)
+	result := validateTopicOrPattern(topic)
 	if false == result {
 		Logger.Error("Topic validation failed")
-		return EZMQX_INVALID_TOPIC
+		return nil, newTopicError(EZMQX_INVALID_TOPIC, "subscribe", topic, errors.New("topic validation failed"))
 	}
 	if !context.isCtxTnsEnabled() {
 		Logger.Error("TNS is not enabled")
-		return EZMQX_TNS_NOT_AVAILABLE
+		return nil, newTopicError(EZMQX_TNS_NOT_AVAILABLE, "subscribe", topic, errors.New("TNS is not enabled"))
 	}
-	verified, errorCode := instance.verifyTopics(topic, isHierarchical)
-	if errorCode != EZMQX_OK {
+	queryTopic, queryHierarchical := getTNSQuery(topic, isHierarchical)
+	verified, err := instance.verifyTopics(ctx, queryTopic, queryHierarchical, useCache)
+	if err != nil {
 		Logger.Error("Verify topics failed")
-		return errorCode
+		return nil, err
 	}
-	return instance.storeTopics(*verified)
+	return filterTopics(verified, topic, isHierarchical), nil
 }
 
-func (instance *EZMQXSubscriber) parseTNSResponse(data []byte) (*list.List, EZMQXErrorCode) {
+func (instance *EZMQXSubscriber) parseTNSResponse(data []byte) (*list.List, error) {
 	ezmqxTopicList := list.New()
 	topics := make(map[string][]interface{})
 	err := json.Unmarshal([]byte(data), &topics)
 	if err != nil {
-		return nil, EZMQX_REST_ERROR
+		return nil, newError(EZMQX_REST_ERROR, "parse TNS response", &ResponseParseError{Err: err})
 	}
 	topicList, exists := topics[PAYLOAD_TOPICS]
 	if !exists {
 		Logger.Error("No topics key exists in json response")
-		return nil, EZMQX_REST_ERROR
+		return nil, newError(EZMQX_REST_ERROR, "parse TNS response", &ResponseParseError{Err: errors.New("no topics key exists in json response")})
 	}
 	for _, item := range topicList {
 		stringMap := item.(map[string]interface{})
 		dataModel, exists := stringMap[PAYLOAD_DATAMODEL].(string)
 		if !exists {
 			Logger.Error("No data model key exists in json response")
-			return nil, EZMQX_REST_ERROR
+			return nil, newError(EZMQX_REST_ERROR, "parse TNS response", &ResponseParseError{Err: errors.New("no data model key exists in json response")})
 		}
 		endPoint, exists := stringMap[PAYLOAD_ENDPOINT].(string)
 		if !exists {
 			Logger.Error("No end point key exists in json response")
-			return nil, EZMQX_REST_ERROR
+			return nil, newError(EZMQX_REST_ERROR, "parse TNS response", &ResponseParseError{Err: errors.New("no end point key exists in json response")})
 		}
 		name, exists := stringMap[PAYLOAD_NAME].(string)
 		if !exists {
 			Logger.Error("No name exists in json response")
-			return nil, EZMQX_REST_ERROR
+			return nil, newError(EZMQX_REST_ERROR, "parse TNS response", &ResponseParseError{Err: errors.New("no name exists in json response")})
 		}
 		isSecured, exists := stringMap[PAYLOAD_SECURED].(bool)
 		if !exists {
 			Logger.Error("No secured key exists in json response")
-			return nil, EZMQX_REST_ERROR
+			return nil, newError(EZMQX_REST_ERROR, "parse TNS response", &ResponseParseError{Err: errors.New("no secured key exists in json response")})
 		}
 		ezmqXEndPoint := GetEZMQXEndPoint(endPoint)
-		ezmqxTopic := GetEZMQXTopic(name, dataModel, isSecured, ezmqXEndPoint)
+		ezmqxTopic := GetEZMQXTopicWithMetadata(name, dataModel, isSecured, ezmqXEndPoint, parseMetadataPayload(stringMap))
 		topicValue := *ezmqxTopic
 		ezmqxTopicList.PushBack(topicValue)
 	}
-	return ezmqxTopicList, EZMQX_OK
+	return ezmqxTopicList, nil
 }
 
-func (instance *EZMQXSubscriber) verifyTopics(topic string, isHierarchical bool) (*list.List, EZMQXErrorCode) {
-	tnsURL := instance.context.ctxGetTnsAddr() + PREFIX + TOPIC
-	Logger.Debug("[TNS get topic]", zap.String("Rest URL:", tnsURL))
-	var hierarchical string
-	if true == isHierarchical {
-		hierarchical = QUERY_TRUE
-	} else {
-		hierarchical = QUERY_FALSE
-	}
-	query := QUERY_NAME + topic + QUERY_HIERARCHICAL + hierarchical
-	Logger.Debug("[TNS get topic]", zap.String("query:", query))
-
-	client := GetRestFactory()
-	response, err := client.Get(tnsURL + QUESTION_MARK + query)
-	if err != EZMQX_OK {
-		Logger.Debug("[TNS get topic] request failed")
-		return nil, EZMQX_REST_ERROR
-	}
-	if response.GetStatusCode() != HTTP_OK {
-		Logger.Debug("[TNS get topic] Response code is not HTTP_OK")
-		return nil, EZMQX_REST_ERROR
+func (instance *EZMQXSubscriber) verifyTopics(ctx context.Context, topic string, isHierarchical bool, useCache bool) (*list.List, error) {
+	data, err := instance.context.queryTNS(ctx, "query TNS", topic, isHierarchical, "", useCache)
+	if err != nil {
+		return nil, err
 	}
-	data := response.GetResponse()
-	Logger.Debug("[TNS get topic]", zap.String("response:", string(data)))
 	return instance.parseTNSResponse(data)
 }
 
-func (instance *EZMQXSubscriber) createSubscriber(endPoint *EZMQXEndpoint) EZMQXErrorCode {
-	instance.ezmqSubscriber = ezmq.GetEZMQSubscriber(endPoint.GetAddr(), endPoint.GetPort(), func(ezmqMsg ezmq.EZMQMessage) {},
+// Create ezmq subscriber for the given end point.
+// Callbacks of the end point are serialized, callbacks of different end points
+// may run concurrently, so that a slow callback does not stall other end points.
+func (instance *EZMQXSubscriber) createSubscriber(endPoint *EZMQXEndpoint) (*ezmq.EZMQSubscriber, error) {
+	callbackMutex := &sync.Mutex{}
+	ezmqSubscriber := ezmq.GetEZMQSubscriber(endPoint.GetAddr(), endPoint.GetPort(), func(ezmqMsg ezmq.EZMQMessage) {},
 		func(topic string, ezmqMsg ezmq.EZMQMessage) {
+			atomic.StoreInt64(&instance.lastReceived, time.Now().UnixNano())
 			contentType := ezmqMsg.GetContentType()
-			fmt.Printf("\nTopic: %s", topic)
 			if contentType == ezmq.EZMQ_CONTENT_TYPE_BYTEDATA {
 				byteData := ezmqMsg.(ezmq.EZMQByteData)
+				instance.context.metrics.addReceived(topic, len(byteData.ByteData))
+				callbackMutex.Lock()
 				instance.internalCB(topic, byteData)
+				callbackMutex.Unlock()
 			} else {
 				Logger.Debug("[Content type is not byte data")
 			}
 		})
-	if nil == instance.ezmqSubscriber {
+	if nil == ezmqSubscriber {
 		Logger.Error("Ezmq subscriber is null")
-		return EZMQX_UNKNOWN_STATE
+		return nil, newError(EZMQX_UNKNOWN_STATE, "create subscriber", errors.New("ezmq subscriber is null"))
 	}
-	return EZMQX_OK
+	return ezmqSubscriber, nil
 }
 
-func (instance *EZMQXSubscriber) subscribe(topic EZMQXTopic) EZMQXErrorCode {
+func (instance *EZMQXSubscriber) subscribe(topic EZMQXTopic) error {
 	endPoint := topic.GetEndPoint()
-	if nil == instance.ezmqSubscriber {
-		result := instance.createSubscriber(endPoint)
-		if result != EZMQX_OK {
-			Logger.Error("Create subscriber failed", zap.Int("Error code:", int(result)))
-			return result
+	key := endPoint.ToString()
+	ezmqSubscriber, exists := instance.ezmqSubscribers[key]
+	if !exists {
+		var err error
+		ezmqSubscriber, err = instance.createSubscriber(endPoint)
+		if err != nil {
+			Logger.Error("Create subscriber failed", logError(err))
+			return err
 		}
-		ezmqResult := instance.ezmqSubscriber.Start()
+		ezmqResult := ezmqSubscriber.Start()
 		if ezmqResult != ezmq.EZMQ_OK {
-			Logger.Error("Start ezmq subscriber failed", zap.Int("Error code:", int(result)))
-			return EZMQX_UNKNOWN_STATE
+			Logger.Error("Start ezmq subscriber failed", logField("error_code", int(ezmqResult)))
+			return newTopicError(EZMQX_UNKNOWN_STATE, "subscribe", topic.GetName(), errors.New("start ezmq subscriber failed"))
 		}
-		Logger.Debug("Started ezmq subscriber", zap.Int("Error code:", int(result)))
-		errorCode := instance.ezmqSubscriber.SubscribeForTopic(topic.GetName())
-		if errorCode != ezmq.EZMQ_OK {
-			Logger.Error("Subscribe failed")
-			return EZMQX_SESSION_UNAVAILABLE
-		}
-	} else {
-		errorCode := instance.ezmqSubscriber.SubscribeWithIPPort(endPoint.GetAddr(), endPoint.GetPort(), topic.GetName())
-		if errorCode != ezmq.EZMQ_OK {
-			Logger.Error("Subscribe with IP port failed")
-			return EZMQX_SESSION_UNAVAILABLE
+		Logger.Debug("Started ezmq subscriber", logField(LOG_FIELD_ENDPOINT, key))
+		instance.ezmqSubscribers[key] = ezmqSubscriber
+	}
+	errorCode := ezmqSubscriber.SubscribeForTopic(topic.GetName())
+	if errorCode != ezmq.EZMQ_OK {
+		Logger.Error("Subscribe failed")
+		if !instance.hasEndPoint(key) {
+			instance.disconnect(key)
 		}
+		return newTopicError(EZMQX_SESSION_UNAVAILABLE, "subscribe", topic.GetName(), errors.New("subscribe failed"))
+	}
+	Logger.Debug("Subscribed for topic", logField(LOG_FIELD_TOPIC, topic.GetName()))
+	return nil
+}
+
+// Store topics while creating subscriber.
+func (instance *EZMQXSubscriber) storeTopics(topics list.List) error {
+	instance.topicMutex.Lock()
+	defer instance.topicMutex.Unlock()
+	err := instance.storeTopicsLocked(topics)
+	if err != nil {
+		instance.stopSubscribers()
+		return err
+	}
+	atomic.StoreInt64(&instance.lastReceived, time.Now().UnixNano())
+	atomic.StoreUint32(&instance.status, INITIALIZED)
+	return nil
+}
+
+// Store topics on a live subscriber.
+func (instance *EZMQXSubscriber) addTopics(topics list.List) error {
+	instance.topicMutex.Lock()
+	defer instance.topicMutex.Unlock()
+	if atomic.LoadUint32(&instance.status) != INITIALIZED {
+		return newError(EZMQX_TERMINATED, "subscribe", errInvalidState)
 	}
-	Logger.Debug("Subscribed for topic", zap.String("Topic: ", topic.GetName()))
-	return EZMQX_OK
+	return instance.storeTopicsLocked(topics)
 }
 
-func (instance *EZMQXSubscriber) storeTopics(topics list.List) EZMQXErrorCode {
+func (instance *EZMQXSubscriber) storeTopicsLocked(topics list.List) error {
 	context := instance.context
 	if false == context.isCtxInitialized() {
-		return EZMQX_NOT_INITIALIZED
+		return newError(EZMQX_NOT_INITIALIZED, "subscribe", nil)
 	}
-	var result EZMQXErrorCode
 	for topic := topics.Front(); topic != nil; topic = topic.Next() {
 		ezmqxTopic := topic.Value.(EZMQXTopic)
 		if ezmqxTopic.IsSecured() {
 			Logger.Error("Topic is secured")
-			return EZMQX_INVALID_PARAM
+			return newTopicError(EZMQX_INVALID_PARAM, "subscribe", ezmqxTopic.GetName(), errors.New("topic is secured"))
 		}
 		//validate topic
 		isValid := validateTopic(ezmqxTopic.GetName())
 		if !isValid {
 			Logger.Error("Invalid topic")
-			return EZMQX_INVALID_TOPIC
+			return newTopicError(EZMQX_INVALID_TOPIC, "subscribe", ezmqxTopic.GetName(), errors.New("invalid topic"))
 		}
-		instance.amlRepDic[ezmqxTopic.GetName()], result = context.getAmlRep(ezmqxTopic.GetDataModel())
-		if result != EZMQX_OK {
-			Logger.Error("getAmlRep failed", zap.Int("Error code:", int(result)))
-			return result
+		if nil != instance.findTopic(ezmqxTopic.GetName()) {
+			Logger.Debug("Topic is already subscribed", logField(LOG_FIELD_TOPIC, ezmqxTopic.GetName()))
+			continue
 		}
-		result = instance.subscribe(ezmqxTopic)
-		if result != EZMQX_OK {
-			Logger.Error("subscribe failed", zap.Int("Error code:", int(result)))
-			return result
+		representation, err := context.getAmlRep(ezmqxTopic.GetDataModel())
+		if err != nil {
+			Logger.Error("getAmlRep failed", logError(err))
+			return err
+		}
+		instance.setAmlRep(ezmqxTopic.GetName(), representation)
+		err = instance.subscribe(ezmqxTopic)
+		if err != nil {
+			Logger.Error("subscribe failed", logError(err))
+			instance.setAmlRep(ezmqxTopic.GetName(), nil)
+			return err
 		}
 		instance.storedTopics.PushBack(ezmqxTopic)
 	}
-	atomic.StoreUint32(&instance.status, INITIALIZED)
-	return EZMQX_OK
+	return nil
+}
+
+// Unsubscribe topic on a live subscriber.
+// If it is the last topic of the end point, subscriber disconnects from the end point.
+func (instance *EZMQXSubscriber) removeTopic(topic string) error {
+	instance.topicMutex.Lock()
+	defer instance.topicMutex.Unlock()
+	if atomic.LoadUint32(&instance.status) != INITIALIZED {
+		return newTopicError(EZMQX_TERMINATED, "unsubscribe", topic, errInvalidState)
+	}
+	return instance.removeTopicLocked(topic)
 }
 
-func (instance *EZMQXSubscriber) terminate() EZMQXErrorCode {
+func (instance *EZMQXSubscriber) removeTopicLocked(topic string) error {
+	element := instance.findTopic(topic)
+	if nil == element {
+		return newTopicError(EZMQX_UNKNOWN_TOPIC, "unsubscribe", topic, errors.New("topic is not subscribed"))
+	}
+	ezmqxTopic := element.Value.(EZMQXTopic)
+	key := ezmqxTopic.GetEndPoint().ToString()
+	ezmqSubscriber, exists := instance.ezmqSubscribers[key]
+	if exists {
+		errorCode := ezmqSubscriber.UnSubscribeForTopic(topic)
+		if errorCode != ezmq.EZMQ_OK {
+			Logger.Error("Unsubscribe failed")
+			return newTopicError(EZMQX_SESSION_UNAVAILABLE, "unsubscribe", topic, errors.New("unsubscribe failed"))
+		}
+	}
+	instance.storedTopics.Remove(element)
+	instance.setAmlRep(topic, nil)
+	instance.sequences.removeTopic(topic)
+	instance.latency.removeTopic(topic)
+	if !instance.hasEndPoint(key) {
+		instance.disconnect(key)
+	}
+	Logger.Debug("Unsubscribed topic", logField(LOG_FIELD_TOPIC, topic))
+	return nil
+}
+
+func (instance *EZMQXSubscriber) findTopic(topic string) *list.Element {
+	for element := instance.storedTopics.Front(); element != nil; element = element.Next() {
+		ezmqxTopic := element.Value.(EZMQXTopic)
+		if ezmqxTopic.GetName() == topic {
+			return element
+		}
+	}
+	return nil
+}
+
+func (instance *EZMQXSubscriber) hasEndPoint(key string) bool {
+	for element := instance.storedTopics.Front(); element != nil; element = element.Next() {
+		ezmqxTopic := element.Value.(EZMQXTopic)
+		if ezmqxTopic.GetEndPoint().ToString() == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop ezmq subscriber of the given end point.
+func (instance *EZMQXSubscriber) disconnect(key string) error {
+	ezmqSubscriber, exists := instance.ezmqSubscribers[key]
+	if !exists {
+		return nil
+	}
+	delete(instance.ezmqSubscribers, key)
+	result := ezmqSubscriber.Stop()
+	if result != ezmq.EZMQ_OK {
+		Logger.Error("EZMQ subscriber stop: failed", logField(LOG_FIELD_ENDPOINT, key))
+		return newError(EZMQX_UNKNOWN_STATE, "disconnect", errors.New("ezmq subscriber stop failed"))
+	}
+	Logger.Debug("Disconnected from end point", logField(LOG_FIELD_ENDPOINT, key))
+	return nil
+}
+
+func (instance *EZMQXSubscriber) stopSubscribers() error {
+	var result error
+	for key := range instance.ezmqSubscribers {
+		err := instance.disconnect(key)
+		if err != nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// Decode envelope and AML payload of received message.
+// Legacy payload without envelope is decoded with nil header.
+func (instance *EZMQXSubscriber) decodeMessage(topic string, ezmqMsg ezmq.EZMQMessage) (*EZMQXMessageHeader, *aml.AMLObject, *aml.Representation, EZMQXErrorCode) {
+	representation := instance.getAmlRep(topic)
+	if 0 == len(topic) || nil == representation {
+		instance.context.metrics.addReceiveError(topic, EZMQX_UNKNOWN_TOPIC)
+		return nil, nil, nil, EZMQX_UNKNOWN_TOPIC
+	}
+	ezmqByteData := ezmqMsg.(ezmq.EZMQByteData)
+	header, payload, err := decodeEnvelope(ezmqByteData.ByteData)
+	if err != nil {
+		Logger.Debug("Decode envelope failed", logField(LOG_FIELD_TOPIC, topic), logError(err))
+		instance.context.metrics.addReceiveError(topic, EZMQX_BROKEN_PAYLOAD)
+		return nil, nil, nil, EZMQX_BROKEN_PAYLOAD
+	}
+	amlObject, result := representation.ByteToData(payload)
+	if result != aml.AML_OK {
+		instance.context.metrics.addReceiveError(topic, EZMQX_BROKEN_PAYLOAD)
+		return nil, nil, nil, EZMQX_BROKEN_PAYLOAD
+	}
+	return header, amlObject, representation, EZMQX_OK
+}
+
+// Check sequence number of received message.
+// Lost, duplicated and reordered messages are counted in metrics.
+func (instance *EZMQXSubscriber) checkSequence(topic string, header *EZMQXMessageHeader) EZMQXErrorCode {
+	errorCode, count := instance.sequences.check(topic, header)
+	if errorCode != EZMQX_OK {
+		Logger.Debug("Sequence mismatch", logField(LOG_FIELD_TOPIC, topic), logField("publisher_id", header.PublisherId),
+			logField("sequence", header.Sequence), logField("error_code", errorCode.String()), logField("count", count))
+		instance.context.metrics.addSequenceError(topic, errorCode, count)
+	}
+	return errorCode
+}
+
+func (instance *EZMQXSubscriber) getAmlRep(topic string) *aml.Representation {
+	instance.repMutex.RLock()
+	defer instance.repMutex.RUnlock()
+	return instance.amlRepDic[topic]
+}
+
+func (instance *EZMQXSubscriber) setAmlRep(topic string, representation *aml.Representation) {
+	instance.repMutex.Lock()
+	defer instance.repMutex.Unlock()
+	if nil == representation {
+		delete(instance.amlRepDic, topic)
+		return
+	}
+	instance.amlRepDic[topic] = representation
+}
+
+func (instance *EZMQXSubscriber) terminate() error {
+	instance.stopRoutines()
+	instance.topicMutex.Lock()
+	defer instance.topicMutex.Unlock()
 	if false == atomic.CompareAndSwapUint32(&instance.status, INITIALIZED, TERMINATING) {
 		Logger.Error("terminate failed : Not initialized")
-		return EZMQX_UNKNOWN_STATE
+		return newError(EZMQX_UNKNOWN_STATE, "terminate subscriber", errInvalidState)
 	}
-	ezmqSubscriber := instance.ezmqSubscriber
-	if ezmqSubscriber != nil {
-		result := ezmqSubscriber.Stop()
-		if result != ezmq.EZMQ_OK {
-			Logger.Error("EZMQ subscriber stop: failed")
-			atomic.StoreUint32(&instance.status, INITIALIZED)
-			return EZMQX_UNKNOWN_STATE
-		}
+	result := instance.stopSubscribers()
+	if result != nil {
+		Logger.Error("EZMQ subscriber stop: failed")
+		atomic.StoreUint32(&instance.status, INITIALIZED)
+		return newError(EZMQX_UNKNOWN_STATE, "terminate subscriber", result)
 	}
 	atomic.StoreUint32(&instance.status, CREATED)
-	return EZMQX_OK
+	return nil
 }
 
 func (instance *EZMQXSubscriber) isTerminated() bool {
@@ -250,5 +473,20 @@ func (instance *EZMQXSubscriber) isTerminated() bool {
 }
 
 func (instance *EZMQXSubscriber) getTopics() *list.List {
-	return instance.storedTopics
+	instance.topicMutex.Lock()
+	defer instance.topicMutex.Unlock()
+	topics := list.New()
+	topics.PushBackList(instance.storedTopics)
+	return topics
+}
+
+func (instance *EZMQXSubscriber) getTopicNames() []string {
+	instance.topicMutex.Lock()
+	defer instance.topicMutex.Unlock()
+	names := make([]string, 0, instance.storedTopics.Len())
+	for element := instance.storedTopics.Front(); element != nil; element = element.Next() {
+		ezmqxTopic := element.Value.(EZMQXTopic)
+		names = append(names, ezmqxTopic.GetName())
+	}
+	return names
 }
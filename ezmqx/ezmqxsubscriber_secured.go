@@ -1,90 +1,91 @@
+//go:build !unsecure
 // +build !unsecure
 
 package ezmqx
 
 import (
-	"go.uber.org/zap"
+	"errors"
 	"go/ezmq"
 	"sync/atomic"
 )
 
-func (instance *EZMQXSubscriber) storeSecuredTopics(ezmqxTopic EZMQXTopic, serverPublicKey string, clientPublicKey string, clientSecretKey string) EZMQXErrorCode {
+func (instance *EZMQXSubscriber) storeSecuredTopics(ezmqxTopic EZMQXTopic, serverPublicKey string, clientPublicKey string, clientSecretKey string) error {
+	instance.topicMutex.Lock()
+	defer instance.topicMutex.Unlock()
 	context := instance.context
 	if false == context.isCtxInitialized() {
-		return EZMQX_NOT_INITIALIZED
+		return newTopicError(EZMQX_NOT_INITIALIZED, "subscribe", ezmqxTopic.GetName(), nil)
 	}
-	var result EZMQXErrorCode
 	//validate topic
 	isValid := validateTopic(ezmqxTopic.GetName())
 	if !isValid {
 		Logger.Error("Invalid topic")
-		return EZMQX_INVALID_TOPIC
+		return newTopicError(EZMQX_INVALID_TOPIC, "subscribe", ezmqxTopic.GetName(), errors.New("invalid topic"))
 	}
-	instance.amlRepDic[ezmqxTopic.GetName()], result = context.getAmlRep(ezmqxTopic.GetDataModel())
-	if result != EZMQX_OK {
-		Logger.Error("getAmlRep failed", zap.Int("Error code:", int(result)))
-		return result
+	if nil != instance.findTopic(ezmqxTopic.GetName()) {
+		Logger.Debug("Topic is already subscribed", logField(LOG_FIELD_TOPIC, ezmqxTopic.GetName()))
+		atomic.StoreUint32(&instance.status, INITIALIZED)
+		return nil
 	}
-	result = instance.subscribeSecured(ezmqxTopic, serverPublicKey, clientPublicKey, clientSecretKey)
-	if result != EZMQX_OK {
-		Logger.Error("subscribe failed", zap.Int("Error code:", int(result)))
-		return result
+	representation, err := context.getAmlRep(ezmqxTopic.GetDataModel())
+	if err != nil {
+		Logger.Error("getAmlRep failed", logError(err))
+		return err
+	}
+	instance.setAmlRep(ezmqxTopic.GetName(), representation)
+	err = instance.subscribeSecured(ezmqxTopic, serverPublicKey, clientPublicKey, clientSecretKey)
+	if err != nil {
+		Logger.Error("subscribe failed", logError(err))
+		instance.setAmlRep(ezmqxTopic.GetName(), nil)
+		instance.stopSubscribers()
+		return err
 	}
 	instance.storedTopics.PushBack(ezmqxTopic)
 	atomic.StoreUint32(&instance.status, INITIALIZED)
-	return EZMQX_OK
+	return nil
 }
 
-func (instance *EZMQXSubscriber) subscribeSecured(topic EZMQXTopic, serverPublicKey string, clientPublicKey string, clientSecretKey string) EZMQXErrorCode {
+func (instance *EZMQXSubscriber) subscribeSecured(topic EZMQXTopic, serverPublicKey string, clientPublicKey string, clientSecretKey string) error {
 	if len(serverPublicKey) != KEY_LENGTH || len(clientPublicKey) != KEY_LENGTH || len(clientSecretKey) != KEY_LENGTH {
-		return EZMQX_INVALID_PARAM
+		return newTopicError(EZMQX_INVALID_PARAM, "subscribe", topic.GetName(), errors.New("invalid key length"))
 	}
 	endPoint := topic.GetEndPoint()
-	if nil == instance.ezmqSubscriber {
-		result := instance.createSubscriber(endPoint)
-		if result != EZMQX_OK {
-			Logger.Error("Create subscriber failed", zap.Int("Error code:", int(result)))
-			return result
+	key := endPoint.ToString()
+	ezmqSubscriber, exists := instance.ezmqSubscribers[key]
+	if !exists {
+		var err error
+		ezmqSubscriber, err = instance.createSubscriber(endPoint)
+		if err != nil {
+			Logger.Error("Create subscriber failed", logError(err))
+			return err
 		}
 		//set server key
-		ezmqResult := instance.ezmqSubscriber.SetServerPublicKey([]byte(serverPublicKey))
+		ezmqResult := ezmqSubscriber.SetServerPublicKey([]byte(serverPublicKey))
 		if ezmqResult != ezmq.EZMQ_OK {
-			Logger.Error("SetServerPublicKey failed", zap.Int("Error code:", int(result)))
-			return EZMQX_UNKNOWN_STATE
+			Logger.Error("SetServerPublicKey failed", logField("error_code", int(ezmqResult)))
+			return newTopicError(EZMQX_UNKNOWN_STATE, "subscribe", topic.GetName(), errors.New("set server public key failed"))
 		}
 		//set client keys
-		ezmqResult = instance.ezmqSubscriber.SetClientKeys([]byte(clientSecretKey), []byte(clientPublicKey))
+		ezmqResult = ezmqSubscriber.SetClientKeys([]byte(clientSecretKey), []byte(clientPublicKey))
 		if ezmqResult != ezmq.EZMQ_OK {
-			Logger.Error("SetClientKeys failed", zap.Int("Error code:", int(result)))
-			return EZMQX_UNKNOWN_STATE
+			Logger.Error("SetClientKeys failed", logField("error_code", int(ezmqResult)))
+			return newTopicError(EZMQX_UNKNOWN_STATE, "subscribe", topic.GetName(), errors.New("set client keys failed"))
 		}
 		//start subscriber
-		ezmqResult = instance.ezmqSubscriber.Start()
+		ezmqResult = ezmqSubscriber.Start()
 		if ezmqResult != ezmq.EZMQ_OK {
-			Logger.Error("Start ezmq subscriber failed", zap.Int("Error code:", int(result)))
-			return EZMQX_UNKNOWN_STATE
-		}
-		Logger.Debug("Started ezmq subscriber", zap.Int("Error code:", int(result)))
-		//Subscribe
-		errorCode := instance.ezmqSubscriber.SubscribeForTopic(topic.GetName())
-		if errorCode != ezmq.EZMQ_OK {
-			Logger.Error("Subscribe failed")
-			return EZMQX_SESSION_UNAVAILABLE
+			Logger.Error("Start ezmq subscriber failed", logField("error_code", int(ezmqResult)))
+			return newTopicError(EZMQX_UNKNOWN_STATE, "subscribe", topic.GetName(), errors.New("start ezmq subscriber failed"))
 		}
-		Logger.Debug("Subscribed for topic", zap.String("Topic: ", topic.GetName()))
-	} else {
-		//set server key
-		ezmqResult := instance.ezmqSubscriber.SetServerPublicKey([]byte(serverPublicKey))
-		if ezmqResult != ezmq.EZMQ_OK {
-			Logger.Error("SetServerPublicKey failed", zap.Int("Error code:", int(ezmqResult)))
-			return EZMQX_UNKNOWN_STATE
-		}
-		errorCode := instance.ezmqSubscriber.SubscribeWithIPPort(endPoint.GetAddr(), endPoint.GetPort(), topic.GetName())
-		if errorCode != ezmq.EZMQ_OK {
-			Logger.Error("Subscribe with IP port failed")
-			return EZMQX_SESSION_UNAVAILABLE
-		}
-		Logger.Debug("Subscribed for topic [With Ip and port]", zap.String("Topic: ", topic.GetName()))
+		Logger.Debug("Started ezmq subscriber", logField(LOG_FIELD_ENDPOINT, key))
+		instance.ezmqSubscribers[key] = ezmqSubscriber
+	}
+	//Subscribe
+	errorCode := ezmqSubscriber.SubscribeForTopic(topic.GetName())
+	if errorCode != ezmq.EZMQ_OK {
+		Logger.Error("Subscribe failed")
+		return newTopicError(EZMQX_SESSION_UNAVAILABLE, "subscribe", topic.GetName(), errors.New("subscribe failed"))
 	}
-	return EZMQX_OK
+	Logger.Debug("Subscribed for topic", logField(LOG_FIELD_TOPIC, topic.GetName()))
+	return nil
 }
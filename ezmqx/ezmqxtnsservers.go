@@ -0,0 +1,187 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Separator of TNS addresses given to StartStandAloneMode.
+const TNS_ADDR_SEPARATOR = ","
+
+// Default time to skip TNS server after its request failed.
+const DEFAULT_TNS_COOL_DOWN = 30 * time.Second
+
+// Selection of TNS server among multiple TNS servers.
+type EZMQXTNSSelection int
+
+// Selection of TNS server among multiple TNS servers.
+const (
+	// Servers are tried in the given order, first healthy server is used.
+	TNS_FAILOVER EZMQXTNSSelection = iota
+	// Requests are spread over healthy servers in turn.
+	TNS_ROUND_ROBIN
+)
+
+// Options for multiple TNS servers.
+//
+// Request which fails with transport error or 408, 429 and 5xx status codes
+// is sent to the next server, and the failed server is skipped for CoolDown.
+// If all the servers are skipped, they are tried anyway.
+type EZMQXTNSOptions struct {
+	// Selection of TNS server.
+	Selection EZMQXTNSSelection
+	// Time to skip failed server. If 0, DEFAULT_TNS_COOL_DOWN is used.
+	CoolDown time.Duration
+}
+
+type tnsServers struct {
+	addrs          []string
+	unhealthyUntil []time.Time
+	next           int
+	options        EZMQXTNSOptions
+	mutex          *sync.Mutex
+}
+
+func validateTNSOptions(options *EZMQXTNSOptions) error {
+	if options.Selection < TNS_FAILOVER || options.Selection > TNS_ROUND_ROBIN {
+		return newError(EZMQX_INVALID_PARAM, "set TNS options", errors.New("invalid TNS selection"))
+	}
+	if options.CoolDown < 0 {
+		return newError(EZMQX_INVALID_PARAM, "set TNS options", errors.New("negative cool down"))
+	}
+	if 0 == options.CoolDown {
+		options.CoolDown = DEFAULT_TNS_COOL_DOWN
+	}
+	return nil
+}
+
+// Split TNS addresses separated by TNS_ADDR_SEPARATOR.
+func parseTnsAddrs(tnsAddr string) []string {
+	if !strings.Contains(tnsAddr, TNS_ADDR_SEPARATOR) {
+		return []string{tnsAddr}
+	}
+	addrs := make([]string, 0)
+	for _, addr := range strings.Split(tnsAddr, TNS_ADDR_SEPARATOR) {
+		addr = strings.TrimSpace(addr)
+		if len(addr) > 0 {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+func newTnsServers(addrs []string, options EZMQXTNSOptions) *tnsServers {
+	var instance *tnsServers
+	instance = &tnsServers{}
+	instance.addrs = addrs
+	instance.unhealthyUntil = make([]time.Time, len(addrs))
+	instance.options = options
+	instance.mutex = &sync.Mutex{}
+	return instance
+}
+
+// Get indexes of servers in order of trial.
+// Healthy servers come first and servers in cool down come last.
+func (servers *tnsServers) getCandidates() []int {
+	servers.mutex.Lock()
+	defer servers.mutex.Unlock()
+	count := len(servers.addrs)
+	start := 0
+	if TNS_ROUND_ROBIN == servers.options.Selection && count > 0 {
+		start = servers.next
+		servers.next = (servers.next + 1) % count
+	}
+	now := time.Now()
+	healthy := make([]int, 0, count)
+	unhealthy := make([]int, 0)
+	for i := 0; i < count; i++ {
+		index := (start + i) % count
+		if now.Before(servers.unhealthyUntil[index]) {
+			unhealthy = append(unhealthy, index)
+		} else {
+			healthy = append(healthy, index)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+func (servers *tnsServers) setHealthy(index int, healthy bool) {
+	servers.mutex.Lock()
+	defer servers.mutex.Unlock()
+	if healthy {
+		servers.unhealthyUntil[index] = time.Time{}
+	} else {
+		servers.unhealthyUntil[index] = time.Now().Add(servers.options.CoolDown)
+	}
+}
+
+// Send request to servers until it succeeds or fails with error that can not be
+// sent to another server. Last error is returned.
+func (servers *tnsServers) request(ctx context.Context, request func(ctx context.Context, tnsAddr string) error) error {
+	var err error = newError(EZMQX_TNS_NOT_AVAILABLE, "TNS request", errors.New("no TNS address"))
+	for _, index := range servers.getCandidates() {
+		err = request(ctx, servers.addrs[index])
+		if nil == err {
+			servers.setHealthy(index, true)
+			return nil
+		}
+		if !isRetryable(err) || nil != ctx.Err() {
+			return err
+		}
+		Logger.Debug("[TNS request] failed, trying next TNS server", logField(LOG_FIELD_URL, servers.addrs[index]), logError(err))
+		servers.setHealthy(index, false)
+	}
+	return err
+}
+
+func (cxtInstance *EZMQXContext) setTNSOptions(options EZMQXTNSOptions) error {
+	err := validateTNSOptions(&options)
+	if err != nil {
+		return err
+	}
+	cxtInstance.mutex.Lock()
+	defer cxtInstance.mutex.Unlock()
+	cxtInstance.tnsOptions = options
+	if nil != cxtInstance.tnsServers {
+		cxtInstance.tnsServers = newTnsServers(cxtInstance.tnsServers.addrs, options)
+	}
+	return nil
+}
+
+func (cxtInstance *EZMQXContext) getTnsServers() *tnsServers {
+	cxtInstance.mutex.Lock()
+	defer cxtInstance.mutex.Unlock()
+	if nil == cxtInstance.tnsServers {
+		return newTnsServers(nil, cxtInstance.tnsOptions)
+	}
+	return cxtInstance.tnsServers
+}
+
+// Send request to TNS servers with retry policy.
+// Every attempt fails over to the next TNS server.
+func (cxtInstance *EZMQXContext) tnsRequest(ctx context.Context, policy EZMQXRetryPolicy, request func(ctx context.Context, tnsAddr string) error) error {
+	servers := cxtInstance.getTnsServers()
+	return retryRequest(ctx, policy, func(ctx context.Context) error {
+		return servers.request(ctx, request)
+	})
+}
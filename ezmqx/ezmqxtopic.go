@@ -23,6 +23,23 @@ type EZMQXTopic struct {
 	dataModel string
 	endPoint  *EZMQXEndpoint
 	isSecured bool
+	// Pointer keeps topic comparable, so it can be used as a map key.
+	metadata *EZMQXTopicMetadata
+}
+
+// Optional metadata of EZMQX topic.
+// It is registered to TNS with the topic and returned by topic discovery.
+type EZMQXTopicMetadata struct {
+	// Human readable description of the topic.
+	Description string
+	// Free-form key/value tags.
+	Tags map[string]string
+	// Id of the application which publishes the topic.
+	Owner string
+	// Version of the data model schema.
+	SchemaVersion string
+	// Nominal publish rate in messages per second.
+	Rate float64
 }
 
 // Get EZMQX topic instance.
@@ -36,6 +53,16 @@ func GetEZMQXTopic(name string, dataModel string, isSecured bool, endPoint *EZMQ
 	return instance
 }
 
+// Get EZMQX topic instance with metadata.
+func GetEZMQXTopicWithMetadata(name string, dataModel string, isSecured bool, endPoint *EZMQXEndpoint, metadata EZMQXTopicMetadata) *EZMQXTopic {
+	instance := GetEZMQXTopic(name, dataModel, isSecured, endPoint)
+	if !metadata.isEmpty() {
+		metadata.Tags = copyTags(metadata.Tags)
+		instance.metadata = &metadata
+	}
+	return instance
+}
+
 // Get topic name.
 func (topic *EZMQXTopic) GetName() string {
 	return topic.name
@@ -55,3 +82,92 @@ func (topic *EZMQXTopic) IsSecured() bool {
 func (topic *EZMQXTopic) GetEndPoint() *EZMQXEndpoint {
 	return topic.endPoint
 }
+
+// Get metadata of topic.
+func (topic *EZMQXTopic) GetMetadata() EZMQXTopicMetadata {
+	if nil == topic.metadata {
+		return EZMQXTopicMetadata{}
+	}
+	metadata := *topic.metadata
+	metadata.Tags = copyTags(metadata.Tags)
+	return metadata
+}
+
+// Get description of topic.
+func (topic *EZMQXTopic) GetDescription() string {
+	return topic.GetMetadata().Description
+}
+
+// Get tags of topic.
+func (topic *EZMQXTopic) GetTags() map[string]string {
+	return topic.GetMetadata().Tags
+}
+
+// Get id of the application which publishes the topic.
+func (topic *EZMQXTopic) GetOwner() string {
+	return topic.GetMetadata().Owner
+}
+
+// Get schema version of data model.
+func (topic *EZMQXTopic) GetSchemaVersion() string {
+	return topic.GetMetadata().SchemaVersion
+}
+
+// Get nominal publish rate in messages per second.
+func (topic *EZMQXTopic) GetRate() float64 {
+	return topic.GetMetadata().Rate
+}
+
+func (metadata *EZMQXTopicMetadata) isEmpty() bool {
+	return 0 == len(metadata.Description) && 0 == len(metadata.Tags) && 0 == len(metadata.Owner) &&
+		0 == len(metadata.SchemaVersion) && 0 == metadata.Rate
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	if nil == tags {
+		return nil
+	}
+	copied := make(map[string]string, len(tags))
+	for key, value := range tags {
+		copied[key] = value
+	}
+	return copied
+}
+
+// Add metadata keys of topic to TNS payload, empty values are omitted.
+func addMetadataPayload(jsonData map[string]interface{}, metadata EZMQXTopicMetadata) {
+	if len(metadata.Description) > 0 {
+		jsonData[PAYLOAD_DESCRIPTION] = metadata.Description
+	}
+	if len(metadata.Tags) > 0 {
+		jsonData[PAYLOAD_TAGS] = metadata.Tags
+	}
+	if len(metadata.Owner) > 0 {
+		jsonData[PAYLOAD_OWNER] = metadata.Owner
+	}
+	if len(metadata.SchemaVersion) > 0 {
+		jsonData[PAYLOAD_SCHEMA_VERSION] = metadata.SchemaVersion
+	}
+	if metadata.Rate > 0 {
+		jsonData[PAYLOAD_RATE] = metadata.Rate
+	}
+}
+
+// Parse metadata keys of topic from TNS payload, unknown types are ignored.
+func parseMetadataPayload(jsonData map[string]interface{}) EZMQXTopicMetadata {
+	var metadata EZMQXTopicMetadata
+	metadata.Description, _ = jsonData[PAYLOAD_DESCRIPTION].(string)
+	metadata.Owner, _ = jsonData[PAYLOAD_OWNER].(string)
+	metadata.SchemaVersion, _ = jsonData[PAYLOAD_SCHEMA_VERSION].(string)
+	metadata.Rate, _ = jsonData[PAYLOAD_RATE].(float64)
+	tags, _ := jsonData[PAYLOAD_TAGS].(map[string]interface{})
+	if len(tags) > 0 {
+		metadata.Tags = make(map[string]string, len(tags))
+		for key, value := range tags {
+			if tag, ok := value.(string); ok {
+				metadata.Tags[key] = tag
+			}
+		}
+	}
+	return metadata
+}
@@ -19,9 +19,11 @@ package ezmqx
 
 import (
 	"container/list"
+	"context"
+	"errors"
+	"strconv"
 
 	"encoding/json"
-	"go.uber.org/zap"
 )
 
 // Structure represents EZMQX topic discovery.
@@ -31,23 +33,48 @@ type EZMQXTopicDiscovery struct {
 
 // Get EZMQX topic discovery instance.
 func GetEZMQXTopicDiscovery() (*EZMQXTopicDiscovery, EZMQXErrorCode) {
-	context := getContextInstance()
-	if !context.isCtxInitialized() {
-		return nil, EZMQX_NOT_INITIALIZED
+	instance, err := getContextInstance().NewTopicDiscovery()
+	return instance, ErrorCodeOf(err)
+}
+
+// Create EZMQX topic discovery instance on this context.
+// On failure, it returns EZMQXError with cause of failure.
+func (ezmqxCtx *EZMQXContext) NewTopicDiscovery() (*EZMQXTopicDiscovery, error) {
+	if !ezmqxCtx.isCtxInitialized() {
+		return nil, newError(EZMQX_NOT_INITIALIZED, "create topic discovery", errors.New("context is not initialized"))
 	}
 	var instance *EZMQXTopicDiscovery
 	instance = &EZMQXTopicDiscovery{}
-	instance.ezmqxCtx = context
-	return instance, EZMQX_OK
+	instance.ezmqxCtx = ezmqxCtx
+	return instance, nil
 }
 
 // Query the given topic to TNS [Topic name server] server.
+//
+// Topic can be a pattern with wild cards: * matches one level and # matches any depth.
+// For example: /plant/*/temperature or /plant/#. For pattern, first matched topic is returned.
 func (instance *EZMQXTopicDiscovery) Query(topic string) (*EZMQXTopic, EZMQXErrorCode) {
-	topics, result := instance.queryInternal(topic, false)
-	if result != EZMQX_OK {
-		return nil, result
+	ezmqxTopic, err := instance.QueryV2(topic)
+	return ezmqxTopic, ErrorCodeOf(err)
+}
+
+// Query the given topic to TNS [Topic name server] server.
+// It is same as Query, but returns EZMQXError with cause of failure.
+func (instance *EZMQXTopicDiscovery) QueryV2(topic string) (*EZMQXTopic, error) {
+	return instance.QueryContext(context.Background(), topic)
+}
+
+// Query the given topic to TNS [Topic name server] server.
+// Query request is cancelled when ctx is done.
+func (instance *EZMQXTopicDiscovery) QueryContext(ctx context.Context, topic string) (*EZMQXTopic, error) {
+	topics, err := instance.queryInternal(ctx, topic, false, "")
+	if err != nil {
+		return nil, err
+	}
+	if 0 == topics.Len() {
+		return nil, newTopicError(EZMQX_NO_TOPIC_MATCHED, "query", topic, nil)
 	}
-	return topics.Front().Value.(*EZMQXTopic), result
+	return topics.Front().Value.(*EZMQXTopic), nil
 }
 
 // Query the given topic to TNS [Topic name server] server.
@@ -55,90 +82,239 @@ func (instance *EZMQXTopicDiscovery) Query(topic string) (*EZMQXTopic, EZMQXErro
 //
 // For example: If topic name is /Topic then in success case TNS will
 // return /Topic/A, /Topic/A/B etc.
+// For topic pattern, topics matched with the pattern and their child topics are returned.
+// TOPIC_ROOT returns all the topics registered in TNS.
 func (instance *EZMQXTopicDiscovery) HierarchicalQuery(topic string) (*list.List, EZMQXErrorCode) {
-	return instance.queryInternal(topic, true)
+	topics, err := instance.HierarchicalQueryV2(topic)
+	return topics, ErrorCodeOf(err)
+}
+
+// Query the given topic to TNS [Topic name server] server with hierarchical option.
+// It is same as HierarchicalQuery, but returns EZMQXError with cause of failure.
+func (instance *EZMQXTopicDiscovery) HierarchicalQueryV2(topic string) (*list.List, error) {
+	return instance.HierarchicalQueryContext(context.Background(), topic)
+}
+
+// Query the given topic to TNS [Topic name server] server with hierarchical option.
+// Query request is cancelled when ctx is done.
+func (instance *EZMQXTopicDiscovery) HierarchicalQueryContext(ctx context.Context, topic string) (*list.List, error) {
+	return instance.queryInternal(ctx, topic, true, "")
+}
+
+// Query a page of the given topic and its child topics to TNS [Topic name server] server.
+//
+// Topic is a topic name or TOPIC_ROOT for the whole topic namespace.
+// Topics are sorted by name, and limit 0 means no limit. If TNS server does not
+// support paging, all the topics are queried and the page is taken on client side.
+func (instance *EZMQXTopicDiscovery) HierarchicalQueryPage(topic string, offset int, limit int) (*EZMQXTopicPage, EZMQXErrorCode) {
+	page, err := instance.HierarchicalQueryPageV2(topic, offset, limit)
+	return page, ErrorCodeOf(err)
 }
 
-func (instance *EZMQXTopicDiscovery) queryInternal(topic string, isHierarchical bool) (*list.List, EZMQXErrorCode) {
+// Query a page of the given topic and its child topics to TNS [Topic name server] server.
+// It is same as HierarchicalQueryPage, but returns EZMQXError with cause of failure.
+func (instance *EZMQXTopicDiscovery) HierarchicalQueryPageV2(topic string, offset int, limit int) (*EZMQXTopicPage, error) {
+	return instance.HierarchicalQueryPageContext(context.Background(), topic, offset, limit)
+}
+
+// Query a page of the given topic and its child topics to TNS [Topic name server] server.
+// Query request is cancelled when ctx is done.
+func (instance *EZMQXTopicDiscovery) HierarchicalQueryPageContext(ctx context.Context, topic string, offset int, limit int) (*EZMQXTopicPage, error) {
+	return instance.queryPage(ctx, topic, offset, limit)
+}
+
+// Query the given topic and its child topics to TNS [Topic name server] server
+// and get them as a topic tree.
+//
+// Topic is a topic name or TOPIC_ROOT for the whole topic namespace.
+// Topics are queried in pages of DEFAULT_TREE_PAGE_SIZE. If TNS server does
+// not support paging, all the topics are taken from the first response.
+func (instance *EZMQXTopicDiscovery) QueryTree(topic string) (*EZMQXTopicNode, EZMQXErrorCode) {
+	node, err := instance.QueryTreeV2(topic)
+	return node, ErrorCodeOf(err)
+}
+
+// Query the given topic and its child topics as a topic tree.
+// It is same as QueryTree, but returns EZMQXError with cause of failure.
+func (instance *EZMQXTopicDiscovery) QueryTreeV2(topic string) (*EZMQXTopicNode, error) {
+	return instance.QueryTreeContext(context.Background(), topic)
+}
+
+// Query the given topic and its child topics as a topic tree.
+// Query requests are cancelled when ctx is done.
+func (instance *EZMQXTopicDiscovery) QueryTreeContext(ctx context.Context, topic string) (*EZMQXTopicNode, error) {
+	topics := list.New()
+	offset := 0
+	for {
+		pageTopics, total, err := instance.queryPageTopics(ctx, topic, offset, DEFAULT_TREE_PAGE_SIZE)
+		if err != nil {
+			return nil, err
+		}
+		if total < 0 || pageTopics.Len() > DEFAULT_TREE_PAGE_SIZE {
+			// TNS server ignores paging and returns all the topics
+			return GetEZMQXTopicTree(topic, pageTopics), nil
+		}
+		topics.PushBackList(pageTopics)
+		nextOffset := offset + pageTopics.Len()
+		if nextOffset >= total || nextOffset == offset {
+			break
+		}
+		offset = nextOffset
+	}
+	return GetEZMQXTopicTree(topic, topics), nil
+}
+
+// Query topics matched with the given filter to TNS [Topic name server] server.
+//
+// For example, filter EZMQXTopicFilter{Topic: "/plant", DataModel: "GTC_Robot_0.0.1", Tags: map[string]string{"line": "3"}}
+// returns /plant and its child topics with the data model, which are tagged line=3.
+func (instance *EZMQXTopicDiscovery) QueryByFilter(filter EZMQXTopicFilter) (*list.List, EZMQXErrorCode) {
+	topics, err := instance.QueryByFilterV2(filter)
+	return topics, ErrorCodeOf(err)
+}
+
+// Query topics matched with the given filter to TNS [Topic name server] server.
+// It is same as QueryByFilter, but returns EZMQXError with cause of failure.
+func (instance *EZMQXTopicDiscovery) QueryByFilterV2(filter EZMQXTopicFilter) (*list.List, error) {
+	return instance.QueryByFilterContext(context.Background(), filter)
+}
+
+// Query topics matched with the given filter to TNS [Topic name server] server.
+// Query request is cancelled when ctx is done.
+func (instance *EZMQXTopicDiscovery) QueryByFilterContext(ctx context.Context, filter EZMQXTopicFilter) (*list.List, error) {
+	if !validateTopicFilter(filter) {
+		return nil, newTopicError(EZMQX_INVALID_PARAM, "query", filter.Topic, errors.New("filter validation failed"))
+	}
+	topic := filter.Topic
+	if EMPTY_STRING == topic {
+		topic = TOPIC_ROOT
+	}
+	topics, err := instance.queryInternal(ctx, topic, true, filter.getQuery())
+	if err != nil {
+		return nil, err
+	}
+	return filter.apply(topics), nil
+}
+
+// Remove cached query responses which may contain the given topic,
+// so that the next query is sent to TNS. Empty topic or TOPIC_ROOT removes all.
+// It does nothing if discovery cache is disabled.
+func (instance *EZMQXTopicDiscovery) InvalidateCache(topic string) {
+	instance.ezmqxCtx.invalidateDiscoveryCache(topic)
+}
+
+func (instance *EZMQXTopicDiscovery) queryInternal(ctx context.Context, topic string, isHierarchical bool, filterQuery string) (*list.List, error) {
 	if instance.ezmqxCtx.isCtxTerminated() {
-		return nil, EZMQX_TERMINATED
+		return nil, newTopicError(EZMQX_TERMINATED, "query", topic, nil)
 	}
 	if !instance.ezmqxCtx.isCtxTnsEnabled() {
-		return nil, EZMQX_TNS_NOT_AVAILABLE
+		return nil, newTopicError(EZMQX_TNS_NOT_AVAILABLE, "query", topic, errors.New("TNS is not enabled"))
 	}
-	result := validateTopic(topic)
+	result := validateTopicOrPattern(topic) || (isHierarchical && TOPIC_ROOT == topic)
 	if false == result {
-		return nil, EZMQX_INVALID_TOPIC
+		return nil, newTopicError(EZMQX_INVALID_TOPIC, "query", topic, errors.New("topic validation failed"))
+	}
+	queryTopic, queryHierarchical := getTNSQuery(topic, isHierarchical)
+	topics, _, err := instance.verifyTopic(ctx, queryTopic, queryHierarchical, filterQuery)
+	if err != nil {
+		return nil, err
+	}
+	return filterTopics(topics, topic, isHierarchical), nil
+}
+
+func (instance *EZMQXTopicDiscovery) queryPage(ctx context.Context, topic string, offset int, limit int) (*EZMQXTopicPage, error) {
+	topics, total, err := instance.queryPageTopics(ctx, topic, offset, limit)
+	if err != nil {
+		return nil, err
 	}
-	return instance.verifyTopic(topic, isHierarchical)
+	if total < 0 {
+		// TNS server does not support paging
+		return getTopicPage(topics, offset, limit), nil
+	}
+	page := getTopicPage(topics, 0, 0)
+	page.offset = offset
+	page.total = total
+	return page, nil
 }
 
-func (instance *EZMQXTopicDiscovery) parseTNSResponse(data []byte) (*list.List, EZMQXErrorCode) {
+// Query a page of topics to TNS server.
+// Total number of topics is returned, if TNS server supports paging, otherwise it is -1.
+func (instance *EZMQXTopicDiscovery) queryPageTopics(ctx context.Context, topic string, offset int, limit int) (*list.List, int, error) {
+	if instance.ezmqxCtx.isCtxTerminated() {
+		return nil, -1, newTopicError(EZMQX_TERMINATED, "query", topic, nil)
+	}
+	if !instance.ezmqxCtx.isCtxTnsEnabled() {
+		return nil, -1, newTopicError(EZMQX_TNS_NOT_AVAILABLE, "query", topic, errors.New("TNS is not enabled"))
+	}
+	if !validateTopic(topic) && TOPIC_ROOT != topic {
+		return nil, -1, newTopicError(EZMQX_INVALID_TOPIC, "query", topic, errors.New("topic validation failed"))
+	}
+	if offset < 0 || limit < 0 {
+		return nil, -1, newTopicError(EZMQX_INVALID_PARAM, "query", topic, errors.New("invalid offset or limit"))
+	}
+	pageQuery := QUERY_OFFSET + strconv.Itoa(offset)
+	if limit > 0 {
+		pageQuery += QUERY_LIMIT + strconv.Itoa(limit)
+	}
+	return instance.verifyTopic(ctx, topic, true, pageQuery)
+}
+
+// Parse topics of TNS response.
+// Total number of topics is returned for paged response, otherwise it is -1.
+func (instance *EZMQXTopicDiscovery) parseTNSResponse(data []byte) (*list.List, int, error) {
 	ezmqxTopicList := list.New()
-	topics := make(map[string][]interface{})
+	topics := make(map[string]interface{})
 	err := json.Unmarshal([]byte(data), &topics)
 	if err != nil {
 		Logger.Error("parseTNSResponse: Unmarshal failed")
-		return nil, EZMQX_REST_ERROR
+		return nil, -1, newError(EZMQX_REST_ERROR, "parse TNS response", &ResponseParseError{Err: err})
 	}
-	topicList, exists := topics[PAYLOAD_TOPICS]
+	topicList, exists := topics[PAYLOAD_TOPICS].([]interface{})
 	if !exists {
 		Logger.Error("No topics key exists in json response")
-		return nil, EZMQX_REST_ERROR
+		return nil, -1, newError(EZMQX_REST_ERROR, "parse TNS response", &ResponseParseError{Err: errors.New("no topics key exists in json response")})
+	}
+	total := -1
+	if value, exists := topics[PAYLOAD_TOTAL].(float64); exists {
+		total = int(value)
 	}
 	for _, item := range topicList {
-		stringMap := item.(map[string]interface{})
+		stringMap, ok := item.(map[string]interface{})
+		if !ok {
+			Logger.Error("Topic is not an object in json response")
+			return nil, -1, newError(EZMQX_REST_ERROR, "parse TNS response", &ResponseParseError{Err: errors.New("topic is not an object in json response")})
+		}
 		dataModel, exists := stringMap[PAYLOAD_DATAMODEL].(string)
 		if !exists {
 			Logger.Error("No data model key exists in json response")
-			return nil, EZMQX_REST_ERROR
+			return nil, -1, newError(EZMQX_REST_ERROR, "parse TNS response", &ResponseParseError{Err: errors.New("no data model key exists in json response")})
 		}
 		endPoint, exists := stringMap[PAYLOAD_ENDPOINT].(string)
 		if !exists {
 			Logger.Error("No end point key exists in json response")
-			return nil, EZMQX_REST_ERROR
+			return nil, -1, newError(EZMQX_REST_ERROR, "parse TNS response", &ResponseParseError{Err: errors.New("no end point key exists in json response")})
 		}
 		name, exists := stringMap[PAYLOAD_NAME].(string)
 		if !exists {
 			Logger.Error("No name exists in json response")
-			return nil, EZMQX_REST_ERROR
+			return nil, -1, newError(EZMQX_REST_ERROR, "parse TNS response", &ResponseParseError{Err: errors.New("no name exists in json response")})
 		}
 		isSecured, exists := stringMap[PAYLOAD_SECURED].(bool)
 		if !exists {
 			Logger.Error("No secured key exists in json response")
-			return nil, EZMQX_REST_ERROR
+			return nil, -1, newError(EZMQX_REST_ERROR, "parse TNS response", &ResponseParseError{Err: errors.New("no secured key exists in json response")})
 		}
 		ezmqXEndPoint := GetEZMQXEndPoint(endPoint)
-		ezmqxTopic := GetEZMQXTopic(name, dataModel, isSecured, ezmqXEndPoint)
+		ezmqxTopic := GetEZMQXTopicWithMetadata(name, dataModel, isSecured, ezmqXEndPoint, parseMetadataPayload(stringMap))
 		ezmqxTopicList.PushBack(ezmqxTopic)
 	}
-	return ezmqxTopicList, EZMQX_OK
+	return ezmqxTopicList, total, nil
 }
 
-func (instance *EZMQXTopicDiscovery) verifyTopic(topic string, isHierarchical bool) (*list.List, EZMQXErrorCode) {
-	tnsURL := instance.ezmqxCtx.ctxGetTnsAddr() + PREFIX + TOPIC
-	Logger.Debug("[Topic discovery]", zap.String("Rest URL:", tnsURL))
-
-	var hierarchical string
-	if true == isHierarchical {
-		hierarchical = QUERY_TRUE
-	} else {
-		hierarchical = QUERY_FALSE
-	}
-	query := QUERY_NAME + topic + QUERY_HIERARCHICAL + hierarchical
-	Logger.Debug("[Topic discovery]", zap.String("query:", query))
-
-	client := GetRestFactory()
-	response, err := client.Get(tnsURL + QUESTION_MARK + query)
-	if err != EZMQX_OK {
-		Logger.Error("[Topic discovery]: request failed")
-		return nil, EZMQX_REST_ERROR
-	}
-	if response.GetStatusCode() != HTTP_OK {
-		Logger.Error("[Topic discovery]: Response code is not HTTP_OK")
-		return nil, EZMQX_REST_ERROR
+func (instance *EZMQXTopicDiscovery) verifyTopic(ctx context.Context, topic string, isHierarchical bool, filterQuery string) (*list.List, int, error) {
+	data, err := instance.ezmqxCtx.queryTNS(ctx, "query", topic, isHierarchical, filterQuery, true)
+	if err != nil {
+		return nil, -1, err
 	}
-	data := response.GetResponse()
-	Logger.Debug("[Topic discovery]: ", zap.String("response:", string(data)))
 	return instance.parseTNSResponse(data)
 }
@@ -0,0 +1,137 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"container/list"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Secured option of topic filter.
+type EZMQXSecuredFilter int
+
+// Secured option of topic filter.
+const (
+	// Both secured and unsecured topics are matched.
+	SECURED_ANY EZMQXSecuredFilter = iota
+	// Only secured topics are matched.
+	SECURED_ONLY
+	// Only unsecured topics are matched.
+	UNSECURED_ONLY
+)
+
+// Filter of topic discovery query.
+//
+// The topic, its child topics or topics matched with the pattern are searched.
+// Empty Topic or TOPIC_ROOT searches every topic.
+// Other fields are optional and empty field matches any topic.
+type EZMQXTopicFilter struct {
+	// Topic name or pattern, empty for every topic.
+	Topic string
+	// AML data model id.
+	DataModel string
+	// Secured option.
+	Secured EZMQXSecuredFilter
+	// Address of end point, or address and port such as 192.168.0.1:4000.
+	Host string
+	// Tags which topic should have with the same values.
+	Tags map[string]string
+}
+
+// Get query parameters of filter, which are appended to TNS query.
+func (filter *EZMQXTopicFilter) getQuery() string {
+	var query string
+	if len(filter.DataModel) > 0 {
+		query += QUERY_DATAMODEL + url.QueryEscape(filter.DataModel)
+	}
+	if SECURED_ONLY == filter.Secured {
+		query += QUERY_SECURED + QUERY_TRUE
+	} else if UNSECURED_ONLY == filter.Secured {
+		query += QUERY_SECURED + QUERY_FALSE
+	}
+	if len(filter.Host) > 0 {
+		query += QUERY_HOST + url.QueryEscape(filter.Host)
+	}
+	keys := make([]string, 0, len(filter.Tags))
+	for key := range filter.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		query += QUERY_TAG + url.QueryEscape(key+TAG_SEPARATOR+filter.Tags[key])
+	}
+	return query
+}
+
+// Check whether topic matches filter, except topic name.
+func (filter *EZMQXTopicFilter) match(topic *EZMQXTopic) bool {
+	if len(filter.DataModel) > 0 && filter.DataModel != topic.GetDataModel() {
+		return false
+	}
+	if (SECURED_ONLY == filter.Secured && !topic.IsSecured()) || (UNSECURED_ONLY == filter.Secured && topic.IsSecured()) {
+		return false
+	}
+	if len(filter.Host) > 0 {
+		endPoint := topic.GetEndPoint()
+		if nil == endPoint {
+			return false
+		}
+		if strings.Contains(filter.Host, COLON) {
+			if filter.Host != endPoint.ToString() {
+				return false
+			}
+		} else if filter.Host != endPoint.GetAddr() {
+			return false
+		}
+	}
+	if len(filter.Tags) > 0 {
+		tags := topic.GetTags()
+		for key, value := range filter.Tags {
+			tag, exists := tags[key]
+			if !exists || tag != value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Remove topics which do not match filter.
+// TNS server may ignore filter query parameters, so filter is applied again.
+func (filter *EZMQXTopicFilter) apply(topics *list.List) *list.List {
+	filtered := list.New()
+	for element := topics.Front(); element != nil; element = element.Next() {
+		topic := element.Value.(*EZMQXTopic)
+		if filter.match(topic) {
+			filtered.PushBack(topic)
+		}
+	}
+	return filtered
+}
+
+func validateTopicFilter(filter EZMQXTopicFilter) bool {
+	if filter.Secured < SECURED_ANY || filter.Secured > UNSECURED_ONLY {
+		return false
+	}
+	if EMPTY_STRING == filter.Topic || TOPIC_ROOT == filter.Topic {
+		return true
+	}
+	return validateTopicOrPattern(filter.Topic)
+}
@@ -19,10 +19,9 @@ package ezmqx
 
 import (
 	"container/list"
+	"context"
 	"encoding/json"
-	"fmt"
 	zmq "github.com/pebbe/zmq4"
-	"go.uber.org/zap"
 	"go/ezmq"
 	"math/rand"
 	"strconv"
@@ -32,6 +31,27 @@ import (
 	"time"
 )
 
+// Status of the keep alive service.
+type EZMQXKeepAliveStatus int
+
+// Keep alive status.
+const (
+	// Keep alive request failed or TNS returned unknown topic status.
+	KEEPALIVE_FAILED EZMQXKeepAliveStatus = iota
+	// Keep alive request succeeded after a failure.
+	KEEPALIVE_RECOVERED
+	// Topic is registered to TNS again.
+	TOPIC_REREGISTERED
+	// Topic could not be registered to TNS again.
+	TOPIC_REREGISTER_FAILED
+)
+
+// Callback to get keep alive status.
+//
+// Topic is set for TOPIC_REREGISTERED and TOPIC_REREGISTER_FAILED status.
+// Error is set for KEEPALIVE_FAILED and TOPIC_REREGISTER_FAILED status.
+type EZMQXKeepAliveStatusCB func(status EZMQXKeepAliveStatus, topic string, err error)
+
 type EZMQXTopicHandler struct {
 	context            *zmq.Context
 	topicServer        *zmq.Socket
@@ -42,33 +62,29 @@ type EZMQXTopicHandler struct {
 	keepAliveInterval  atomic.Value
 	isKeepAliveStarted atomic.Value
 	isRoutineStarted   atomic.Value
-	tnsAddress         string
 	topicList          *list.List
+	keepAliveFailed    bool
+	requestCtx         context.Context
+	cancelRequests     context.CancelFunc
 	shutdownChan       chan string
 	mutex              *sync.Mutex
 	status             uint32
 }
 
-var topicHandler *EZMQXTopicHandler
-var handlerMutex = &sync.Mutex{}
-
-func getTopicHandler() *EZMQXTopicHandler {
-	handlerMutex.Lock()
-	defer handlerMutex.Unlock()
-	if nil == topicHandler {
-		topicHandler = &EZMQXTopicHandler{}
-		topicHandler.context = ezmq.GetInstance().GetContext()
-		topicHandler.tnsAddress = getContextInstance().ctxGetTnsAddr()
-		var interval int64 = -1
-		topicHandler.keepAliveInterval.Store(interval)
-		topicHandler.isKeepAliveStarted.Store(false)
-		topicHandler.isRoutineStarted.Store(false)
-		topicHandler.topicList = list.New()
-		topicHandler.shutdownChan = nil
-		topicHandler.mutex = &sync.Mutex{}
-		topicHandler.status = CREATED
-	}
-	return topicHandler
+func newTopicHandler(ezmqxContext *EZMQXContext) *EZMQXTopicHandler {
+	var instance *EZMQXTopicHandler
+	instance = &EZMQXTopicHandler{}
+	instance.context = ezmq.GetInstance().GetContext()
+	instance.ezmqxContext = ezmqxContext
+	var interval int64 = -1
+	instance.keepAliveInterval.Store(interval)
+	instance.isKeepAliveStarted.Store(false)
+	instance.isRoutineStarted.Store(false)
+	instance.topicList = list.New()
+	instance.shutdownChan = nil
+	instance.mutex = &sync.Mutex{}
+	instance.status = CREATED
+	return instance
 }
 
 func (instance *EZMQXTopicHandler) initHandler() {
@@ -79,7 +95,7 @@ func (instance *EZMQXTopicHandler) initHandler() {
 	// Topic server
 	if nil == instance.topicServer {
 		address := getInProcUniqueAddress()
-		Logger.Debug("initHandler", zap.String("Topic server address: ", address))
+		Logger.Debug("initHandler", logField("topic_server_address", address))
 		instance.topicServer, _ = zmq.NewSocket(zmq.PAIR)
 		if nil != instance.topicServer {
 			instance.topicServer.Bind(address)
@@ -89,7 +105,7 @@ func (instance *EZMQXTopicHandler) initHandler() {
 	// Topic client
 	if nil == instance.topicClient {
 		address := instance.handlerAddress
-		Logger.Debug("initHandler", zap.String("Topic client address: ", address))
+		Logger.Debug("initHandler", logField("topic_client_address", address))
 		instance.topicClient, _ = zmq.NewSocket(zmq.PAIR)
 		if nil != instance.topicClient {
 			instance.topicClient.Connect(address)
@@ -102,7 +118,9 @@ func (instance *EZMQXTopicHandler) initHandler() {
 	}
 	//call a go routine [new thread] for handler
 	if false == instance.isRoutineStarted.Load() {
-		topicHandler.isRoutineStarted.Store(true)
+		instance.isRoutineStarted.Store(true)
+		// Keep alive requests of handler routine are cancelled on terminate
+		instance.requestCtx, instance.cancelRequests = context.WithCancel(context.Background())
 		go handleEvents(instance)
 		Logger.Debug("Topic Handler thread started")
 	}
@@ -125,8 +143,8 @@ func (instance *EZMQXTopicHandler) parseSocketData(topicServer *zmq.Socket) bool
 			return false
 		}
 	}
-	Logger.Debug("parseSocketData", zap.String("requestType: ", requestType))
-	Logger.Debug("parseSocketData", zap.String("Data: ", data))
+	Logger.Debug("parseSocketData", logField("request_type", requestType))
+	Logger.Debug("parseSocketData", logField("data", data))
 	if 0 == strings.Compare(requestType, SHUTDOWN) {
 		return true
 	} else if 0 == strings.Compare(requestType, REGISTER) {
@@ -150,7 +168,7 @@ func handleEvents(instance *EZMQXTopicHandler) {
 	duration := time.Duration(1 * time.Second)
 	for instance.poller != nil {
 		duration = time.Duration(instance.keepAliveInterval.Load().(int64)) * time.Second
-		fmt.Println("parseSocketData Duration is : ", duration)
+		Logger.Debug("Polling topic handler requests", logField("timeout", duration))
 		sockets, err = instance.poller.Poll(duration)
 		Logger.Debug("Received register/unregister/keepalive/shutdown request")
 		if err == nil {
@@ -169,15 +187,11 @@ func handleEvents(instance *EZMQXTopicHandler) {
 		}
 		if true == instance.isKeepAliveStarted.Load() {
 			currentTime := time.Now().UnixNano() / int64(time.Millisecond)
-			fmt.Println("parseSocketData current time: ", currentTime)
 			difference := currentTime - lastKeepAlive
-			fmt.Println("parseSocketData difference: ", difference)
-			fmt.Println("parseSocketData instance.getKeepAliveInterval(): ", instance.getKeepAliveInterval())
 			if difference >= instance.getKeepAliveInterval() {
 				Logger.Debug("Sending keep alive request [timer expired]")
 				instance.sendKeepAlive()
 				lastKeepAlive = time.Now().UnixNano() / int64(time.Millisecond)
-				fmt.Println("parseSocketData New Keep alive time: ", lastKeepAlive)
 			}
 		}
 	}
@@ -193,23 +207,30 @@ func (instance *EZMQXTopicHandler) send(requestType string, payload string) EZMQ
 	defer instance.mutex.Unlock()
 	result, err := instance.topicClient.Send(requestType, zmq.SNDMORE)
 	if err != nil {
-		Logger.Error("Error while sending requestType", zap.Int("Result: ", result))
+		Logger.Error("Error while sending requestType", logField("result", result))
 		return EZMQX_UNKNOWN_STATE
 	}
 	result, err = instance.topicClient.Send(payload, 0)
 	if err != nil {
-		Logger.Error("Error while sending payload", zap.Int("Result: ", result))
+		Logger.Error("Error while sending payload", logField("result", result))
 		return EZMQX_UNKNOWN_STATE
 	}
 	return EZMQX_OK
 }
 
-func (instance *EZMQXTopicHandler) addTopic(topic string) {
+func (instance *EZMQXTopicHandler) addTopic(payload string) {
 	if nil == instance.topicList {
 		Logger.Error("Topic list is nil")
 	}
+	topic, err := parseRegisterPayload([]byte(payload))
+	if err != nil {
+		Logger.Error("Add topic: parse payload failed")
+		return
+	}
+	instance.mutex.Lock()
 	instance.topicList.PushBack(topic)
-	Logger.Debug("Added topic to list", zap.String("Topic: ", topic))
+	instance.mutex.Unlock()
+	Logger.Debug("Added topic to list", logField(LOG_FIELD_TOPIC, topic.GetName()))
 }
 
 func (instance *EZMQXTopicHandler) removeTopic(topic string) {
@@ -217,37 +238,162 @@ func (instance *EZMQXTopicHandler) removeTopic(topic string) {
 	if nil == topicList {
 		Logger.Error("Topic list is nil")
 	}
-	for element := topicList.Front(); element != nil; element = element.Next() {
-		if 0 == strings.Compare(element.Value.(string), topic) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	var next *list.Element
+	for element := topicList.Front(); element != nil; element = next {
+		next = element.Next()
+		if 0 == strings.Compare(element.Value.(*EZMQXTopic).GetName(), topic) {
 			topicList.Remove(element)
-			Logger.Debug("Removed topic from list", zap.String("Topic: ", topic))
+			Logger.Debug("Removed topic from list", logField(LOG_FIELD_TOPIC, topic))
 		}
 	}
 }
 
-func (instance *EZMQXTopicHandler) sendKeepAlive() {
+func (instance *EZMQXTopicHandler) getTopics() []*EZMQXTopic {
 	instance.mutex.Lock()
-	topicArray := make([]string, instance.topicList.Len())
-	i := 0
-	for topic := instance.topicList.Front(); topic != nil; topic = topic.Next() {
-		topicArray[i] = topic.Value.(string)
-		i++
+	defer instance.mutex.Unlock()
+	topics := make([]*EZMQXTopic, 0, instance.topicList.Len())
+	for element := instance.topicList.Front(); element != nil; element = element.Next() {
+		topics = append(topics, element.Value.(*EZMQXTopic))
+	}
+	return topics
+}
+
+func (instance *EZMQXTopicHandler) sendKeepAlive() {
+	topics := instance.getTopics()
+	topicArray := make([]string, len(topics))
+	for i, topic := range topics {
+		topicArray[i] = topic.GetName()
 	}
-	instance.mutex.Unlock()
 	payload := make(map[string]interface{})
 	payload[PAYLOAD_TOPIC_KA] = topicArray
-	fmt.Println("Payload to send: \n\n", payload)
-	jsonPayload, error := json.Marshal(payload)
-	if error != nil {
+	Logger.Debug("[Send Keep Alive] payload", logField("topics", topicArray))
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
 		Logger.Error("send Keep alive: json marshal failed")
 		return
 	}
-	keepAliveURL := instance.tnsAddress + PREFIX + TNS_KEEP_ALIVE
-	Logger.Debug("[Send Keep Alive]", zap.String("Rest URL:", keepAliveURL))
-	client := GetRestFactory()
-	duration := time.Duration(instance.keepAliveInterval.Load().(int64)) * time.Second * 2
-	response, _ := client.Post1(keepAliveURL, jsonPayload, duration)
-	Logger.Debug("[Send Keep Alive] ", zap.Int("Response Status code: ", response.GetStatusCode()))
+	ezmqxCtx := instance.ezmqxContext
+	client := ezmqxCtx.GetRestFactory()
+	duration := time.Duration(instance.keepAliveInterval.Load().(int64)) * time.Second
+	var unknownTopics []string
+	err = ezmqxCtx.tnsRequest(instance.requestCtx, instance.getKeepAlivePolicy(duration), func(ctx context.Context, tnsAddr string) error {
+		ctx, cancel := context.WithTimeout(ctx, duration)
+		defer cancel()
+		keepAliveURL := tnsAddr + PREFIX + TNS_KEEP_ALIVE
+		Logger.Debug("[Send Keep Alive]", logField(LOG_FIELD_URL, keepAliveURL))
+		unknownTopics = nil
+		response, err := client.PostContext(ctx, keepAliveURL, jsonPayload)
+		if err != nil {
+			return newURLError(EZMQX_REST_ERROR, "send keep alive", keepAliveURL, err)
+		}
+		if response.GetStatusCode() == HTTP_NOT_FOUND {
+			unknownTopics = parseKeepAliveResponse(response.GetResponse(), topicArray)
+		}
+		if response.GetStatusCode() != HTTP_OK {
+			return newURLError(EZMQX_REST_ERROR, "send keep alive", keepAliveURL, &HTTPStatusError{response.GetStatusCode()})
+		}
+		Logger.Debug("[Send Keep Alive] ", logField(LOG_FIELD_STATUS, response.GetStatusCode()))
+		return nil
+	})
+	if nil != instance.requestCtx.Err() {
+		// Handler is terminated
+		return
+	}
+	ezmqxCtx.metrics.addKeepAlive(nil == err)
+	if err != nil {
+		Logger.Error("[Send Keep Alive] failed", logError(err))
+		instance.keepAliveFailed = true
+		instance.reportStatus(KEEPALIVE_FAILED, "", err)
+		// TNS may have been restarted and lost some topics, so register them again
+		if len(unknownTopics) > 0 {
+			instance.registerTopics(getTopicsByName(topics, unknownTopics), duration)
+		}
+		return
+	}
+	if instance.keepAliveFailed {
+		instance.keepAliveFailed = false
+		instance.reportStatus(KEEPALIVE_RECOVERED, "", nil)
+	}
+}
+
+// Get names of topics unknown to TNS from keep alive response.
+// Every topic is assumed to be unknown, if TNS does not report them.
+func parseKeepAliveResponse(data []byte, topics []string) []string {
+	result := make(map[string][]string)
+	if err := json.Unmarshal(data, &result); err != nil || 0 == len(result[PAYLOAD_TOPIC_KA]) {
+		return topics
+	}
+	return result[PAYLOAD_TOPIC_KA]
+}
+
+func getTopicsByName(topics []*EZMQXTopic, names []string) []*EZMQXTopic {
+	filtered := make([]*EZMQXTopic, 0, len(names))
+	for _, topic := range topics {
+		for _, name := range names {
+			if topic.GetName() == name {
+				filtered = append(filtered, topic)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func (instance *EZMQXTopicHandler) registerTopics(topics []*EZMQXTopic, timeout time.Duration) {
+	ezmqxCtx := instance.ezmqxContext
+	client := ezmqxCtx.GetRestFactory()
+	for _, topic := range topics {
+		if nil != instance.requestCtx.Err() {
+			return
+		}
+		jsonValue, err := getRegisterPayload(topic)
+		if err != nil {
+			instance.reportStatus(TOPIC_REREGISTER_FAILED, topic.GetName(), newTopicError(EZMQX_REST_ERROR, "register topic", topic.GetName(), err))
+			continue
+		}
+		err = ezmqxCtx.tnsRequest(instance.requestCtx, instance.getKeepAlivePolicy(timeout), func(ctx context.Context, tnsAddr string) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			response, err := client.PostContext(ctx, tnsAddr+PREFIX+TOPIC, jsonValue)
+			if err != nil {
+				return newTopicError(EZMQX_REST_ERROR, "register topic", topic.GetName(), err)
+			}
+			if HTTP_CONFLICT == response.GetStatusCode() {
+				// Topic is taken over by another publisher
+				return newTopicError(EZMQX_DUPLICATED_TOPIC, "register topic", topic.GetName(), &HTTPStatusError{response.GetStatusCode()})
+			}
+			if response.GetStatusCode() != HTTP_CREATED {
+				return newTopicError(EZMQX_REST_ERROR, "register topic", topic.GetName(), &HTTPStatusError{response.GetStatusCode()})
+			}
+			return nil
+		})
+		if err != nil {
+			Logger.Error("[Register topic] failed", logField(LOG_FIELD_TOPIC, topic.GetName()), logError(err))
+			instance.reportStatus(TOPIC_REREGISTER_FAILED, topic.GetName(), err)
+			continue
+		}
+		Logger.Debug("[Register topic] success", logField(LOG_FIELD_TOPIC, topic.GetName()))
+		instance.reportStatus(TOPIC_REREGISTERED, topic.GetName(), nil)
+	}
+}
+
+// Get retry policy of keep alive and re-registration, which is bounded by keep
+// alive interval, so that handler routine is not blocked on retries of a failing TNS.
+func (instance *EZMQXTopicHandler) getKeepAlivePolicy(interval time.Duration) EZMQXRetryPolicy {
+	policy := instance.ezmqxContext.getRetryPolicy()
+	if 0 == policy.Deadline || policy.Deadline > interval {
+		policy.Deadline = interval
+	}
+	return policy
+}
+
+func (instance *EZMQXTopicHandler) reportStatus(status EZMQXKeepAliveStatus, topic string, err error) {
+	statusCB := instance.ezmqxContext.getKeepAliveStatusCB()
+	if nil != statusCB {
+		statusCB(status, topic, err)
+	}
 }
 
 func (instance *EZMQXTopicHandler) terminateHandler() {
@@ -255,6 +401,10 @@ func (instance *EZMQXTopicHandler) terminateHandler() {
 		return
 	}
 
+	// cancel pending keep alive request of go routine
+	if nil != instance.cancelRequests {
+		instance.cancelRequests()
+	}
 	//shut down channel to stop go routine
 	instance.shutdownChan = make(chan string)
 	timeout := make(chan bool, 1)
@@ -291,6 +441,7 @@ func (instance *EZMQXTopicHandler) terminateHandler() {
 	instance.topicServer = nil
 	instance.topicClient = nil
 	instance.topicList.Init()
+	instance.keepAliveFailed = false
 	var interval int64 = -1
 	instance.keepAliveInterval.Store(interval)
 	instance.isKeepAliveStarted.Store(false)
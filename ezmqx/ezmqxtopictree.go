@@ -0,0 +1,223 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+)
+
+// Topic name of the root of topic namespace.
+// It can be used only for hierarchical query and topic tree.
+const TOPIC_ROOT = "/"
+
+// Default number of topics requested in a page while building topic tree.
+const DEFAULT_TREE_PAGE_SIZE = 500
+
+// Structure represents a node of topic tree.
+//
+// Each node is a level of topic name. Node has a topic if the topic is
+// registered with the name of the node, otherwise it is an intermediate node.
+type EZMQXTopicNode struct {
+	name       string
+	level      string
+	topic      *EZMQXTopic
+	children   map[string]*EZMQXTopicNode
+	topicCount int
+}
+
+// Structure represents a page of hierarchical query.
+type EZMQXTopicPage struct {
+	topics *list.List
+	offset int
+	total  int
+}
+
+// Get topic tree of the given topics.
+//
+// Root is a topic name or TOPIC_ROOT. Topics which are not the root or its
+// child topics are ignored.
+func GetEZMQXTopicTree(root string, topics *list.List) *EZMQXTopicNode {
+	rootNode := newTopicNode(root, getTopicLevel(root))
+	if nil == topics {
+		return rootNode
+	}
+	for element := topics.Front(); element != nil; element = element.Next() {
+		var topic *EZMQXTopic
+		switch ezmqxTopic := element.Value.(type) {
+		case EZMQXTopic:
+			topic = &ezmqxTopic
+		case *EZMQXTopic:
+			topic = ezmqxTopic
+		default:
+			continue
+		}
+		rootNode.addTopic(topic)
+	}
+	return rootNode
+}
+
+func newTopicNode(name string, level string) *EZMQXTopicNode {
+	var instance *EZMQXTopicNode
+	instance = &EZMQXTopicNode{}
+	instance.name = name
+	instance.level = level
+	instance.children = make(map[string]*EZMQXTopicNode)
+	return instance
+}
+
+func getTopicLevel(name string) string {
+	return name[strings.LastIndex(name, F_SLASH)+1:]
+}
+
+// Add topic to the node or its descendant.
+func (node *EZMQXTopicNode) addTopic(topic *EZMQXTopic) {
+	name := topic.GetName()
+	var relative string
+	if TOPIC_ROOT == node.name {
+		relative = strings.TrimPrefix(name, F_SLASH)
+	} else if name != node.name {
+		if !strings.HasPrefix(name, node.name+F_SLASH) {
+			return
+		}
+		relative = strings.TrimPrefix(name, node.name+F_SLASH)
+	}
+	current := node
+	current.topicCount++
+	if len(relative) > 0 {
+		for _, level := range strings.Split(relative, F_SLASH) {
+			child, exists := current.children[level]
+			if !exists {
+				childName := current.name + F_SLASH + level
+				if TOPIC_ROOT == current.name {
+					childName = F_SLASH + level
+				}
+				child = newTopicNode(childName, level)
+				current.children[level] = child
+			}
+			current = child
+			current.topicCount++
+		}
+	}
+	current.topic = topic
+}
+
+// Get full topic name of node.
+func (node *EZMQXTopicNode) GetName() string {
+	return node.name
+}
+
+// Get last level of topic name of node.
+func (node *EZMQXTopicNode) GetLevel() string {
+	return node.level
+}
+
+// Get topic of node, it is nil for intermediate node.
+func (node *EZMQXTopicNode) GetTopic() *EZMQXTopic {
+	return node.topic
+}
+
+// Get child nodes sorted by level.
+func (node *EZMQXTopicNode) GetChildren() []*EZMQXTopicNode {
+	children := make([]*EZMQXTopicNode, 0, len(node.children))
+	for _, child := range node.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].level < children[j].level
+	})
+	return children
+}
+
+// Get child node of the given level, it is nil if there is no such child.
+func (node *EZMQXTopicNode) GetChild(level string) *EZMQXTopicNode {
+	return node.children[level]
+}
+
+// Get number of child nodes.
+func (node *EZMQXTopicNode) GetChildCount() int {
+	return len(node.children)
+}
+
+// Get number of topics of node and all of its descendants.
+func (node *EZMQXTopicNode) GetTopicCount() int {
+	return node.topicCount
+}
+
+// Check whether node has no child.
+func (node *EZMQXTopicNode) IsLeaf() bool {
+	return 0 == len(node.children)
+}
+
+// Visit node and its descendants in depth first order.
+// Depth of node is 0. Children of a node are skipped if visit returns false.
+func (node *EZMQXTopicNode) Walk(visit func(node *EZMQXTopicNode, depth int) bool) {
+	node.walk(visit, 0)
+}
+
+func (node *EZMQXTopicNode) walk(visit func(node *EZMQXTopicNode, depth int) bool, depth int) {
+	if !visit(node, depth) {
+		return
+	}
+	for _, child := range node.GetChildren() {
+		child.walk(visit, depth+1)
+	}
+}
+
+// Get topics of page, sorted by name.
+func (page *EZMQXTopicPage) GetTopics() *list.List {
+	return page.topics
+}
+
+// Get offset of the first topic of page.
+func (page *EZMQXTopicPage) GetOffset() int {
+	return page.offset
+}
+
+// Get total number of topics of query.
+func (page *EZMQXTopicPage) GetTotal() int {
+	return page.total
+}
+
+// Check whether there are topics after this page.
+func (page *EZMQXTopicPage) HasNext() bool {
+	return page.offset+page.topics.Len() < page.total
+}
+
+// Get offset of next page.
+func (page *EZMQXTopicPage) GetNextOffset() int {
+	return page.offset + page.topics.Len()
+}
+
+// Get page of the given topics, which are sorted by name.
+// It is used when TNS server does not support paging.
+func getTopicPage(topics *list.List, offset int, limit int) *EZMQXTopicPage {
+	sorted := make([]*EZMQXTopic, 0, topics.Len())
+	for element := topics.Front(); element != nil; element = element.Next() {
+		sorted = append(sorted, element.Value.(*EZMQXTopic))
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].GetName() < sorted[j].GetName()
+	})
+	page := &EZMQXTopicPage{topics: list.New(), offset: offset, total: len(sorted)}
+	for i := offset; i < len(sorted) && (limit <= 0 || i < offset+limit); i++ {
+		page.topics.PushBack(sorted[i])
+	}
+	return page
+}
@@ -18,6 +18,7 @@
 package ezmqx
 
 import (
+	"container/list"
 	"regexp"
 	"strings"
 )
@@ -32,8 +33,8 @@ const TOPIC_PATTERN = "^(/)[a-zA-Z0-9-_./]+$"
 const EMPTY_STRING = ""
 const KEY_LENGTH = 40
 
-//const TOPIC_WILD_CARD = "*"
-//const TOPIC_WILD_PATTERN = "/*/";
+const TOPIC_WILD_CARD = "*"
+const TOPIC_MULTI_LEVEL_WILD_CARD = "#"
 const CREATED = 0
 const INITIALIZING = 1
 const INITIALIZED = 2
@@ -52,3 +53,150 @@ func validateTopic(topic string) bool {
 	}
 	return true
 }
+
+// Check whether topic is a pattern with wild cards.
+func isTopicPattern(topic string) bool {
+	return strings.Contains(topic, TOPIC_WILD_CARD) || strings.Contains(topic, TOPIC_MULTI_LEVEL_WILD_CARD)
+}
+
+// Validate topic pattern.
+// Each level of pattern is either a valid topic level, TOPIC_WILD_CARD which
+// matches one level or TOPIC_MULTI_LEVEL_WILD_CARD which matches any depth and
+// should be the last level. First level should not be a wild card.
+func validateTopicPattern(pattern string) bool {
+	if !strings.HasPrefix(pattern, F_SLASH) || strings.Contains(pattern, F_DOUBLE_SLASH) || strings.HasSuffix(pattern, F_SLASH) {
+		return false
+	}
+	levels := strings.Split(pattern[1:], F_SLASH)
+	for i, level := range levels {
+		switch level {
+		case TOPIC_WILD_CARD:
+			if 0 == i {
+				return false
+			}
+		case TOPIC_MULTI_LEVEL_WILD_CARD:
+			if 0 == i || i != len(levels)-1 {
+				return false
+			}
+		default:
+			if !validateTopic(F_SLASH + level) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Validate topic name or topic pattern.
+func validateTopicOrPattern(topic string) bool {
+	if isTopicPattern(topic) {
+		return validateTopicPattern(topic)
+	}
+	return validateTopic(topic)
+}
+
+// Get topic and hierarchical option to be sent to TNS.
+// For topic pattern, literal levels before the first wild card are queried
+// hierarchically and result is matched on client side.
+func getTNSQuery(topic string, isHierarchical bool) (string, bool) {
+	if !isTopicPattern(topic) {
+		return topic, isHierarchical
+	}
+	levels := strings.Split(topic[1:], F_SLASH)
+	prefix := EMPTY_STRING
+	for _, level := range levels {
+		if level == TOPIC_WILD_CARD || level == TOPIC_MULTI_LEVEL_WILD_CARD {
+			break
+		}
+		prefix += F_SLASH + level
+	}
+	return prefix, true
+}
+
+// Group topic names by their first level, key of a group is the longest
+// common prefix of its names, e.g. /a/b/c and /a/b/d are grouped by /a/b.
+func groupTopicsByPrefix(names []string) map[string][]string {
+	prefixes := make(map[string]string)
+	groups := make(map[string][]string)
+	for _, name := range names {
+		first := F_SLASH + strings.SplitN(strings.TrimPrefix(name, F_SLASH), F_SLASH, 2)[0]
+		prefix, exists := prefixes[first]
+		if !exists {
+			prefix = name
+		}
+		prefixes[first] = getCommonPrefix(prefix, name)
+		groups[first] = append(groups[first], name)
+	}
+	result := make(map[string][]string)
+	for first, group := range groups {
+		result[prefixes[first]] = group
+	}
+	return result
+}
+
+// Get the longest common prefix of two topic names at level boundary.
+func getCommonPrefix(topic1 string, topic2 string) string {
+	levels1 := strings.Split(strings.TrimPrefix(topic1, F_SLASH), F_SLASH)
+	levels2 := strings.Split(strings.TrimPrefix(topic2, F_SLASH), F_SLASH)
+	prefix := EMPTY_STRING
+	for i := 0; i < len(levels1) && i < len(levels2) && levels1[i] == levels2[i]; i++ {
+		prefix += F_SLASH + levels1[i]
+	}
+	return prefix
+}
+
+// Match topic pattern with topic name.
+// TOPIC_MULTI_LEVEL_WILD_CARD also matches the parent level, e.g. /a/# matches /a.
+func matchTopicPattern(pattern string, name string) bool {
+	patternLevels := strings.Split(strings.TrimPrefix(pattern, F_SLASH), F_SLASH)
+	nameLevels := strings.Split(strings.TrimPrefix(name, F_SLASH), F_SLASH)
+	for i, level := range patternLevels {
+		if level == TOPIC_MULTI_LEVEL_WILD_CARD {
+			return true
+		}
+		if i >= len(nameLevels) {
+			return false
+		}
+		if level != TOPIC_WILD_CARD && level != nameLevels[i] {
+			return false
+		}
+	}
+	return len(patternLevels) == len(nameLevels)
+}
+
+// Check whether topic name is covered by the given topic or topic pattern.
+// With hierarchical option, child topics are covered as well.
+func isTopicCovered(topic string, isHierarchical bool, name string) bool {
+	if isTopicPattern(topic) {
+		if matchTopicPattern(topic, name) {
+			return true
+		}
+		return isHierarchical && matchTopicPattern(topic+F_SLASH+TOPIC_MULTI_LEVEL_WILD_CARD, name)
+	}
+	if name == topic {
+		return true
+	}
+	return isHierarchical && strings.HasPrefix(name, topic+F_SLASH)
+}
+
+// Filter topics of TNS response with topic pattern.
+// Topics can be either EZMQXTopic or *EZMQXTopic.
+func filterTopics(topics *list.List, topic string, isHierarchical bool) *list.List {
+	if !isTopicPattern(topic) {
+		return topics
+	}
+	filtered := list.New()
+	for element := topics.Front(); element != nil; element = element.Next() {
+		var name string
+		switch ezmqxTopic := element.Value.(type) {
+		case EZMQXTopic:
+			name = ezmqxTopic.GetName()
+		case *EZMQXTopic:
+			name = ezmqxTopic.GetName()
+		}
+		if isTopicCovered(topic, isHierarchical, name) {
+			filtered.PushBack(element.Value)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,137 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+type EZMQXTopicChangeType int
+
+// Constants represents type of topic change found by watch mode subscriber.
+const (
+	TOPIC_ADDED   EZMQXTopicChangeType = 0
+	TOPIC_REMOVED EZMQXTopicChangeType = 1
+)
+
+const DEFAULT_WATCH_INTERVAL = 10 * time.Second
+
+// Callback to get topic changes of watch mode subscriber.
+type EZMQXTopicChangeCB func(changeType EZMQXTopicChangeType, topic EZMQXTopic)
+
+// Options for watch mode subscriber.
+//
+// Interval is the period of TNS query, if it is 0 then DEFAULT_WATCH_INTERVAL
+// is used.
+// TopicChangeCallback is called for every topic that is subscribed or
+// unsubscribed as a result of TNS query. It can be nil.
+type EZMQXWatchOptions struct {
+	Interval            time.Duration
+	TopicChangeCallback EZMQXTopicChangeCB
+}
+
+func validateWatchOptions(options *EZMQXWatchOptions) error {
+	if options.Interval < 0 {
+		return newError(EZMQX_INVALID_PARAM, "watch", errors.New("invalid watch interval"))
+	}
+	if 0 == options.Interval {
+		options.Interval = DEFAULT_WATCH_INTERVAL
+	}
+	return nil
+}
+
+// Start routine which re-queries TNS for the given topic on every interval.
+// Failure of TNS query is reported on error callback for the watched topic.
+func (instance *EZMQXSubscriber) startWatch(topic string, isHierarchical bool, options EZMQXWatchOptions) {
+	instance.startPeriodicRoutine("Watch", options.Interval,
+		func(ctx context.Context, now time.Time) (*topicChanges, error) {
+			return instance.refreshTopics(ctx, topic, isHierarchical)
+		},
+		func(err error) {
+			instance.reportError(topic, ErrorCodeOf(err))
+		}, options.TopicChangeCallback)
+}
+
+// Query topics from TNS and diff them with subscribed topics.
+// New topics are subscribed and topics that are not in TNS any more are unsubscribed.
+// Only subscribed topics which are covered by the watched topic are unsubscribed.
+// Subscribed topics whose end point is changed are moved to the new end point.
+func (instance *EZMQXSubscriber) refreshTopics(ctx context.Context, topic string, isHierarchical bool) (*topicChanges, error) {
+	verified, err := instance.queryTopics(ctx, topic, isHierarchical, false)
+	if err != nil {
+		return nil, err
+	}
+	instance.topicMutex.Lock()
+	defer instance.topicMutex.Unlock()
+	if atomic.LoadUint32(&instance.status) != INITIALIZED {
+		return nil, newTopicError(EZMQX_TERMINATED, "watch", topic, errInvalidState)
+	}
+	queried := make(map[string]bool)
+	for element := verified.Front(); element != nil; element = element.Next() {
+		ezmqxTopic := element.Value.(EZMQXTopic)
+		queried[ezmqxTopic.GetName()] = true
+	}
+	stale := list.New()
+	for element := instance.storedTopics.Front(); element != nil; element = element.Next() {
+		ezmqxTopic := element.Value.(EZMQXTopic)
+		if !queried[ezmqxTopic.GetName()] && isTopicCovered(topic, isHierarchical, ezmqxTopic.GetName()) {
+			stale.PushBack(ezmqxTopic)
+		}
+	}
+	// Topics are reported as removed only if they are unsubscribed
+	removed := list.New()
+	for element := stale.Front(); element != nil; element = element.Next() {
+		ezmqxTopic := element.Value.(EZMQXTopic)
+		result := instance.removeTopicLocked(ezmqxTopic.GetName())
+		if result != nil {
+			Logger.Error("[Watch] unsubscribe failed", logError(result))
+			err = result
+			continue
+		}
+		removed.PushBack(ezmqxTopic)
+	}
+	added := list.New()
+	reconnected := list.New()
+	for element := verified.Front(); element != nil; element = element.Next() {
+		ezmqxTopic := element.Value.(EZMQXTopic)
+		if nil != instance.findTopic(ezmqxTopic.GetName()) {
+			moved, result := instance.updateEndPointLocked(ezmqxTopic)
+			if result != nil {
+				Logger.Error("[Watch] reconnect failed", logError(result))
+				err = result
+			} else if moved {
+				reconnected.PushBack(ezmqxTopic)
+			}
+			continue
+		}
+		var topicList list.List
+		topicList.PushBack(ezmqxTopic)
+		result := instance.storeTopicsLocked(topicList)
+		if result != nil {
+			Logger.Error("[Watch] subscribe failed", logError(result))
+			err = result
+			continue
+		}
+		added.PushBack(ezmqxTopic)
+	}
+	return &topicChanges{added: added, removed: removed, reconnected: reconnected}, err
+}
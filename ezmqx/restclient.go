@@ -19,86 +19,117 @@ package ezmqx
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"time"
 )
 
 type RestClient struct {
-	client http.Client
+	client        http.Client
+	authorization string
 }
 
+// Get REST client which shares the default HTTP transport.
 func GetRestClient(timeout time.Duration) *RestClient {
+	return GetRestClientWithTransport(timeout, defaultTransport, "")
+}
+
+// Get REST client with the given transport and Authorization header value.
+// Clients of the same transport share its connections.
+func GetRestClientWithTransport(timeout time.Duration, transport http.RoundTripper, authorization string) *RestClient {
 	var instance *RestClient
 	instance = &RestClient{}
 	instance.client = http.Client{
-		Timeout: timeout,
+		Transport: transport,
+		Timeout:   timeout,
 	}
-	InitLogger()
+	instance.authorization = authorization
 	return instance
 }
 
-func (instance *RestClient) Get(url string) (*RestResponse, EZMQXErrorCode) {
-	response, err := instance.client.Get(url)
+// Send request and read response. Response body is always closed,
+// so that connection can be reused.
+func (instance *RestClient) do(req *http.Request, readBody bool) (*RestResponse, error) {
+	if len(instance.authorization) > 0 {
+		req.Header.Set(HEADER_AUTHORIZATION, instance.authorization)
+	}
+	response, err := instance.client.Do(req)
 	if err != nil {
 		Logger.Error("HTTP request failed")
-		return nil, EZMQX_REST_ERROR
+		return nil, newURLError(EZMQX_REST_ERROR, req.Method, req.URL.String(), err)
+	}
+	defer response.Body.Close()
+	if !readBody {
+		io.Copy(ioutil.Discard, response.Body)
+		return GetRestResponse(response.StatusCode, nil), nil
 	}
 	data, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		Logger.Error("Failed to read response body")
-		return nil, EZMQX_REST_ERROR
+		return nil, newURLError(EZMQX_REST_ERROR, req.Method, req.URL.String(), err)
 	}
-	res := GetRestResponse(response.StatusCode, data)
-	return res, EZMQX_OK
+	return GetRestResponse(response.StatusCode, data), nil
+}
+
+func (instance *RestClient) Get(url string) (*RestResponse, EZMQXErrorCode) {
+	response, err := instance.GetContext(context.Background(), url)
+	return response, ErrorCodeOf(err)
 }
 
 func (instance *RestClient) Put(url string, data []byte) (*RestResponse, EZMQXErrorCode) {
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(data))
+	response, err := instance.PutContext(context.Background(), url, data)
+	return response, ErrorCodeOf(err)
+}
+
+func (instance *RestClient) Post(url string, data []byte) (*RestResponse, EZMQXErrorCode) {
+	response, err := instance.PostContext(context.Background(), url, data)
+	return response, ErrorCodeOf(err)
+}
+
+func (instance *RestClient) Delete(url string, data []byte) (*RestResponse, EZMQXErrorCode) {
+	response, err := instance.DeleteContext(context.Background(), url, data)
+	return response, ErrorCodeOf(err)
+}
+
+// Send GET request, which is cancelled when ctx is done.
+func (instance *RestClient) GetContext(ctx context.Context, url string) (*RestResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		Logger.Error("Form delete request failed")
-		return nil, EZMQX_REST_ERROR
+		Logger.Error("Form get request failed")
+		return nil, newURLError(EZMQX_REST_ERROR, "GET", url, err)
 	}
-	response, err := instance.client.Do(req)
+	return instance.do(req, true)
+}
+
+// Send PUT request, which is cancelled when ctx is done.
+func (instance *RestClient) PutContext(ctx context.Context, url string, data []byte) (*RestResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewBuffer(data))
 	if err != nil {
-		Logger.Error("Delete request failed")
-		return nil, EZMQX_REST_ERROR
-	}
-	resData, error := ioutil.ReadAll(response.Body)
-	if error != nil {
-		Logger.Error("Failed to read response body")
-		return nil, EZMQX_REST_ERROR
+		Logger.Error("Form put request failed")
+		return nil, newURLError(EZMQX_REST_ERROR, "PUT", url, err)
 	}
-	res := GetRestResponse(response.StatusCode, resData)
-	return res, EZMQX_OK
+	return instance.do(req, true)
 }
 
-func (instance *RestClient) Post(url string, data []byte) (*RestResponse, EZMQXErrorCode) {
-	response, err := instance.client.Post(url, APPLICATION_JSON, bytes.NewBuffer(data))
+// Send POST request, which is cancelled when ctx is done.
+func (instance *RestClient) PostContext(ctx context.Context, url string, data []byte) (*RestResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(data))
 	if err != nil {
-		Logger.Error("Post request failed")
-		return nil, EZMQX_REST_ERROR
-	}
-	resData, error := ioutil.ReadAll(response.Body)
-	if error != nil {
-		Logger.Error("Failed to read response body")
-		return nil, EZMQX_REST_ERROR
+		Logger.Error("Form post request failed")
+		return nil, newURLError(EZMQX_REST_ERROR, "POST", url, err)
 	}
-	res := GetRestResponse(response.StatusCode, resData)
-	return res, EZMQX_OK
+	req.Header.Set("Content-Type", APPLICATION_JSON)
+	return instance.do(req, true)
 }
 
-func (instance *RestClient) Delete(url string, data []byte) (*RestResponse, EZMQXErrorCode) {
-	req, err := http.NewRequest("DELETE", url, nil)
+// Send DELETE request, which is cancelled when ctx is done.
+func (instance *RestClient) DeleteContext(ctx context.Context, url string, data []byte) (*RestResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
 	if err != nil {
 		Logger.Error("Form delete request failed")
-		return nil, EZMQX_REST_ERROR
-	}
-	response, err := instance.client.Do(req)
-	if err != nil {
-		Logger.Error("Delete request failed")
-		return nil, EZMQX_REST_ERROR
+		return nil, newURLError(EZMQX_REST_ERROR, "DELETE", url, err)
 	}
-	res := GetRestResponse(response.StatusCode, nil)
-	return res, EZMQX_OK
+	return instance.do(req, false)
 }
@@ -17,7 +17,10 @@
 
 package ezmqx
 
-import "time"
+import (
+	"net/http"
+	"time"
+)
 
 type RestClientFactory struct {
 }
@@ -26,3 +29,8 @@ func (instance RestClientFactory) GetRestClient(timeout time.Duration) RestClien
 	client := GetRestClient(timeout)
 	return client
 }
+
+func (instance RestClientFactory) GetRestClientWithTransport(timeout time.Duration, transport http.RoundTripper, authorization string) RestClientInterface {
+	client := GetRestClientWithTransport(timeout, transport, authorization)
+	return client
+}
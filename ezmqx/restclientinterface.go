@@ -17,9 +17,21 @@
 
 package ezmqx
 
+import "context"
+
 type RestClientInterface interface {
 	Get(url string) (*RestResponse, EZMQXErrorCode)
 	Put(url string, data []byte) (*RestResponse, EZMQXErrorCode)
 	Post(url string, data []byte) (*RestResponse, EZMQXErrorCode)
 	Delete(url string, data []byte) (*RestResponse, EZMQXErrorCode)
 }
+
+// REST client which returns EZMQXError with cause of failure and whose requests
+// are cancelled when ctx is done. If a client implements it, RestFactory uses
+// it instead of RestClientInterface.
+type RestClientContextInterface interface {
+	GetContext(ctx context.Context, url string) (*RestResponse, error)
+	PutContext(ctx context.Context, url string, data []byte) (*RestResponse, error)
+	PostContext(ctx context.Context, url string, data []byte) (*RestResponse, error)
+	DeleteContext(ctx context.Context, url string, data []byte) (*RestResponse, error)
+}
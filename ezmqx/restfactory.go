@@ -17,49 +17,206 @@
 
 package ezmqx
 
-import "time"
-
-var restFactoryInstance *RestFactory
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"time"
+)
 
 type RestFactory struct {
-	restInterface RestClientFactoryInterface
-	timeout       time.Duration
+	restInterface    RestClientFactoryInterface
+	timeout          time.Duration
+	tlsConfig        *tls.Config
+	authorization    string
+	isHTTPS          bool
+	transportOptions EZMQXTransportOptions
+	transport        *http.Transport
+	metrics          *metrics
+	mutex            *sync.RWMutex
 }
 
+// Get REST factory of the default EZMQX context.
 func GetRestFactory() *RestFactory {
-	if nil == restFactoryInstance {
-		restFactoryInstance = &RestFactory{}
-		restFactoryInstance.restInterface = RestClientFactory{}
-		restFactoryInstance.timeout = time.Duration(CONNECTION_TIMEOUT * time.Second)
-	}
-	return restFactoryInstance
+	return getContextInstance().GetRestFactory()
+}
+
+func newRestFactory(factory RestClientFactoryInterface, metrics *metrics) *RestFactory {
+	var instance *RestFactory
+	instance = &RestFactory{}
+	instance.restInterface = factory
+	instance.metrics = metrics
+	instance.timeout = time.Duration(CONNECTION_TIMEOUT * time.Second)
+	instance.transport = newTransport(instance.transportOptions, nil)
+	instance.mutex = &sync.RWMutex{}
+	return instance
 }
 
 func (instance *RestFactory) SetFactory(factory RestClientFactoryInterface) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
 	instance.restInterface = factory
 }
 
+func (instance *RestFactory) setSecurity(security EZMQXRestSecurity) error {
+	tlsConfig, err := security.getTLSConfig()
+	if err != nil {
+		return err
+	}
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	instance.tlsConfig = tlsConfig
+	instance.authorization = security.getAuthorization()
+	instance.isHTTPS = security.isHTTPS()
+	instance.resetTransport()
+	return nil
+}
+
+func (instance *RestFactory) setTransportOptions(options EZMQXTransportOptions) error {
+	err := validateTransportOptions(&options)
+	if err != nil {
+		return err
+	}
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	instance.transportOptions = options
+	instance.resetTransport()
+	return nil
+}
+
+// Replace shared transport with new options, mutex should be locked.
+// Idle connections of the old transport are closed.
+func (instance *RestFactory) resetTransport() {
+	instance.transport.CloseIdleConnections()
+	instance.transport = newTransport(instance.transportOptions, instance.tlsConfig)
+}
+
+// Close idle connections of shared transport.
+func (instance *RestFactory) closeIdleConnections() {
+	instance.mutex.RLock()
+	defer instance.mutex.RUnlock()
+	instance.transport.CloseIdleConnections()
+}
+
+// Get scheme prefix of TNS address found in docker mode.
+func (instance *RestFactory) getSchemePrefix() string {
+	instance.mutex.RLock()
+	defer instance.mutex.RUnlock()
+	if instance.isHTTPS {
+		return HTTPS_PREFIX
+	}
+	return HTTP_PREFIX
+}
+
+// Get REST client with shared transport and security options, if factory supports them.
+func (instance *RestFactory) getRestClient(timeout time.Duration) RestClientInterface {
+	instance.mutex.RLock()
+	defer instance.mutex.RUnlock()
+	factory, ok := instance.restInterface.(RestClientTransportFactoryInterface)
+	if ok {
+		return factory.GetRestClientWithTransport(timeout, instance.transport, instance.authorization)
+	}
+	return instance.restInterface.GetRestClient(timeout)
+}
+
+// Count request in metrics of context, if any.
+func (instance *RestFactory) addRequestMetrics(method string, url string, start time.Time, response *RestResponse, err error) {
+	if nil == instance.metrics {
+		return
+	}
+	statusCode := 0
+	if nil != response {
+		statusCode = response.GetStatusCode()
+	}
+	instance.metrics.addRequest(method, url, statusCode, time.Since(start))
+}
+
+// Send request with client of factory. Requests of clients which implement only
+// RestClientInterface are not cancelled once sent.
+func (instance *RestFactory) send(ctx context.Context, method string, url string, data []byte, timeout time.Duration) (*RestResponse, error) {
+	restClient := instance.getRestClient(timeout)
+	start := time.Now()
+	var response *RestResponse
+	var err error
+	if contextClient, ok := restClient.(RestClientContextInterface); ok {
+		switch method {
+		case "GET":
+			response, err = contextClient.GetContext(ctx, url)
+		case "PUT":
+			response, err = contextClient.PutContext(ctx, url, data)
+		case "POST":
+			response, err = contextClient.PostContext(ctx, url, data)
+		default:
+			response, err = contextClient.DeleteContext(ctx, url, data)
+		}
+	} else if err = ctx.Err(); err != nil {
+		err = newURLError(EZMQX_REST_ERROR, method, url, err)
+	} else {
+		var result EZMQXErrorCode
+		switch method {
+		case "GET":
+			response, result = restClient.Get(url)
+		case "PUT":
+			response, result = restClient.Put(url, data)
+		case "POST":
+			response, result = restClient.Post(url, data)
+		default:
+			response, result = restClient.Delete(url, data)
+		}
+		if result != EZMQX_OK {
+			err = newURLError(result, method, url, errRequestFailed)
+		}
+	}
+	instance.addRequestMetrics(method, url, start, response, err)
+	return response, err
+}
+
 func (instance *RestFactory) Get(url string) (*RestResponse, EZMQXErrorCode) {
-	restClient := instance.restInterface.GetRestClient(instance.timeout)
-	return restClient.Get(url)
+	response, err := instance.GetContext(context.Background(), url)
+	return response, ErrorCodeOf(err)
+}
+
+// Send GET request, which is cancelled when ctx is done.
+// It returns EZMQXError with cause of failure.
+func (instance *RestFactory) GetContext(ctx context.Context, url string) (*RestResponse, error) {
+	return instance.send(ctx, "GET", url, nil, instance.timeout)
 }
 
 func (instance *RestFactory) Put(url string, data []byte) (*RestResponse, EZMQXErrorCode) {
-	restClient := instance.restInterface.GetRestClient(instance.timeout)
-	return restClient.Put(url, data)
+	response, err := instance.PutContext(context.Background(), url, data)
+	return response, ErrorCodeOf(err)
+}
+
+// Send PUT request, which is cancelled when ctx is done.
+// It returns EZMQXError with cause of failure.
+func (instance *RestFactory) PutContext(ctx context.Context, url string, data []byte) (*RestResponse, error) {
+	return instance.send(ctx, "PUT", url, data, instance.timeout)
 }
 
 func (instance *RestFactory) Post(url string, data []byte) (*RestResponse, EZMQXErrorCode) {
-	restClient := instance.restInterface.GetRestClient(instance.timeout)
-	return restClient.Post(url, data)
+	response, err := instance.PostContext(context.Background(), url, data)
+	return response, ErrorCodeOf(err)
+}
+
+// Send POST request, which is cancelled when ctx is done.
+// It returns EZMQXError with cause of failure.
+func (instance *RestFactory) PostContext(ctx context.Context, url string, data []byte) (*RestResponse, error) {
+	return instance.send(ctx, "POST", url, data, instance.timeout)
 }
 
 func (instance *RestFactory) Post1(url string, data []byte, timeout time.Duration) (*RestResponse, EZMQXErrorCode) {
-	restClient := instance.restInterface.GetRestClient(timeout)
-	return restClient.Post(url, data)
+	response, err := instance.send(context.Background(), "POST", url, data, timeout)
+	return response, ErrorCodeOf(err)
 }
 
 func (instance *RestFactory) Delete(url string, data []byte) (*RestResponse, EZMQXErrorCode) {
-	restClient := instance.restInterface.GetRestClient(instance.timeout)
-	return restClient.Delete(url, data)
+	response, err := instance.DeleteContext(context.Background(), url, data)
+	return response, ErrorCodeOf(err)
+}
+
+// Send DELETE request, which is cancelled when ctx is done.
+// It returns EZMQXError with cause of failure.
+func (instance *RestFactory) DeleteContext(ctx context.Context, url string, data []byte) (*RestResponse, error) {
+	return instance.send(ctx, "DELETE", url, data, instance.timeout)
 }
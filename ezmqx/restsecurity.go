@@ -0,0 +1,95 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+)
+
+// Security options of REST requests to TNS and pharos.
+//
+// TLS is used for URLs with https scheme. Authorization header is sent with
+// every request if BearerToken or Username is set.
+type EZMQXRestSecurity struct {
+	// PEM file of CA certificates to verify server.
+	// If empty, CA certificates of system are used.
+	CAFile string
+	// PEM files of client certificate and key for mutual TLS.
+	CertFile string
+	KeyFile  string
+	// Name to verify server certificate, if it differs from host of URL.
+	ServerName string
+	// Skip verification of server certificate. It should be used only for test.
+	InsecureSkipVerify bool
+	// Use https scheme for TNS address found in docker mode.
+	// It is implied by CAFile, CertFile and InsecureSkipVerify.
+	UseHTTPS bool
+	// Token sent as bearer token.
+	BearerToken string
+	// User name and password for basic authentication.
+	// They are used only if BearerToken is empty.
+	Username string
+	Password string
+}
+
+// Check whether https scheme is used for TNS address found in docker mode.
+func (security *EZMQXRestSecurity) isHTTPS() bool {
+	return security.UseHTTPS || len(security.CAFile) > 0 || len(security.CertFile) > 0 || security.InsecureSkipVerify
+}
+
+// Get TLS configuration, it is nil if TLS is not configured.
+func (security *EZMQXRestSecurity) getTLSConfig() (*tls.Config, error) {
+	if !security.isHTTPS() && 0 == len(security.ServerName) {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{ServerName: security.ServerName, InsecureSkipVerify: security.InsecureSkipVerify}
+	if len(security.CAFile) > 0 {
+		data, err := ioutil.ReadFile(security.CAFile)
+		if err != nil {
+			return nil, newError(EZMQX_INVALID_PARAM, "set REST security", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, newError(EZMQX_INVALID_PARAM, "set REST security", errors.New("no certificate in CA file"))
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(security.CertFile) > 0 || len(security.KeyFile) > 0 {
+		certificate, err := tls.LoadX509KeyPair(security.CertFile, security.KeyFile)
+		if err != nil {
+			return nil, newError(EZMQX_INVALID_PARAM, "set REST security", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// Get value of Authorization header, it is empty if authorization is not configured.
+func (security *EZMQXRestSecurity) getAuthorization() string {
+	if len(security.BearerToken) > 0 {
+		return AUTH_BEARER + security.BearerToken
+	}
+	if len(security.Username) > 0 {
+		return AUTH_BASIC + base64.StdEncoding.EncodeToString([]byte(security.Username+COLON+security.Password))
+	}
+	return ""
+}
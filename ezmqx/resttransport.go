@@ -0,0 +1,87 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+const DEFAULT_MAX_IDLE_CONNS = 100
+const DEFAULT_MAX_IDLE_CONNS_PER_HOST = 10
+const DEFAULT_IDLE_CONN_TIMEOUT = 90 * time.Second
+
+// Options of HTTP transport shared by REST requests of a context.
+// Zero value of a field means its default.
+type EZMQXTransportOptions struct {
+	// Maximum number of idle connections. If 0, DEFAULT_MAX_IDLE_CONNS is used.
+	MaxIdleConns int
+	// Maximum number of idle connections per host.
+	// If 0, DEFAULT_MAX_IDLE_CONNS_PER_HOST is used.
+	MaxIdleConnsPerHost int
+	// Maximum number of connections per host. 0 means no limit.
+	MaxConnsPerHost int
+	// Time to keep idle connection. If 0, DEFAULT_IDLE_CONN_TIMEOUT is used.
+	IdleConnTimeout time.Duration
+}
+
+// Factory of REST clients which share HTTP transport of context.
+// If factory of context implements it, it is used instead of GetRestClient.
+type RestClientTransportFactoryInterface interface {
+	GetRestClientWithTransport(timeout time.Duration, transport http.RoundTripper, authorization string) RestClientInterface
+}
+
+// Transport used by REST clients which are not created by a context.
+var defaultTransport = newTransport(EZMQXTransportOptions{}, nil)
+
+func validateTransportOptions(options *EZMQXTransportOptions) error {
+	if options.MaxIdleConns < 0 || options.MaxIdleConnsPerHost < 0 || options.MaxConnsPerHost < 0 || options.IdleConnTimeout < 0 {
+		return newError(EZMQX_INVALID_PARAM, "set transport options", errors.New("negative option"))
+	}
+	if 0 == options.MaxIdleConns {
+		options.MaxIdleConns = DEFAULT_MAX_IDLE_CONNS
+	}
+	if 0 == options.MaxIdleConnsPerHost {
+		options.MaxIdleConnsPerHost = DEFAULT_MAX_IDLE_CONNS_PER_HOST
+	}
+	if 0 == options.IdleConnTimeout {
+		options.IdleConnTimeout = DEFAULT_IDLE_CONN_TIMEOUT
+	}
+	return nil
+}
+
+func newTransport(options EZMQXTransportOptions, tlsConfig *tls.Config) *http.Transport {
+	validateTransportOptions(&options)
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   CONNECTION_TIMEOUT * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   CONNECTION_TIMEOUT * time.Second,
+		MaxIdleConns:          options.MaxIdleConns,
+		MaxIdleConnsPerHost:   options.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       options.MaxConnsPerHost,
+		IdleConnTimeout:       options.IdleConnTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
@@ -36,6 +36,8 @@ func printTopicError() {
 	fmt.Printf("\n      ./topicdiscovery -t /topic -tns 192.168.1.1\n")
 	fmt.Printf("\n  (2)  For running in docker mode: ")
 	fmt.Printf("\n      ./topicdiscovery -t /topic \n")
+	fmt.Printf("\n  (3)  For printing topic hierarchy of whole TNS: ")
+	fmt.Printf("\n      ./topicdiscovery -t / -tns 192.168.1.1 -tree\n")
 	fmt.Printf("\nNote: docker mode will work only when sample is running in docker container\n")
 	os.Exit(-1)
 }
@@ -55,6 +57,25 @@ func printTopicList(topicList list.List) {
 	}
 }
 
+func printTopicTree(root *ezmqx.EZMQXTopicNode) {
+	fmt.Printf("Total topics: %d\n", root.GetTopicCount())
+	root.Walk(func(node *ezmqx.EZMQXTopicNode, depth int) bool {
+		name := node.GetLevel()
+		if 0 == depth {
+			name = node.GetName()
+		}
+		line := strings.Repeat("  ", depth) + name
+		if topic := node.GetTopic(); nil != topic {
+			line += " [" + topic.GetEndPoint().ToString() + "]"
+		}
+		if !node.IsLeaf() {
+			line += fmt.Sprintf(" (%d)", node.GetTopicCount())
+		}
+		fmt.Println(line)
+		return true
+	})
+}
+
 func getTNSAddress(tnsAddress string) string {
 	return "http://" + tnsAddress + ":80/tns-server"
 }
@@ -64,9 +85,10 @@ func main() {
 	var topic string
 	var configInstance *ezmqx.EZMQXConfig = nil
 	var isStandAlone bool
+	var isTree bool
 
 	// get port/topic from command line arguments
-	if len(os.Args) != 3 && len(os.Args) != 5 {
+	if len(os.Args) < 3 || len(os.Args) > 6 {
 		printTopicError()
 	}
 	for n := 1; n < len(os.Args); n++ {
@@ -79,6 +101,8 @@ func main() {
 			topic = os.Args[n+1]
 			fmt.Println("Topic is : ", topic)
 			n = n + 1
+		} else if 0 == strings.Compare(os.Args[n], "-tree") {
+			isTree = true
 		} else {
 			printTopicError()
 		}
@@ -103,12 +127,21 @@ func main() {
 		fmt.Println("Docker mode started")
 	}
 	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
-	topicList, errorCode := topicDiscovery.HierarchicalQuery(topic)
-	fmt.Println("Topic discovery query respone: ", errorCode)
-	if errorCode == ezmqx.EZMQX_OK {
-		printTopicList(*topicList)
+	if true == isTree {
+		root, errorCode := topicDiscovery.QueryTree(topic)
+		fmt.Println("Topic tree query respone: ", errorCode)
+		if errorCode != ezmqx.EZMQX_OK {
+			os.Exit(-1)
+		}
+		printTopicTree(root)
 	} else {
-		os.Exit(-1)
+		topicList, errorCode := topicDiscovery.HierarchicalQuery(topic)
+		fmt.Println("Topic discovery query respone: ", errorCode)
+		if errorCode == ezmqx.EZMQX_OK {
+			printTopicList(*topicList)
+		} else {
+			os.Exit(-1)
+		}
 	}
 
 	// Wait for 5 minutes before exit [For docker mode].
@@ -0,0 +1,66 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+// Command tnsserver runs TNS [Topic name server] for ezmqx publishers and
+// subscribers, e.g. in standalone mode without Pharos.
+//
+//	tnsserver -addr :48323 -ka_interval 30
+package main
+
+import (
+	"flag"
+	"go/ezmqx_tns"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":48323", "address to listen on")
+	keepAliveInterval := flag.Int("ka_interval", ezmqx_tns.DEFAULT_KEEPALIVE_INTERVAL, "keep alive interval of publishers in seconds")
+	expiryTimeout := flag.Duration("expiry", 0, "expiry timeout of topics without keep alive (default: 3 * ka_interval)")
+	pathPrefix := flag.String("prefix", "", "path prefix of URLs, e.g. /tns-server")
+	flag.Parse()
+
+	server, err := ezmqx_tns.NewTNSServer(ezmqx_tns.TNSServerOptions{
+		KeepAliveInterval: *keepAliveInterval,
+		ExpiryTimeout:     *expiryTimeout,
+		PathPrefix:        *pathPrefix,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	httpServer := &http.Server{Addr: *addr, Handler: server}
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		httpServer.Close()
+	}()
+
+	log.Printf("TNS server listening on %s, ka_interval: %s", *addr, time.Duration(*keepAliveInterval)*time.Second)
+	err = httpServer.ListenAndServe()
+	// Stop expiry routine of TNS server before exiting
+	server.Close()
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,393 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+// Package ezmqx_tns implements TNS [Topic name server] server, which can be
+// embedded in any HTTP server or run by cmd/tnsserver.
+//
+// It serves the protocol used by ezmqx library:
+//
+//	POST   /api/v1/tns/topic                              Register topic
+//	GET    /api/v1/tns/topic?name=<topic>&hierarchical=yes Query topic
+//	DELETE /api/v1/tns/topic?name=<topic>                 Unregister topic
+//	POST   /api/v1/tns/keepalive                          Keep alive of topics
+//
+// Query accepts optional filters: datamodel=<id>, secured=yes|no, host=<address>
+// and tag=<key>:<value>, which can be repeated. Name / with hierarchical=yes
+// queries all the topics. With offset=<n> or limit=<n>, a page of topics sorted
+// by name is returned with total number of topics in "total".
+// Topics whose keep alive is not received within expiry timeout are removed.
+package ezmqx_tns
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// URLs
+const TOPIC_PATH = "/api/v1/tns/topic"
+const KEEPALIVE_PATH = "/api/v1/tns/keepalive"
+const QUERY_NAME = "name"
+const QUERY_HIERARCHICAL = "hierarchical"
+const QUERY_TRUE = "yes"
+const QUERY_DATAMODEL = "datamodel"
+const QUERY_SECURED = "secured"
+const QUERY_HOST = "host"
+const QUERY_TAG = "tag"
+const TAG_SEPARATOR = ":"
+const QUERY_OFFSET = "offset"
+const QUERY_LIMIT = "limit"
+
+// JSON Keys
+const PAYLOAD_TOPIC = "topic"
+const PAYLOAD_TOPICS = "topics"
+const PAYLOAD_TOTAL = "total"
+const PAYLOAD_NAME = "name"
+const PAYLOAD_ENDPOINT = "endpoint"
+const PAYLOAD_DATAMODEL = "datamodel"
+const PAYLOAD_SECURED = "secured"
+const PAYLOAD_KEEPALIVE_INTERVAL = "ka_interval"
+const PAYLOAD_TOPIC_KA = "topic_names"
+
+// Default keep alive interval in seconds.
+const DEFAULT_KEEPALIVE_INTERVAL = 30
+
+// Default expiry timeout is multiple of keep alive interval.
+const DEFAULT_EXPIRY_FACTOR = 3
+
+// Minimum expiry timeout, expired topics are checked every half of it.
+const MIN_EXPIRY_TIMEOUT = 10 * time.Millisecond
+
+const F_SLASH = "/"
+const TOPIC_ROOT = "/"
+
+// Options for TNS server.
+//
+// KeepAliveInterval is sent to publishers in register response as ka_interval
+// [seconds], if it is 0 then DEFAULT_KEEPALIVE_INTERVAL is used.
+// ExpiryTimeout is the time after the last keep alive of a topic until the
+// topic is removed, if it is 0 then DEFAULT_EXPIRY_FACTOR times keep alive
+// interval is used. It should not be less than MIN_EXPIRY_TIMEOUT.
+// PathPrefix is prepended to the URLs, e.g. /tns-server behind reverse proxy.
+type TNSServerOptions struct {
+	KeepAliveInterval int
+	ExpiryTimeout     time.Duration
+	PathPrefix        string
+}
+
+// Structure represents topic registered in TNS server.
+// Metadata fields are optional and returned as registered.
+type TNSTopic struct {
+	Name          string            `json:"name"`
+	DataModel     string            `json:"datamodel"`
+	EndPoint      string            `json:"endpoint"`
+	Secured       bool              `json:"secured"`
+	Description   string            `json:"description,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`
+	Owner         string            `json:"owner,omitempty"`
+	SchemaVersion string            `json:"schema_version,omitempty"`
+	Rate          float64           `json:"rate,omitempty"`
+}
+
+// Structure represents TNS server.
+// It implements http.Handler.
+type TNSServer struct {
+	options   TNSServerOptions
+	topics    map[string]*registeredTopic
+	mutex     *sync.Mutex
+	mux       *http.ServeMux
+	stopChan  chan struct{}
+	closeOnce *sync.Once
+}
+
+type registeredTopic struct {
+	topic         TNSTopic
+	lastKeepAlive time.Time
+}
+
+// Create TNS server.
+// It starts a go routine to remove expired topics, which is stopped by Close.
+func NewTNSServer(options TNSServerOptions) (*TNSServer, error) {
+	if options.KeepAliveInterval < 0 || options.ExpiryTimeout < 0 {
+		return nil, errors.New("invalid keep alive interval or expiry timeout")
+	}
+	if options.ExpiryTimeout > 0 && options.ExpiryTimeout < MIN_EXPIRY_TIMEOUT {
+		return nil, errors.New("expiry timeout is less than MIN_EXPIRY_TIMEOUT")
+	}
+	if 0 == options.KeepAliveInterval {
+		options.KeepAliveInterval = DEFAULT_KEEPALIVE_INTERVAL
+	}
+	if 0 == options.ExpiryTimeout {
+		options.ExpiryTimeout = time.Duration(options.KeepAliveInterval*DEFAULT_EXPIRY_FACTOR) * time.Second
+	}
+	options.PathPrefix = strings.TrimSuffix(options.PathPrefix, F_SLASH)
+	var instance *TNSServer
+	instance = &TNSServer{}
+	instance.options = options
+	instance.topics = make(map[string]*registeredTopic)
+	instance.mutex = &sync.Mutex{}
+	instance.stopChan = make(chan struct{})
+	instance.closeOnce = &sync.Once{}
+	instance.mux = http.NewServeMux()
+	instance.mux.HandleFunc(options.PathPrefix+TOPIC_PATH, instance.handleTopic)
+	instance.mux.HandleFunc(options.PathPrefix+KEEPALIVE_PATH, instance.handleKeepAlive)
+	go instance.expire()
+	return instance, nil
+}
+
+// Serve TNS request.
+func (instance *TNSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	instance.mux.ServeHTTP(w, r)
+}
+
+// Stop go routine of TNS server.
+func (instance *TNSServer) Close() {
+	instance.closeOnce.Do(func() {
+		close(instance.stopChan)
+	})
+}
+
+// Get registered topics sorted by name.
+func (instance *TNSServer) GetTopics() []TNSTopic {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	topics := make([]TNSTopic, 0, len(instance.topics))
+	for _, registered := range instance.topics {
+		topics = append(topics, registered.topic)
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].Name < topics[j].Name })
+	return topics
+}
+
+// Remove topics whose keep alive is expired.
+// It returns number of removed topics.
+func (instance *TNSServer) ExpireTopics() int {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	now := time.Now()
+	count := 0
+	for name, registered := range instance.topics {
+		if now.Sub(registered.lastKeepAlive) > instance.options.ExpiryTimeout {
+			delete(instance.topics, name)
+			count++
+		}
+	}
+	return count
+}
+
+func (instance *TNSServer) expire() {
+	ticker := time.NewTicker(instance.options.ExpiryTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-instance.stopChan:
+			return
+		case <-ticker.C:
+			instance.ExpireTopics()
+		}
+	}
+}
+
+func (instance *TNSServer) handleTopic(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		instance.register(w, r)
+	case http.MethodGet:
+		instance.query(w, r)
+	case http.MethodDelete:
+		instance.unregister(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (instance *TNSServer) register(w http.ResponseWriter, r *http.Request) {
+	payload := make(map[string]*TNSTopic)
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, nil)
+		return
+	}
+	topic, exists := payload[PAYLOAD_TOPIC]
+	if !exists || nil == topic || !validateTopic(topic.Name) || 0 == len(topic.DataModel) || 0 == len(topic.EndPoint) {
+		writeJSON(w, http.StatusBadRequest, nil)
+		return
+	}
+	instance.mutex.Lock()
+	registered, exists := instance.topics[topic.Name]
+	if exists && registered.topic.EndPoint != topic.EndPoint {
+		instance.mutex.Unlock()
+		writeJSON(w, http.StatusConflict, nil)
+		return
+	}
+	instance.topics[topic.Name] = &registeredTopic{topic: *topic, lastKeepAlive: time.Now()}
+	instance.mutex.Unlock()
+	writeJSON(w, http.StatusCreated, map[string]int{PAYLOAD_KEEPALIVE_INTERVAL: instance.options.KeepAliveInterval})
+}
+
+func (instance *TNSServer) query(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get(QUERY_NAME)
+	isHierarchical := r.URL.Query().Get(QUERY_HIERARCHICAL) == QUERY_TRUE
+	isRoot := isHierarchical && TOPIC_ROOT == name
+	if !validateTopic(name) && !isRoot {
+		writeJSON(w, http.StatusBadRequest, nil)
+		return
+	}
+	topics := make([]TNSTopic, 0)
+	for _, topic := range instance.GetTopics() {
+		if !isRoot && topic.Name != name && !(isHierarchical && strings.HasPrefix(topic.Name, name+F_SLASH)) {
+			continue
+		}
+		if matchFilters(topic, r.URL.Query()) {
+			topics = append(topics, topic)
+		}
+	}
+	offset, limit, isPaged, err := getPage(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, nil)
+		return
+	}
+	if !isPaged {
+		writeJSON(w, http.StatusOK, map[string][]TNSTopic{PAYLOAD_TOPICS: topics})
+		return
+	}
+	total := len(topics)
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{PAYLOAD_TOPICS: topics[offset:end], PAYLOAD_TOTAL: total})
+}
+
+// Get offset and limit of paged query. Limit 0 means no limit.
+func getPage(query url.Values) (int, int, bool, error) {
+	offsetValue := query.Get(QUERY_OFFSET)
+	limitValue := query.Get(QUERY_LIMIT)
+	if 0 == len(offsetValue) && 0 == len(limitValue) {
+		return 0, 0, false, nil
+	}
+	var offset, limit int
+	var err error
+	if len(offsetValue) > 0 {
+		offset, err = strconv.Atoi(offsetValue)
+		if err != nil || offset < 0 {
+			return 0, 0, false, errors.New("invalid offset")
+		}
+	}
+	if len(limitValue) > 0 {
+		limit, err = strconv.Atoi(limitValue)
+		if err != nil || limit < 0 {
+			return 0, 0, false, errors.New("invalid limit")
+		}
+	}
+	return offset, limit, true, nil
+}
+
+// Check whether topic matches optional filters of query.
+func matchFilters(topic TNSTopic, query url.Values) bool {
+	if dataModel := query.Get(QUERY_DATAMODEL); len(dataModel) > 0 && dataModel != topic.DataModel {
+		return false
+	}
+	if secured := query.Get(QUERY_SECURED); len(secured) > 0 && (secured == QUERY_TRUE) != topic.Secured {
+		return false
+	}
+	if host := query.Get(QUERY_HOST); len(host) > 0 && host != topic.EndPoint && !strings.HasPrefix(topic.EndPoint, host+":") {
+		return false
+	}
+	for _, tag := range query[QUERY_TAG] {
+		keyValue := strings.SplitN(tag, TAG_SEPARATOR, 2)
+		if 2 != len(keyValue) {
+			return false
+		}
+		value, exists := topic.Tags[keyValue[0]]
+		if !exists || value != keyValue[1] {
+			return false
+		}
+	}
+	return true
+}
+
+func (instance *TNSServer) unregister(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get(QUERY_NAME)
+	instance.mutex.Lock()
+	_, exists := instance.topics[name]
+	delete(instance.topics, name)
+	instance.mutex.Unlock()
+	if !exists {
+		writeJSON(w, http.StatusNotFound, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, nil)
+}
+
+// Refresh keep alive of topics.
+// If some of topics are not registered, it responds with not found status and
+// names of those topics, so that publisher can register them again.
+func (instance *TNSServer) handleKeepAlive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	payload := make(map[string][]string)
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, nil)
+		return
+	}
+	unknown := make([]string, 0)
+	now := time.Now()
+	instance.mutex.Lock()
+	for _, name := range payload[PAYLOAD_TOPIC_KA] {
+		registered, exists := instance.topics[name]
+		if !exists {
+			unknown = append(unknown, name)
+			continue
+		}
+		registered.lastKeepAlive = now
+	}
+	instance.mutex.Unlock()
+	if 0 != len(unknown) {
+		writeJSON(w, http.StatusNotFound, map[string][]string{PAYLOAD_TOPIC_KA: unknown})
+		return
+	}
+	writeJSON(w, http.StatusOK, nil)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if nil == payload {
+		payload = struct{}{}
+	}
+	json.NewEncoder(w).Encode(payload)
+}
+
+func validateTopic(topic string) bool {
+	if !strings.HasPrefix(topic, F_SLASH) || strings.HasSuffix(topic, F_SLASH) {
+		return false
+	}
+	return !strings.Contains(topic, F_SLASH+F_SLASH)
+}
@@ -18,8 +18,15 @@
 package ezmqx_unittests
 
 import (
+	"bytes"
 	"go/ezmqx"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 const TEST_URL = "localhost:80/test"
@@ -54,3 +61,81 @@ func TestDeleteRequestN(t *testing.T) {
 	factory.SetFactory(ezmqx.RestClientFactory{})
 	factory.Delete(TEST_URL, nil)
 }
+
+// REST client of previous implementation, which creates a transport for every
+// request and does not close response body.
+type legacyRestClient struct {
+	client http.Client
+}
+
+type legacyRestClientFactory struct {
+}
+
+func (instance legacyRestClientFactory) GetRestClient(timeout time.Duration) ezmqx.RestClientInterface {
+	return &legacyRestClient{client: http.Client{Transport: &http.Transport{}, Timeout: timeout}}
+}
+
+func (instance *legacyRestClient) send(method string, url string, data []byte) (*ezmqx.RestResponse, ezmqx.EZMQXErrorCode) {
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, ezmqx.EZMQX_REST_ERROR
+	}
+	response, err := instance.client.Do(req)
+	if err != nil {
+		return nil, ezmqx.EZMQX_REST_ERROR
+	}
+	resData, _ := ioutil.ReadAll(response.Body)
+	return ezmqx.GetRestResponse(response.StatusCode, resData), ezmqx.EZMQX_OK
+}
+
+func (instance *legacyRestClient) Get(url string) (*ezmqx.RestResponse, ezmqx.EZMQXErrorCode) {
+	return instance.send("GET", url, nil)
+}
+
+func (instance *legacyRestClient) Put(url string, data []byte) (*ezmqx.RestResponse, ezmqx.EZMQXErrorCode) {
+	return instance.send("PUT", url, data)
+}
+
+func (instance *legacyRestClient) Post(url string, data []byte) (*ezmqx.RestResponse, ezmqx.EZMQXErrorCode) {
+	return instance.send("POST", url, data)
+}
+
+func (instance *legacyRestClient) Delete(url string, data []byte) (*ezmqx.RestResponse, ezmqx.EZMQXErrorCode) {
+	return instance.send("DELETE", url, data)
+}
+
+// Send keep alive requests as topic handler does, and report new connections per request.
+func benchmarkKeepAlive(b *testing.B, factory ezmqx.RestClientFactoryInterface) {
+	var connections int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if http.StateNew == state {
+			atomic.AddInt64(&connections, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	ezmqxCtx := ezmqx.NewEZMQXContext(ezmqx.EZMQXContextOptions{RestClientFactory: factory})
+	restFactory := ezmqxCtx.GetRestFactory()
+	payload := []byte(`{"topic_names":["/topic"]}`)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		response, result := restFactory.Post1(server.URL+"/api/v1/tns/keepalive", payload, time.Second)
+		if ezmqx.EZMQX_OK != result || http.StatusOK != response.GetStatusCode() {
+			b.Fatalf("Keep alive failed: %v", result)
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(atomic.LoadInt64(&connections))/float64(b.N), "conns/op")
+}
+
+func BenchmarkKeepAliveSharedTransport(b *testing.B) {
+	benchmarkKeepAlive(b, ezmqx.RestClientFactory{})
+}
+
+func BenchmarkKeepAliveLegacyClient(b *testing.B) {
+	benchmarkKeepAlive(b, legacyRestClientFactory{})
+}
@@ -19,8 +19,12 @@ package ezmqx_unittests
 
 import (
 	"container/list"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"go/aml"
+	"go/ezmq"
 	"go/ezmqx"
 	"go/ezmqx_unittests/utils"
 	"testing"
@@ -40,6 +44,7 @@ func errorCB(topic string, errorCode ezmqx.EZMQXErrorCode) {
 func TestGetAMLStandAloneSubscriber(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	amlFilePath := list.New()
 	amlFilePath.PushBack(utils.AML_FILE_PATH)
 	idList, _ := configInstance.AddAmlModel(*amlFilePath)
@@ -50,12 +55,12 @@ func TestGetAMLStandAloneSubscriber(t *testing.T) {
 		t.Errorf("subscriber is nil")
 	}
 	subscriber.Terminate()
-	configInstance.Reset()
 }
 
 func TestGetAMLStandAloneSubscriber1(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	amlFilePath := list.New()
 	amlFilePath.PushBack(utils.AML_FILE_PATH)
 	idList, _ := configInstance.AddAmlModel(*amlFilePath)
@@ -65,7 +70,6 @@ func TestGetAMLStandAloneSubscriber1(t *testing.T) {
 	if result != ezmqx.EZMQX_INVALID_TOPIC {
 		t.Errorf("subscriber is nil")
 	}
-	configInstance.Reset()
 }
 
 func TestGetSecuredAMLSubscriber(t *testing.T) {
@@ -162,6 +166,7 @@ func TestGetSecuredAMLSubscriber1(t *testing.T) {
 func TestGetSecuredAMLSubscriber2(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	amlFilePath := list.New()
 	amlFilePath.PushBack(utils.AML_FILE_PATH)
 	idList, _ := configInstance.AddAmlModel(*amlFilePath)
@@ -176,12 +181,12 @@ func TestGetSecuredAMLSubscriber2(t *testing.T) {
 		t.Errorf("subscriber is nil")
 	}
 	subscriber.Terminate()
-	configInstance.Reset()
 }
 
 func TestAMLSubscriberStandAlone(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	amlFilePath := list.New()
 	amlFilePath.PushBack(utils.AML_FILE_PATH)
 	idList, _ := configInstance.AddAmlModel(*amlFilePath)
@@ -203,7 +208,6 @@ func TestAMLSubscriberStandAlone(t *testing.T) {
 		t.Errorf("Received less event")
 	}
 	subscriber.Terminate()
-	configInstance.Reset()
 }
 
 func TestAMLSubscriberStandAlone1(t *testing.T) {
@@ -284,6 +288,7 @@ func TestAMLSubDockerMode1(t *testing.T) {
 func TestSubTerminate(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	amlFilePath := list.New()
 	amlFilePath.PushBack(utils.AML_FILE_PATH)
 	idList, _ := configInstance.AddAmlModel(*amlFilePath)
@@ -306,12 +311,12 @@ func TestSubTerminate(t *testing.T) {
 	if result != ezmqx.EZMQX_UNKNOWN_STATE {
 		t.Errorf("Termination failed")
 	}
-	configInstance.Reset()
 }
 
 func TestGetTopics(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	amlFilePath := list.New()
 	amlFilePath.PushBack(utils.AML_FILE_PATH)
 	idList, _ := configInstance.AddAmlModel(*amlFilePath)
@@ -323,5 +328,716 @@ func TestGetTopics(t *testing.T) {
 		t.Errorf("GetTopics failed")
 	}
 	subscriber.Terminate()
-	configInstance.Reset()
+}
+
+func TestAMLChannelSubscriberStandAlone(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	idList, _ := configInstance.AddAmlModel(*amlFilePath)
+	endPoint := ezmqx.GetEZMQXEndPoint1(utils.TEST_LOCAL_HOST, utils.PORT)
+	topic := ezmqx.GetEZMQXTopic(utils.TOPIC, idList.Front().Value.(string), false, endPoint)
+	options := ezmqx.EZMQXChannelOptions{BufferSize: utils.NUMBER_OF_EVENTS, OverflowPolicy: ezmqx.OVERFLOW_DROP_OLDEST}
+	subscriber, err := ezmqx.GetAMLStandAloneSubscriberWithOptions(*topic, ezmqx.EZMQXAMLSubscriberOptions{Channel: &options})
+	if nil != err {
+		t.Fatalf("Get channel subscriber failed: %v", err)
+	}
+
+	// Routine to publish data on socket
+	go utils.Publish()
+
+	received := 0
+	timeout := time.After(20 * time.Second)
+	for received < utils.NUMBER_OF_EVENTS {
+		select {
+		case message := <-subscriber.Messages():
+			if message.Topic != utils.TOPIC || nil == message.Object {
+				t.Errorf("Received invalid message")
+			}
+			received++
+		case <-timeout:
+			t.Fatalf("Received less event: %d", received)
+		}
+	}
+	<-utils.Exit_Chan
+	subscriber.Terminate()
+	if _, ok := <-subscriber.Messages(); ok {
+		t.Errorf("Message channel is not closed after terminate")
+	}
+}
+
+func TestAMLChannelSubscriberNegative(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	idList, _ := configInstance.AddAmlModel(*amlFilePath)
+	endPoint := ezmqx.GetEZMQXEndPoint1(utils.ADDRESS, utils.PORT)
+	topic := ezmqx.GetEZMQXTopic(utils.TOPIC, idList.Front().Value.(string), false, endPoint)
+
+	_, err := ezmqx.GetAMLStandAloneSubscriberWithOptions(*topic,
+		ezmqx.EZMQXAMLSubscriberOptions{Channel: &ezmqx.EZMQXChannelOptions{BufferSize: -1}})
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_INVALID_PARAM)) {
+		t.Errorf("Invalid buffer size is accepted")
+	}
+	_, err = ezmqx.GetAMLStandAloneSubscriberWithOptions(*topic,
+		ezmqx.EZMQXAMLSubscriberOptions{Channel: &ezmqx.EZMQXChannelOptions{OverflowPolicy: 10}})
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_INVALID_PARAM)) {
+		t.Errorf("Invalid overflow policy is accepted")
+	}
+	_, err = ezmqx.GetAMLStandAloneSubscriberWithOptions(*topic, ezmqx.EZMQXAMLSubscriberOptions{SubCallback: amlSubCB,
+		ErrorCallback: errorCB, Channel: &ezmqx.EZMQXChannelOptions{}})
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_INVALID_PARAM)) {
+		t.Errorf("Callbacks with channel option are accepted")
+	}
+	_, err = ezmqx.GetAMLStandAloneSubscriberWithOptions(*topic, ezmqx.EZMQXAMLSubscriberOptions{})
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_INVALID_PARAM)) {
+		t.Errorf("Options without callbacks and channel are accepted")
+	}
+	_, err = ezmqx.GetAMLStandAloneSubscriberWithOptions(*topic, ezmqx.EZMQXAMLSubscriberOptions{SubCallback: amlSubCB,
+		ErrorCallback: errorCB, Watch: &ezmqx.EZMQXWatchOptions{}})
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_INVALID_PARAM)) {
+		t.Errorf("Watch option is accepted in stand alone mode")
+	}
+	// Callback based subscriber does not have channels
+	subscriber, _ := ezmqx.GetAMLStandAloneSubscriber(*topic, amlSubCB, errorCB)
+	if nil != subscriber.Messages() || nil != subscriber.Errors() {
+		t.Errorf("Callback subscriber has channels")
+	}
+	subscriber.Terminate()
+}
+
+func TestAMLSubscribeUnsubscribe(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	idList, _ := configInstance.AddAmlModel(*amlFilePath)
+	endPoint := ezmqx.GetEZMQXEndPoint1(utils.ADDRESS, utils.PORT)
+	topic := ezmqx.GetEZMQXTopic(utils.TOPIC, idList.Front().Value.(string), false, endPoint)
+	subscriber, _ := ezmqx.GetAMLStandAloneSubscriber(*topic, amlSubCB, errorCB)
+	if nil == subscriber {
+		t.Fatalf("subscriber is nil")
+	}
+
+	// Topic on another end point
+	endPoint1 := ezmqx.GetEZMQXEndPoint1(utils.ADDRESS, utils.PORT+1)
+	topic1 := ezmqx.GetEZMQXTopic(utils.TOPIC+"/1", idList.Front().Value.(string), false, endPoint1)
+	if err := subscriber.SubscribeTopic(*topic1); nil != err {
+		t.Errorf("SubscribeTopic failed: %v", err)
+	}
+	// Already subscribed topic is skipped
+	if err := subscriber.SubscribeTopic(*topic1); nil != err {
+		t.Errorf("SubscribeTopic failed for subscribed topic: %v", err)
+	}
+	topics, _ := subscriber.GetTopics()
+	if topics.Len() != 2 {
+		t.Errorf("Topic count mismatch: %d", topics.Len())
+	}
+	if err := subscriber.Unsubscribe(utils.TOPIC + "/1"); nil != err {
+		t.Errorf("Unsubscribe failed: %v", err)
+	}
+	topics, _ = subscriber.GetTopics()
+	if topics.Len() != 1 {
+		t.Errorf("Topic count mismatch: %d", topics.Len())
+	}
+	err := subscriber.Unsubscribe(utils.TOPIC + "/1")
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_UNKNOWN_TOPIC)) {
+		t.Errorf("Unsubscribe for unknown topic: %v", err)
+	}
+	subscriber.Terminate()
+	err = subscriber.Unsubscribe(utils.TOPIC)
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_TERMINATED)) {
+		t.Errorf("Unsubscribe after terminate: %v", err)
+	}
+	err = subscriber.SubscribeTopic(*topic1)
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_TERMINATED)) {
+		t.Errorf("SubscribeTopic after terminate: %v", err)
+	}
+}
+
+func TestAMLSubscribeDockerMode(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	utils.SetRestResponse(utils.CONFIG_URL, []byte(utils.VALID_CONFIG_RESPONSE))
+	utils.SetRestResponse(utils.TNS_INFO_URL, []byte(utils.VALID_TNS_INFO_RESPONSE))
+	utils.SetRestResponse(utils.RUNNING_APPS_URL, []byte(utils.VALID_RUNNING_APPS_RESPONSE))
+	utils.SetRestResponse(utils.RUNNING_APP_INFO_URL, []byte(utils.RUNNING_APP_INFO_RESPONSE))
+	configInstance.StartDockerMode(utils.TNS_CONFIG_FILE_PATH)
+	defer configInstance.Reset()
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	configInstance.AddAmlModel(*amlFilePath)
+	utils.SetRestResponse(utils.SUB_TOPIC_H_URL, []byte(utils.SUB_TOPIC_RESPONSE))
+	subscriber, _ := ezmqx.GetAMLSubscriber(utils.TOPIC, true, amlSubCB, errorCB)
+	if nil == subscriber {
+		t.Fatalf("subscriber is nil")
+	}
+	if err := subscriber.Unsubscribe(utils.TOPIC); nil != err {
+		t.Errorf("Unsubscribe failed: %v", err)
+	}
+	topics, _ := subscriber.GetTopics()
+	if topics.Len() != 0 {
+		t.Errorf("Topic count mismatch: %d", topics.Len())
+	}
+	utils.SetRestResponse(utils.SUB_TOPIC_URL, []byte(utils.SUB_TOPIC_RESPONSE))
+	if err := subscriber.Subscribe(utils.TOPIC, false); nil != err {
+		t.Errorf("Subscribe failed: %v", err)
+	}
+	topics, _ = subscriber.GetTopics()
+	if topics.Len() != 1 {
+		t.Errorf("Topic count mismatch: %d", topics.Len())
+	}
+	err := subscriber.Subscribe("", false)
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_INVALID_TOPIC)) {
+		t.Errorf("Subscribe for invalid topic: %v", err)
+	}
+	subscriber.Terminate()
+}
+
+func TestAMLWatchSubscriber(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	utils.SetRestResponse(utils.CONFIG_URL, []byte(utils.VALID_CONFIG_RESPONSE))
+	utils.SetRestResponse(utils.TNS_INFO_URL, []byte(utils.VALID_TNS_INFO_RESPONSE))
+	utils.SetRestResponse(utils.RUNNING_APPS_URL, []byte(utils.VALID_RUNNING_APPS_RESPONSE))
+	utils.SetRestResponse(utils.RUNNING_APP_INFO_URL, []byte(utils.RUNNING_APP_INFO_RESPONSE))
+	configInstance.StartDockerMode(utils.TNS_CONFIG_FILE_PATH)
+	defer configInstance.Reset()
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	configInstance.AddAmlModel(*amlFilePath)
+	utils.SetRestResponse(utils.SUB_TOPIC_H_URL, []byte(utils.SUB_TOPIC_RESPONSE))
+
+	_, err := ezmqx.GetAMLSubscriberWithOptions(utils.TOPIC, true, ezmqx.EZMQXAMLSubscriberOptions{SubCallback: amlSubCB,
+		ErrorCallback: errorCB, Watch: &ezmqx.EZMQXWatchOptions{Interval: -1}})
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_INVALID_PARAM)) {
+		t.Errorf("Invalid watch interval is accepted")
+	}
+
+	changes := make(chan string, 10)
+	options := ezmqx.EZMQXWatchOptions{Interval: 100 * time.Millisecond,
+		TopicChangeCallback: func(changeType ezmqx.EZMQXTopicChangeType, topic ezmqx.EZMQXTopic) {
+			changes <- fmt.Sprintf("%d%s", changeType, topic.GetName())
+		}}
+	subscriber, err := ezmqx.GetAMLSubscriberWithOptions(utils.TOPIC, true,
+		ezmqx.EZMQXAMLSubscriberOptions{SubCallback: amlSubCB, ErrorCallback: errorCB, Watch: &options})
+	if nil != err {
+		t.Fatalf("Get watch subscriber failed: %v", err)
+	}
+	expectChange := func(expected string) {
+		select {
+		case change := <-changes:
+			if change != expected {
+				t.Errorf("Topic change mismatch: %s, expected: %s", change, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("Topic change is not reported: %s", expected)
+		}
+	}
+
+	// New topic is registered under the watched topic
+	utils.SetRestResponse(utils.SUB_TOPIC_H_URL, []byte(utils.WATCH_TOPIC_RESPONSE))
+	expectChange(fmt.Sprintf("%d%s", ezmqx.TOPIC_ADDED, utils.WATCH_TOPIC))
+	topics, _ := subscriber.GetTopics()
+	if topics.Len() != 2 {
+		t.Errorf("Topic count mismatch: %d", topics.Len())
+	}
+	// New topic is removed from TNS
+	utils.SetRestResponse(utils.SUB_TOPIC_H_URL, []byte(utils.SUB_TOPIC_RESPONSE))
+	expectChange(fmt.Sprintf("%d%s", ezmqx.TOPIC_REMOVED, utils.WATCH_TOPIC))
+	topics, _ = subscriber.GetTopics()
+	if topics.Len() != 1 {
+		t.Errorf("Topic count mismatch: %d", topics.Len())
+	}
+	if result := subscriber.Terminate(); result != ezmqx.EZMQX_OK {
+		t.Errorf("Terminate failed: %d", result)
+	}
+}
+
+func TestAMLSubscriberAutoReconnect(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	utils.SetRestResponse(utils.CONFIG_URL, []byte(utils.VALID_CONFIG_RESPONSE))
+	utils.SetRestResponse(utils.TNS_INFO_URL, []byte(utils.VALID_TNS_INFO_RESPONSE))
+	utils.SetRestResponse(utils.RUNNING_APPS_URL, []byte(utils.VALID_RUNNING_APPS_RESPONSE))
+	utils.SetRestResponse(utils.RUNNING_APP_INFO_URL, []byte(utils.RUNNING_APP_INFO_RESPONSE))
+	configInstance.StartDockerMode(utils.TNS_CONFIG_FILE_PATH)
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	configInstance.AddAmlModel(*amlFilePath)
+	utils.SetRestResponse(utils.SUB_TOPIC_H_URL, []byte(utils.WATCH_TOPIC_RESPONSE))
+
+	reconnected := make(chan string, 10)
+	subscriber, _ := ezmqx.GetAMLSubscriber(utils.TOPIC, true, amlSubCB,
+		func(topic string, errorCode ezmqx.EZMQXErrorCode) {
+			if errorCode == ezmqx.EZMQX_RECONNECTED {
+				reconnected <- topic
+			}
+		})
+	if nil == subscriber {
+		t.Fatalf("subscriber is nil")
+	}
+	defer subscriber.Terminate()
+	err := subscriber.EnableAutoReconnect(ezmqx.EZMQXReconnectOptions{})
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_INVALID_PARAM)) {
+		t.Errorf("Empty reconnect options are accepted")
+	}
+	err = subscriber.EnableAutoReconnect(ezmqx.EZMQXReconnectOptions{ResolveInterval: 100 * time.Millisecond})
+	if nil != err {
+		t.Fatalf("EnableAutoReconnect failed: %v", err)
+	}
+
+	// Publisher is restarted on another port, both topics are resolved by one hierarchical query
+	utils.SetRestResponse(utils.SUB_TOPIC_H_URL, []byte(utils.RECONNECT_WATCH_TOPIC_RESPONSE))
+	select {
+	case topic := <-reconnected:
+		if topic != utils.TOPIC {
+			t.Errorf("Reconnected topic mismatch: %s", topic)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Reconnect is not reported")
+	}
+	topics, _ := subscriber.GetTopics()
+	if topics.Len() != 2 {
+		t.Fatalf("Topic count mismatch: %d", topics.Len())
+	}
+	for element := topics.Front(); element != nil; element = element.Next() {
+		topic := element.Value.(ezmqx.EZMQXTopic)
+		if topic.GetName() == utils.TOPIC && topic.GetEndPoint().GetPort() != 5564 {
+			t.Errorf("End point is not changed: %s", topic.GetEndPoint().ToString())
+		}
+		if topic.GetName() == utils.WATCH_TOPIC && topic.GetEndPoint().GetPort() != 5563 {
+			t.Errorf("End point is changed: %s", topic.GetEndPoint().ToString())
+		}
+	}
+}
+
+func TestAMLSubscriberTerminateInReconnectCallback(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	utils.SetRestResponse(utils.CONFIG_URL, []byte(utils.VALID_CONFIG_RESPONSE))
+	utils.SetRestResponse(utils.TNS_INFO_URL, []byte(utils.VALID_TNS_INFO_RESPONSE))
+	utils.SetRestResponse(utils.RUNNING_APPS_URL, []byte(utils.VALID_RUNNING_APPS_RESPONSE))
+	utils.SetRestResponse(utils.RUNNING_APP_INFO_URL, []byte(utils.RUNNING_APP_INFO_RESPONSE))
+	configInstance.StartDockerMode(utils.TNS_CONFIG_FILE_PATH)
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	configInstance.AddAmlModel(*amlFilePath)
+	utils.SetRestResponse(utils.SUB_TOPIC_H_URL, []byte(utils.SUB_TOPIC_RESPONSE))
+
+	terminated := make(chan ezmqx.EZMQXErrorCode, 1)
+	var subscriber *ezmqx.EZMQXAMLSubscriber
+	subscriber, _ = ezmqx.GetAMLSubscriber(utils.TOPIC, true, amlSubCB,
+		func(topic string, errorCode ezmqx.EZMQXErrorCode) {
+			if errorCode == ezmqx.EZMQX_RECONNECTED {
+				terminated <- subscriber.Terminate()
+			}
+		})
+	if nil == subscriber {
+		t.Fatalf("subscriber is nil")
+	}
+	err := subscriber.EnableAutoReconnect(ezmqx.EZMQXReconnectOptions{ResolveInterval: 100 * time.Millisecond})
+	if nil != err {
+		subscriber.Terminate()
+		t.Fatalf("EnableAutoReconnect failed: %v", err)
+	}
+	utils.SetRestResponse(utils.SUB_TOPIC_H_URL, []byte(utils.RECONNECT_TOPIC_RESPONSE))
+	select {
+	case result := <-terminated:
+		if result != ezmqx.EZMQX_OK {
+			t.Errorf("Terminate failed: %v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Terminate in callback is blocked")
+	}
+}
+
+func TestAMLSubscriberTerminateWaitsForWatchCallback(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	utils.SetRestResponse(utils.CONFIG_URL, []byte(utils.VALID_CONFIG_RESPONSE))
+	utils.SetRestResponse(utils.TNS_INFO_URL, []byte(utils.VALID_TNS_INFO_RESPONSE))
+	utils.SetRestResponse(utils.RUNNING_APPS_URL, []byte(utils.VALID_RUNNING_APPS_RESPONSE))
+	utils.SetRestResponse(utils.RUNNING_APP_INFO_URL, []byte(utils.RUNNING_APP_INFO_RESPONSE))
+	configInstance.StartDockerMode(utils.TNS_CONFIG_FILE_PATH)
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	configInstance.AddAmlModel(*amlFilePath)
+	utils.SetRestResponse(utils.SUB_TOPIC_H_URL, []byte(utils.SUB_TOPIC_RESPONSE))
+
+	entered := make(chan bool, 1)
+	release := make(chan bool)
+	options := ezmqx.EZMQXWatchOptions{Interval: 100 * time.Millisecond,
+		TopicChangeCallback: func(changeType ezmqx.EZMQXTopicChangeType, topic ezmqx.EZMQXTopic) {
+			entered <- true
+			<-release
+		}}
+	subscriber, err := ezmqx.GetAMLSubscriberWithOptions(utils.TOPIC, true,
+		ezmqx.EZMQXAMLSubscriberOptions{SubCallback: amlSubCB, ErrorCallback: errorCB, Watch: &options})
+	if nil != err {
+		t.Fatalf("Get watch subscriber failed: %v", err)
+	}
+	utils.SetRestResponse(utils.SUB_TOPIC_H_URL, []byte(utils.WATCH_TOPIC_RESPONSE))
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		close(release)
+		subscriber.Terminate()
+		t.Fatalf("Topic change is not reported")
+	}
+
+	// Terminate from another goroutine waits until the callback returns
+	terminated := make(chan ezmqx.EZMQXErrorCode, 1)
+	go func() {
+		terminated <- subscriber.Terminate()
+	}()
+	select {
+	case <-terminated:
+		t.Errorf("Terminate returned while callback is running")
+	case <-time.After(500 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case result := <-terminated:
+		if result != ezmqx.EZMQX_OK {
+			t.Errorf("Terminate failed: %v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Terminate is blocked after callback returned")
+	}
+}
+
+func TestAMLSubscriberTopicPattern(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	utils.SetRestResponse(utils.CONFIG_URL, []byte(utils.VALID_CONFIG_RESPONSE))
+	utils.SetRestResponse(utils.TNS_INFO_URL, []byte(utils.VALID_TNS_INFO_RESPONSE))
+	utils.SetRestResponse(utils.RUNNING_APPS_URL, []byte(utils.VALID_RUNNING_APPS_RESPONSE))
+	utils.SetRestResponse(utils.RUNNING_APP_INFO_URL, []byte(utils.RUNNING_APP_INFO_RESPONSE))
+	configInstance.StartDockerMode(utils.TNS_CONFIG_FILE_PATH)
+	defer configInstance.Reset()
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	configInstance.AddAmlModel(*amlFilePath)
+	utils.SetRestResponse(utils.SUB_TOPIC_H_URL, []byte(utils.WATCH_TOPIC_RESPONSE))
+
+	subscriber, err := ezmqx.GetAMLSubscriber("/topic/*", false, amlSubCB, errorCB)
+	if nil == subscriber {
+		t.Fatalf("Get subscriber for pattern failed: %d", err)
+	}
+	topics, _ := subscriber.GetTopics()
+	if topics.Len() != 1 {
+		t.Fatalf("Topic count mismatch: %d", topics.Len())
+	}
+	topic := topics.Front().Value.(ezmqx.EZMQXTopic)
+	if topic.GetName() != utils.WATCH_TOPIC {
+		t.Errorf("Pattern is not matched: %s", topic.GetName())
+	}
+	subscriber.Terminate()
+
+	_, err = ezmqx.GetAMLSubscriber("/*/child", false, amlSubCB, errorCB)
+	if err != ezmqx.EZMQX_INVALID_TOPIC {
+		t.Errorf("Invalid pattern is accepted")
+	}
+}
+
+func TestAMLHeaderSubscriberStandAlone(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	idList, _ := configInstance.AddAmlModel(*amlFilePath)
+	modelId := idList.Front().Value.(string)
+	endPoint := ezmqx.GetEZMQXEndPoint1(utils.TEST_LOCAL_HOST, utils.PORT)
+	topic := ezmqx.GetEZMQXTopic(utils.TOPIC, modelId, false, endPoint)
+	headers := make(chan *ezmqx.EZMQXMessageHeader, utils.NUMBER_OF_EVENTS)
+	subscriber, err := ezmqx.GetAMLStandAloneSubscriberWithOptions(*topic, ezmqx.EZMQXAMLSubscriberOptions{
+		HeaderCallback: func(topic string, header *ezmqx.EZMQXMessageHeader, amlObject aml.AMLObject) { headers <- header },
+		ErrorCallback:  errorCB})
+	if nil != err {
+		t.Fatalf("Get header subscriber failed: %v", err)
+	}
+	defer subscriber.Terminate()
+
+	for _, envelope := range []bool{true, false} {
+		options := ezmqx.EZMQXPublisherOptions{PublisherId: "publisher_1", Envelope: envelope}
+		publisher, err := ezmqx.GetAMLPublisherWithOptions(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT, options)
+		if nil != err {
+			t.Fatalf("Get publisher failed: %v", err)
+		}
+		if "publisher_1" != publisher.GetPublisherId() {
+			t.Errorf("Publisher id mismatch: %s", publisher.GetPublisherId())
+		}
+		time.Sleep(1000 * time.Millisecond)
+		for i := 0; i < 2; i++ {
+			if err := publisher.PublishWithHeaders(utils.GetAMLObject(), map[string]string{"key": "value"}); nil != err {
+				t.Errorf("Publish failed: %v", err)
+			}
+		}
+		for i := 1; i <= 2; i++ {
+			select {
+			case header := <-headers:
+				if !envelope {
+					if nil != header {
+						t.Errorf("Header of legacy payload: %+v", header)
+					}
+					continue
+				}
+				if nil == header || ezmqx.ENVELOPE_VERSION != header.Version || "publisher_1" != header.PublisherId ||
+					uint64(i) != header.Sequence || modelId != header.DataModelId || "value" != header.Headers["key"] || header.Timestamp.IsZero() {
+					t.Errorf("Header mismatch: %+v", header)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Received less event, envelope: %v", envelope)
+			}
+		}
+		publisher.Terminate()
+	}
+}
+
+// Publish AML payload in message envelope with the given sequence number and publish time.
+func publishEnvelope(ezmqPublisher *ezmq.EZMQPublisher, payload []byte, sequence uint64, timestamp time.Time) {
+	header, _ := json.Marshal(map[string]interface{}{"publisherId": "publisher_1", "sequence": sequence, "timestamp": timestamp.UnixNano()})
+	data := []byte{0x00, 'E', 'Z', 'X', ezmqx.ENVELOPE_VERSION, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(data[5:], uint32(len(header)))
+	data = append(append(data, header...), payload...)
+	ezmqPublisher.PublishOnTopic(utils.TOPIC, ezmq.EZMQByteData{ByteData: data})
+}
+
+func TestAMLSubscriberSequence(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	idList, _ := configInstance.AddAmlModel(*amlFilePath)
+	endPoint := ezmqx.GetEZMQXEndPoint1(utils.TEST_LOCAL_HOST, utils.PORT)
+	topic := ezmqx.GetEZMQXTopic(utils.TOPIC, idList.Front().Value.(string), false, endPoint)
+	subscriber, err := ezmqx.GetAMLStandAloneSubscriberWithOptions(*topic, ezmqx.EZMQXAMLSubscriberOptions{Channel: &ezmqx.EZMQXChannelOptions{}})
+	if nil != err {
+		t.Fatalf("Get channel subscriber failed: %v", err)
+	}
+	defer subscriber.Terminate()
+
+	representation, _ := aml.CreateRepresentation(utils.AML_FILE_PATH)
+	payload, _ := representation.DataToByte(utils.GetAMLObject())
+	ezmqPublisher := ezmq.GetEZMQPublisher(utils.PORT, nil, nil, nil)
+	if ezmq.EZMQ_OK != ezmqPublisher.Start() {
+		t.Fatalf("Start ezmq publisher failed")
+	}
+	defer ezmqPublisher.Stop()
+	time.Sleep(1000 * time.Millisecond)
+
+	sequences := []uint64{1, 2, 5, 4, 4, 6}
+	for _, sequence := range sequences {
+		publishEnvelope(ezmqPublisher, payload, sequence, time.Now())
+	}
+	expected := []ezmqx.EZMQXErrorCode{ezmqx.EZMQX_MESSAGE_LOSS, ezmqx.EZMQX_MESSAGE_REORDERED, ezmqx.EZMQX_MESSAGE_DUPLICATED}
+	for _, errorCode := range expected {
+		select {
+		case err := <-subscriber.Errors():
+			if ezmqx.ErrorCodeOf(err) != errorCode {
+				t.Errorf("Error mismatch: %v, expected: %v", err, errorCode)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Error is not reported: %v", errorCode)
+		}
+	}
+	for i := range sequences {
+		select {
+		case <-subscriber.Messages():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Received less event: %d", i)
+		}
+	}
+
+	stats := subscriber.GetSequenceStats()
+	if 1 != len(stats) {
+		t.Fatalf("Sequence stats mismatch: %+v", stats)
+	}
+	if utils.TOPIC != stats[0].Topic || "publisher_1" != stats[0].PublisherId || 6 != stats[0].Received ||
+		1 != stats[0].Lost || 1 != stats[0].Duplicated || 1 != stats[0].Reordered {
+		t.Errorf("Sequence stats mismatch: %+v", stats[0])
+	}
+	metrics := ezmqx.GetMetrics().Topics[utils.TOPIC]
+	if 2 != metrics.LostMessages || 1 != metrics.DuplicatedMessages || 1 != metrics.ReorderedMessages {
+		t.Errorf("Metrics mismatch: %+v", metrics)
+	}
+}
+
+func TestAMLSubscriberLatency(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	idList, _ := configInstance.AddAmlModel(*amlFilePath)
+	endPoint := ezmqx.GetEZMQXEndPoint1(utils.TEST_LOCAL_HOST, utils.PORT)
+	topic := ezmqx.GetEZMQXTopic(utils.TOPIC, idList.Front().Value.(string), false, endPoint)
+	subscriber, err := ezmqx.GetAMLStandAloneSubscriberWithOptions(*topic, ezmqx.EZMQXAMLSubscriberOptions{Channel: &ezmqx.EZMQXChannelOptions{}})
+	if nil != err {
+		t.Fatalf("Get channel subscriber failed: %v", err)
+	}
+	defer subscriber.Terminate()
+
+	representation, _ := aml.CreateRepresentation(utils.AML_FILE_PATH)
+	payload, _ := representation.DataToByte(utils.GetAMLObject())
+	ezmqPublisher := ezmq.GetEZMQPublisher(utils.PORT, nil, nil, nil)
+	if ezmq.EZMQ_OK != ezmqPublisher.Start() {
+		t.Fatalf("Start ezmq publisher failed")
+	}
+	defer ezmqPublisher.Stop()
+	time.Sleep(1000 * time.Millisecond)
+
+	// Publisher clock is one hour ahead and messages are published 50ms ago
+	receive := func(count int) {
+		for i := 1; i <= count; i++ {
+			publishEnvelope(ezmqPublisher, payload, uint64(i), time.Now().Add(time.Hour-50*time.Millisecond))
+			select {
+			case <-subscriber.Messages():
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Received less event: %d", i)
+			}
+		}
+	}
+	receive(1)
+	stats := subscriber.GetLatencyStats()[utils.TOPIC]
+	if 1 != stats.Count || 1 != stats.Negative {
+		t.Errorf("Latency without clock offset mismatch: %+v", stats)
+	}
+
+	subscriber.SetLatencyOptions(ezmqx.EZMQXLatencyOptions{PublisherClockOffsets: map[string]time.Duration{"publisher_1": time.Hour}})
+	subscriber.ResetLatencyStats()
+	receive(utils.NUMBER_OF_EVENTS)
+	stats = subscriber.GetLatencyStats()[utils.TOPIC]
+	if uint64(utils.NUMBER_OF_EVENTS) != stats.Count || 0 != stats.Negative || stats.Min < 50*time.Millisecond || stats.Max > time.Second ||
+		stats.P50 < stats.Min || stats.P50 > stats.P95 || stats.P95 > stats.P99 || stats.P99 > stats.Max {
+		t.Errorf("Latency mismatch: %+v", stats)
+	}
+}
+
+func TestAMLSubscriberCallbackPerEndPoint(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	idList, _ := configInstance.AddAmlModel(*amlFilePath)
+	slowTopic := ezmqx.GetEZMQXTopic(utils.TOPIC, idList.Front().Value.(string), false, ezmqx.GetEZMQXEndPoint1(utils.TEST_LOCAL_HOST, utils.PORT))
+	fastTopic := ezmqx.GetEZMQXTopic(utils.TOPIC+"/fast", idList.Front().Value.(string), false, ezmqx.GetEZMQXEndPoint1(utils.TEST_LOCAL_HOST, utils.PORT+1))
+	topics := list.New()
+	topics.PushBack(*slowTopic)
+	topics.PushBack(*fastTopic)
+
+	// Callback of slow end point waits until message of the other end point is received
+	fastReceived := make(chan bool, 1)
+	slowDone := make(chan bool, 1)
+	subscriber, _ := ezmqx.GetAMLStandAloneSubscriber1(*topics, func(topic string, amlObject aml.AMLObject) {
+		if utils.TOPIC != topic {
+			fastReceived <- true
+			return
+		}
+		select {
+		case <-fastReceived:
+			slowDone <- true
+		case <-time.After(5 * time.Second):
+			slowDone <- false
+		}
+	}, errorCB)
+	if nil == subscriber {
+		t.Fatalf("subscriber is nil")
+	}
+	defer subscriber.Terminate()
+
+	representation, _ := aml.CreateRepresentation(utils.AML_FILE_PATH)
+	payload, _ := representation.DataToByte(utils.GetAMLObject())
+	slowPublisher := ezmq.GetEZMQPublisher(utils.PORT, nil, nil, nil)
+	if ezmq.EZMQ_OK != slowPublisher.Start() {
+		t.Fatalf("Start ezmq publisher failed")
+	}
+	defer slowPublisher.Stop()
+	fastPublisher := ezmq.GetEZMQPublisher(utils.PORT+1, nil, nil, nil)
+	if ezmq.EZMQ_OK != fastPublisher.Start() {
+		t.Fatalf("Start ezmq publisher failed")
+	}
+	defer fastPublisher.Stop()
+	time.Sleep(1000 * time.Millisecond)
+
+	go slowPublisher.PublishOnTopic(slowTopic.GetName(), ezmq.EZMQByteData{ByteData: payload})
+	time.Sleep(100 * time.Millisecond)
+	fastPublisher.PublishOnTopic(fastTopic.GetName(), ezmq.EZMQByteData{ByteData: payload})
+	select {
+	case done := <-slowDone:
+		if !done {
+			t.Errorf("Slow callback stalled delivery of the other end point")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Message is not received")
+	}
+}
+
+func TestAMLChannelSubscriberBlockedReader(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	idList, _ := configInstance.AddAmlModel(*amlFilePath)
+	slowTopic := ezmqx.GetEZMQXTopic(utils.TOPIC, idList.Front().Value.(string), false, ezmqx.GetEZMQXEndPoint1(utils.TEST_LOCAL_HOST, utils.PORT))
+	brokenTopic := ezmqx.GetEZMQXTopic(utils.TOPIC+"/broken", idList.Front().Value.(string), false, ezmqx.GetEZMQXEndPoint1(utils.TEST_LOCAL_HOST, utils.PORT+1))
+	options := ezmqx.EZMQXChannelOptions{BufferSize: 1, OverflowPolicy: ezmqx.OVERFLOW_BLOCK}
+	subscriber, err := ezmqx.GetAMLStandAloneSubscriberWithOptions(*slowTopic, ezmqx.EZMQXAMLSubscriberOptions{Channel: &options})
+	if nil != err {
+		t.Fatalf("Get channel subscriber failed: %v", err)
+	}
+	defer subscriber.Terminate()
+	if err := subscriber.SubscribeTopic(*brokenTopic); nil != err {
+		t.Fatalf("SubscribeTopic failed: %v", err)
+	}
+
+	representation, _ := aml.CreateRepresentation(utils.AML_FILE_PATH)
+	payload, _ := representation.DataToByte(utils.GetAMLObject())
+	slowPublisher := ezmq.GetEZMQPublisher(utils.PORT, nil, nil, nil)
+	if ezmq.EZMQ_OK != slowPublisher.Start() {
+		t.Fatalf("Start ezmq publisher failed")
+	}
+	defer slowPublisher.Stop()
+	brokenPublisher := ezmq.GetEZMQPublisher(utils.PORT+1, nil, nil, nil)
+	if ezmq.EZMQ_OK != brokenPublisher.Start() {
+		t.Fatalf("Start ezmq publisher failed")
+	}
+	defer brokenPublisher.Stop()
+	time.Sleep(1000 * time.Millisecond)
+
+	// Second message waits for the reader, since message channel is full
+	go func() {
+		slowPublisher.PublishOnTopic(slowTopic.GetName(), ezmq.EZMQByteData{ByteData: payload})
+		slowPublisher.PublishOnTopic(slowTopic.GetName(), ezmq.EZMQByteData{ByteData: payload})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	brokenPublisher.PublishOnTopic(brokenTopic.GetName(), ezmq.EZMQByteData{ByteData: []byte("broken")})
+	select {
+	case err := <-subscriber.Errors():
+		if ezmqx.EZMQX_BROKEN_PAYLOAD != ezmqx.ErrorCodeOf(err) {
+			t.Errorf("Error mismatch: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Error is stalled by blocked message")
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-subscriber.Messages():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Received less event: %d", i)
+		}
+	}
 }
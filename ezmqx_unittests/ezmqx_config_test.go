@@ -19,8 +19,14 @@ package ezmqx_unittests
 
 import (
 	"container/list"
+	"context"
+	"errors"
 	"go/ezmqx"
 	"go/ezmqx_unittests/utils"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -90,6 +96,27 @@ func TestStartDockerModeNegative(t *testing.T) {
 	instance.Reset()
 }
 
+func TestStartDockerModeContextCancelled(t *testing.T) {
+	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	utils.SetRestResponse(utils.CONFIG_URL, []byte(utils.VALID_CONFIG_RESPONSE))
+	var instance *ezmqx.EZMQXConfig = ezmqx.GetConfigInstance()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := instance.StartDockerModeContext(ctx, utils.TNS_CONFIG_FILE_PATH)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Start docker mode: expected context.Canceled, got %v", err)
+	}
+	if ezmqx.ErrorCodeOf(err) != ezmqx.EZMQX_REST_ERROR {
+		t.Errorf("Start docker mode: expected EZMQX_REST_ERROR")
+	}
+	// config should be usable again after cancellation
+	result := instance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	if ezmqx.EZMQX_OK != result {
+		t.Errorf("StartStandAloneMode: Error")
+	}
+	instance.Reset()
+}
+
 func TestAddAmlModel(t *testing.T) {
 	var instance *ezmqx.EZMQXConfig = ezmqx.GetConfigInstance()
 	instance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
@@ -133,3 +160,106 @@ func TestResetNegative(t *testing.T) {
 		t.Errorf("Reset: Error")
 	}
 }
+
+func TestStartDockerModeWithFakePharos(t *testing.T) {
+	pharos := utils.NewFakePharos()
+	defer pharos.Close()
+	pharos.AddApp(utils.FakeApp{Id: "app_id", State: "running",
+		Services: []utils.FakeService{{ContainerId: "fake_container_id", Name: "publisher",
+			Ports: []utils.FakePort{{PrivatePort: utils.PORT, PublicPort: utils.PORT + 10000}}}}})
+	pharos.AddApp(utils.FakeApp{Id: "stopped_app_id", State: "exited"})
+
+	dir, err := ioutil.TempDir("", "ezmqx")
+	if nil != err {
+		t.Fatalf("Create temp dir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	hostNameFile := filepath.Join(dir, "hostname")
+	ioutil.WriteFile(hostNameFile, []byte("fake_container\n"), 0644)
+
+	options := ezmqx.EZMQXContextOptions{DockerMode: ezmqx.EZMQXDockerModeOptions{NodeURL: pharos.URL(), HostNameFilePath: hostNameFile}}
+	ezmqxCtx := ezmqx.NewEZMQXContext(options)
+	err = ezmqxCtx.GetConfig().StartDockerModeV2(utils.TNS_CONFIG_FILE_PATH)
+	if nil != err {
+		t.Fatalf("Start docker mode failed: %v", err)
+	}
+	ezmqxCtx.GetConfig().Reset()
+
+	// Pharos node error
+	pharos.SetResponse(utils.FAKE_CONFIG_PATH, http.StatusInternalServerError, "")
+	err = ezmqxCtx.GetConfig().StartDockerModeV2(utils.TNS_CONFIG_FILE_PATH)
+	var statusError *ezmqx.HTTPStatusError
+	if !errors.As(err, &statusError) || statusError.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Pharos node error is not reported: %v", err)
+	}
+	pharos.SetResponse(utils.FAKE_CONFIG_PATH, 0, "")
+
+	// Host name file does not exist
+	ezmqxCtx = ezmqx.NewEZMQXContext(ezmqx.EZMQXContextOptions{DockerMode: ezmqx.EZMQXDockerModeOptions{NodeURL: pharos.URL(), HostNameFilePath: filepath.Join(dir, "unknown")}})
+	err = ezmqxCtx.GetConfig().StartDockerModeV2(utils.TNS_CONFIG_FILE_PATH)
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_UNKNOWN_STATE)) {
+		t.Errorf("Missing host name file is not reported: %v", err)
+	}
+}
+
+func TestSetDockerModeOptions(t *testing.T) {
+	pharos := utils.NewFakePharos()
+	defer pharos.Close()
+	configInstance := ezmqx.GetConfigInstance()
+	err := configInstance.SetDockerModeOptions(ezmqx.EZMQXDockerModeOptions{NodeURL: pharos.URL(), HostNameFilePath: "hostname"})
+	if nil != err {
+		t.Errorf("SetDockerModeOptions failed: %v", err)
+	}
+	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	err = configInstance.SetDockerModeOptions(ezmqx.EZMQXDockerModeOptions{})
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_UNKNOWN_STATE)) {
+		t.Errorf("SetDockerModeOptions is allowed after start: %v", err)
+	}
+	configInstance.Reset()
+	// Restore default pharos node for other test cases
+	configInstance.SetDockerModeOptions(ezmqx.EZMQXDockerModeOptions{})
+}
+
+type testLogger struct {
+	fields map[string]interface{}
+	levels []ezmqx.EZMQXLogLevel
+}
+
+func (instance *testLogger) Log(level ezmqx.EZMQXLogLevel, msg string, fields []ezmqx.EZMQXLogField) {
+	instance.levels = append(instance.levels, level)
+	for _, field := range fields {
+		instance.fields[field.Key] = field.Value
+	}
+}
+
+func TestSetLogger(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	if err := ezmqx.SetLogLevel(ezmqx.LOG_LEVEL_OFF + 1); ezmqx.EZMQX_INVALID_PARAM != ezmqx.ErrorCodeOf(err) {
+		t.Errorf("Invalid log level is accepted")
+	}
+	level := ezmqx.GetLogLevel()
+	logger := &testLogger{fields: make(map[string]interface{})}
+	ezmqx.SetLogger(logger)
+	defer ezmqx.SetLogLevel(level)
+	defer ezmqx.InitLogger()
+
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	utils.SetRestResponse(utils.TOPIC_DISCOVERY_URL, []byte(utils.VALID_TOPIC_DISCOVERY_RESPONSE))
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+
+	ezmqx.SetLogLevel(ezmqx.LOG_LEVEL_DEBUG)
+	topicDiscovery.Query(utils.TOPIC)
+	if _, exists := logger.fields[ezmqx.LOG_FIELD_URL]; !exists {
+		t.Errorf("Debug log with url is not written")
+	}
+	ezmqx.SetLogLevel(ezmqx.LOG_LEVEL_ERROR)
+	logger.levels = nil
+	topicDiscovery.Query(utils.TOPIC)
+	for _, written := range logger.levels {
+		if written < ezmqx.LOG_LEVEL_ERROR {
+			t.Errorf("Log below log level is written: %d", written)
+		}
+	}
+}
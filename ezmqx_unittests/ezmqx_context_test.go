@@ -0,0 +1,81 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx_unittests
+
+import (
+	"go/ezmqx"
+	"go/ezmqx_unittests/utils"
+	"testing"
+)
+
+func TestNewEZMQXContext(t *testing.T) {
+	context := ezmqx.NewEZMQXContext(ezmqx.EZMQXContextOptions{})
+	if nil == context {
+		t.Errorf("Error context is NULL")
+	}
+	if nil == context.GetConfig() {
+		t.Errorf("Error config is NULL")
+	}
+	if nil == context.GetRestFactory() {
+		t.Errorf("Error rest factory is NULL")
+	}
+	if context.GetConfig() == ezmqx.GetConfigInstance() {
+		t.Errorf("Error new context shares default config")
+	}
+}
+
+func TestMultipleContexts(t *testing.T) {
+	context1 := ezmqx.NewEZMQXContext(ezmqx.EZMQXContextOptions{})
+	context2 := ezmqx.NewEZMQXContext(ezmqx.EZMQXContextOptions{RestClientFactory: utils.FakeRestClientFactory{}})
+	result := context1.GetConfig().StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	if ezmqx.EZMQX_OK != result {
+		t.Errorf("StartStandAloneMode [context1]: Error")
+	}
+	result = context2.GetConfig().StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	if ezmqx.EZMQX_OK != result {
+		t.Errorf("StartStandAloneMode [context2]: Error")
+	}
+	utils.SetRestResponse(utils.PUB_TNS_URL, []byte(utils.VALID_PUB_TNS_RESPONSE))
+
+	publisher1, err := context1.NewAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
+	if nil != err {
+		t.Errorf("NewAMLPublisher [context1]: %v", err)
+	}
+	publisher2, err := context2.NewAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT+1)
+	if nil != err {
+		t.Errorf("NewAMLPublisher [context2]: %v", err)
+	}
+	publisher1.Terminate()
+	context1.GetConfig().Reset()
+
+	// context2 should still be usable after context1 is reset
+	discovery, err := context2.NewTopicDiscovery()
+	if nil != err || nil == discovery {
+		t.Errorf("NewTopicDiscovery [context2]: %v", err)
+	}
+	publisher2.Terminate()
+	context2.GetConfig().Reset()
+}
+
+func TestNewTopicDiscoveryNegative(t *testing.T) {
+	context := ezmqx.NewEZMQXContext(ezmqx.EZMQXContextOptions{})
+	_, err := context.NewTopicDiscovery()
+	if ezmqx.ErrorCodeOf(err) != ezmqx.EZMQX_NOT_INITIALIZED {
+		t.Errorf("NewTopicDiscovery: Error")
+	}
+}
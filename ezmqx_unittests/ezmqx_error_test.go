@@ -0,0 +1,100 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx_unittests
+
+import (
+	"errors"
+	"go/ezmqx"
+	"go/ezmqx_unittests/utils"
+	"strings"
+	"testing"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	if ezmqx.ErrorCodeOf(nil) != ezmqx.EZMQX_OK {
+		t.Errorf("Error code of nil error is not EZMQX_OK")
+	}
+	if ezmqx.ErrorCodeOf(errors.New("error")) != ezmqx.EZMQX_UNKNOWN_STATE {
+		t.Errorf("Error code of unknown error is not EZMQX_UNKNOWN_STATE")
+	}
+	err := &ezmqx.EZMQXError{Code: ezmqx.EZMQX_REST_ERROR, Op: "query"}
+	if ezmqx.ErrorCodeOf(err) != ezmqx.EZMQX_REST_ERROR {
+		t.Errorf("Error code of EZMQXError is wrong")
+	}
+	if ezmqx.EZMQXErrorCode(ezmqx.EZMQX_REST_ERROR).String() != "EZMQX_REST_ERROR" {
+		t.Errorf("Error code name is wrong")
+	}
+}
+
+func TestErrorIsAndAs(t *testing.T) {
+	cause := &ezmqx.HTTPStatusError{StatusCode: 500}
+	err := &ezmqx.EZMQXError{Code: ezmqx.EZMQX_REST_ERROR, Op: "query", Topic: utils.TOPIC, Err: cause}
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_REST_ERROR)) {
+		t.Errorf("errors.Is failed for error code")
+	}
+	if errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_INVALID_TOPIC)) {
+		t.Errorf("errors.Is matched wrong error code")
+	}
+	var statusError *ezmqx.HTTPStatusError
+	if !errors.As(err, &statusError) || statusError.StatusCode != 500 {
+		t.Errorf("errors.As failed for HTTP status cause")
+	}
+	if !strings.Contains(err.Error(), utils.TOPIC) {
+		t.Errorf("Error message does not contain topic")
+	}
+}
+
+func TestNewAMLPublisherInvalidTopic(t *testing.T) {
+	context := ezmqx.NewEZMQXContext(ezmqx.EZMQXContextOptions{})
+	context.GetConfig().StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	_, err := context.NewAMLPublisher("invalid topic", ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_INVALID_TOPIC)) {
+		t.Errorf("NewAMLPublisher: expected EZMQX_INVALID_TOPIC, got %v", err)
+	}
+	var ezmqxError *ezmqx.EZMQXError
+	if !errors.As(err, &ezmqxError) || ezmqxError.Topic != "invalid topic" {
+		t.Errorf("NewAMLPublisher: topic is not set in error")
+	}
+	context.GetConfig().Reset()
+}
+
+func TestQueryV2Negative(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	defer configInstance.Reset()
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+
+	//Set fake rest client
+	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	utils.SetRestResponse(utils.TOPIC_DISCOVERY_URL, []byte(utils.INVALID_TOPIC_DISCOVERY_RESPONSE))
+
+	_, err := topicDiscovery.QueryV2(utils.TOPIC)
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_REST_ERROR)) {
+		t.Errorf("QueryV2: expected EZMQX_REST_ERROR, got %v", err)
+	}
+}
+
+func TestStartStandAloneModeV2Negative(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.ADDRESS, false, "")
+	defer configInstance.Reset()
+	err := configInstance.StartStandAloneModeV2(utils.ADDRESS, false, "")
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_UNKNOWN_STATE)) {
+		t.Errorf("StartStandAloneModeV2: expected EZMQX_UNKNOWN_STATE, got %v", err)
+	}
+}
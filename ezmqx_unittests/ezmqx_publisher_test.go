@@ -18,6 +18,8 @@
 package ezmqx_unittests
 
 import (
+	"context"
+	"errors"
 	"go/ezmqx"
 	"go/ezmqx_unittests/utils"
 	"testing"
@@ -28,46 +30,47 @@ import (
 func TestGetPublisherStandAlone(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	amlFilePath := list.New()
 	amlFilePath.PushBack(utils.AML_FILE_PATH)
 	idList, _ := configInstance.AddAmlModel(*amlFilePath)
 	publisher, _ := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_MODEL_ID, idList.Front().Value.(string), utils.PORT)
 	if nil == publisher {
-		t.Errorf("publisher is nil")
+		t.Fatalf("publisher is nil")
 	}
 	publisher.Terminate()
-	configInstance.Reset()
 }
 
 func TestGetPublisherStandAlone1(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	publisher, _ := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
 	if nil == publisher {
-		t.Errorf("publisher is nil")
+		t.Fatalf("publisher is nil")
 	}
 	publisher.Terminate()
-	configInstance.Reset()
 }
 
 func TestGetPublisherStandAlone2(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	defer configInstance.Reset()
 
 	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
 	utils.SetRestResponse(utils.PUB_TNS_URL, []byte(utils.VALID_PUB_TNS_RESPONSE))
 
 	publisher, _ := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
 	if nil == publisher {
-		t.Errorf("publisher is nil")
+		t.Fatalf("publisher is nil")
 	}
 	publisher.Terminate()
-	configInstance.Reset()
 }
 
 func TestGetPublisherStandAlone3(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	defer configInstance.Reset()
 
 	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
 	utils.SetRestResponse(utils.PUB_TNS_URL, []byte(utils.VALID_PUB_TNS_RESPONSE))
@@ -76,12 +79,12 @@ func TestGetPublisherStandAlone3(t *testing.T) {
 	if result != ezmqx.EZMQX_INVALID_TOPIC {
 		t.Errorf("Get publisher failed")
 	}
-	configInstance.Reset()
 }
 
 func TestGetPublisherStandAlone4(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	defer configInstance.Reset()
 
 	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
 	utils.SetRestResponse(utils.PUB_TNS_URL, []byte(utils.VALID_PUB_TNS_RESPONSE))
@@ -105,30 +108,30 @@ func TestGetPublisherStandAlone4(t *testing.T) {
 	if result != ezmqx.EZMQX_UNKNOWN_AML_MODEL {
 		t.Errorf("publisher is nil")
 	}
-	configInstance.Reset()
 }
 
 func TestGetSecuredPublisher(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	amlFilePath := list.New()
 	amlFilePath.PushBack(utils.AML_FILE_PATH)
 	idList, _ := configInstance.AddAmlModel(*amlFilePath)
 	publisher, errorCode := ezmqx.GetSecuredAMLPublisher(utils.TOPIC, utils.SERVER_SECRET_KEY, ezmqx.AML_MODEL_ID, idList.Front().Value.(string), utils.PORT)
 	if errorCode != ezmqx.EZMQX_OK {
-		t.Errorf("GetSecuredAMLPublisher failed")
+		t.Fatalf("GetSecuredAMLPublisher failed")
 	}
+	defer publisher.Terminate()
 	isSecured, _ := publisher.IsSecured()
 	if !isSecured {
 		t.Errorf("publisher is secured failed")
 	}
-	publisher.Terminate()
-	configInstance.Reset()
 }
 
 func TestGetSecuredPublisherNegative(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	amlFilePath := list.New()
 	amlFilePath.PushBack(utils.AML_FILE_PATH)
 	idList, _ := configInstance.AddAmlModel(*amlFilePath)
@@ -158,34 +161,36 @@ func TestGetSecuredPublisherNegative(t *testing.T) {
 	if ezmqx.EZMQX_OK == errorCode {
 		t.Errorf("GetSecuredAMLPublisher wrong error code")
 	}
-	configInstance.Reset()
 }
 
 func TestIsSecuredPublisher(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	publisher, _ := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
 	if nil == publisher {
-		t.Errorf("publisher is nil")
+		t.Fatalf("publisher is nil")
 	}
+	defer publisher.Terminate()
 	isSecured, _ := publisher.IsSecured()
 	if isSecured {
 		t.Errorf("publisher is secured failed")
 	}
-	publisher.Terminate()
-	configInstance.Reset()
 }
 
 func TestStandAlonePublish(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	publisher, _ := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
+	if nil == publisher {
+		t.Fatalf("publisher is nil")
+	}
+	defer publisher.Terminate()
 	result := publisher.Publish(utils.GetAMLObject())
 	if result != ezmqx.EZMQX_OK {
 		t.Errorf("publish failed")
 	}
-	publisher.Terminate()
-	configInstance.Reset()
 }
 
 func TestStandAlonePublishNegative(t *testing.T) {
@@ -214,17 +219,33 @@ func TestDockerModePublish(t *testing.T) {
 	utils.SetRestResponse(utils.PUB_TNS_URL, []byte(utils.VALID_PUB_TNS_RESPONSE))
 
 	configInstance.StartDockerMode(utils.TNS_CONFIG_FILE_PATH)
+	defer configInstance.Reset()
 	publisher, _ := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
 	if nil == publisher {
-		t.Errorf("publisher is nil")
+		t.Fatalf("publisher is nil")
 	}
 	publisher.Terminate()
-	configInstance.Reset()
+}
+
+func TestGetAMLPublisherContextCancelled(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	utils.SetRestResponse(utils.PUB_TNS_URL, []byte(utils.VALID_PUB_TNS_RESPONSE))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	publisher, err := ezmqx.GetAMLPublisherContext(ctx, utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
+	if nil != publisher || !errors.Is(err, context.Canceled) {
+		t.Errorf("GetAMLPublisherContext was not cancelled: %v", err)
+	}
 }
 
 func TestTerminate(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	publisher, _ := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
 	isTerminated, _ := publisher.IsTerminated()
 	if true == isTerminated {
@@ -238,13 +259,17 @@ func TestTerminate(t *testing.T) {
 	if false == isTerminated {
 		t.Errorf("Terminate failed")
 	}
-	configInstance.Reset()
 }
 
 func TestGetTopic(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	publisher, _ := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
+	if nil == publisher {
+		t.Fatalf("publisher is nil")
+	}
+	defer publisher.Terminate()
 	result := publisher.Publish(utils.GetAMLObject())
 	if result != ezmqx.EZMQX_OK {
 		t.Errorf("publish failed")
@@ -253,6 +278,25 @@ func TestGetTopic(t *testing.T) {
 	if topic.GetName() != utils.TOPIC {
 		t.Errorf("Topic mismatch")
 	}
+}
+
+func TestDuplicatedTopic(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
+	publisher, _ := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
+	if nil == publisher {
+		t.Fatalf("publisher is nil")
+	}
+	duplicated, result := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT+1)
+	if nil != duplicated || result != ezmqx.EZMQX_DUPLICATED_TOPIC {
+		t.Errorf("Duplicated topic is published: %d", result)
+	}
 	publisher.Terminate()
-	configInstance.Reset()
+	publisher, _ = ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT+1)
+	if nil == publisher {
+		t.Errorf("Topic is not published after terminate")
+	} else {
+		publisher.Terminate()
+	}
 }
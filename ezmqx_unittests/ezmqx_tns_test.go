@@ -0,0 +1,948 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package ezmqx_unittests
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"encoding/pem"
+	"go/ezmqx"
+	"go/ezmqx_tns"
+	"go/ezmqx_unittests/utils"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func startTNSServer(t *testing.T, options ezmqx_tns.TNSServerOptions) (*ezmqx_tns.TNSServer, *httptest.Server) {
+	tnsServer, err := ezmqx_tns.NewTNSServer(options)
+	if nil != err {
+		t.Fatalf("Create TNS server failed: %v", err)
+	}
+	return tnsServer, httptest.NewServer(tnsServer)
+}
+
+// Start TNS server which responds 503 until it is made available.
+func startUnavailableTNSServer(t *testing.T) (*ezmqx_tns.TNSServer, *httptest.Server, *int32, *int32) {
+	tnsServer, err := ezmqx_tns.NewTNSServer(ezmqx_tns.TNSServerOptions{})
+	if nil != err {
+		t.Fatalf("Create TNS server failed: %v", err)
+	}
+	var available, requests int32
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if 0 == atomic.LoadInt32(&available) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		tnsServer.ServeHTTP(w, r)
+	}))
+	return tnsServer, httpServer, &available, &requests
+}
+
+func registerTopic(t *testing.T, url string, name string, endPoint string) int {
+	payload := map[string]interface{}{"topic": map[string]interface{}{"name": name, "datamodel": "GTC_Robot_0.0.1", "endpoint": endPoint, "secured": false}}
+	data, _ := json.Marshal(payload)
+	response, err := http.Post(url+"/api/v1/tns/topic", "application/json", bytes.NewReader(data))
+	if nil != err {
+		t.Fatalf("Register request failed: %v", err)
+	}
+	response.Body.Close()
+	return response.StatusCode
+}
+
+func sendKeepAlive(t *testing.T, url string, names ...string) int {
+	data, _ := json.Marshal(map[string][]string{"topic_names": names})
+	response, err := http.Post(url+"/api/v1/tns/keepalive", "application/json", bytes.NewReader(data))
+	if nil != err {
+		t.Fatalf("Keep alive request failed: %v", err)
+	}
+	response.Body.Close()
+	return response.StatusCode
+}
+
+func TestTNSServerProtocol(t *testing.T) {
+	tnsServer, httpServer := startTNSServer(t, ezmqx_tns.TNSServerOptions{KeepAliveInterval: 10})
+	defer tnsServer.Close()
+	defer httpServer.Close()
+
+	if status := registerTopic(t, httpServer.URL, "/topic", "localhost:5562"); status != http.StatusCreated {
+		t.Errorf("Register failed: %d", status)
+	}
+	if status := registerTopic(t, httpServer.URL, "/topic/child", "localhost:5563"); status != http.StatusCreated {
+		t.Errorf("Register failed: %d", status)
+	}
+	// Same topic from another end point
+	if status := registerTopic(t, httpServer.URL, "/topic", "localhost:5564"); status != http.StatusConflict {
+		t.Errorf("Duplicated topic is registered: %d", status)
+	}
+	if status := registerTopic(t, httpServer.URL, "topic/", "localhost:5564"); status != http.StatusBadRequest {
+		t.Errorf("Invalid topic is registered: %d", status)
+	}
+	if status := sendKeepAlive(t, httpServer.URL, "/topic", "/topic/child"); status != http.StatusOK {
+		t.Errorf("Keep alive failed: %d", status)
+	}
+	if status := sendKeepAlive(t, httpServer.URL, "/topic", "/unknown"); status != http.StatusNotFound {
+		t.Errorf("Keep alive for unknown topic: %d", status)
+	}
+
+	request, _ := http.NewRequest(http.MethodDelete, httpServer.URL+"/api/v1/tns/topic?name=/topic/child", nil)
+	response, err := http.DefaultClient.Do(request)
+	if nil != err || response.StatusCode != http.StatusOK {
+		t.Errorf("Unregister failed")
+	}
+	response.Body.Close()
+	topics := tnsServer.GetTopics()
+	if len(topics) != 1 || topics[0].Name != "/topic" {
+		t.Errorf("Registered topics mismatch: %v", topics)
+	}
+}
+
+func TestTNSServerExpiry(t *testing.T) {
+	if _, err := ezmqx_tns.NewTNSServer(ezmqx_tns.TNSServerOptions{ExpiryTimeout: 1}); nil == err {
+		t.Errorf("Expiry timeout less than minimum is accepted")
+	}
+	tnsServer, httpServer := startTNSServer(t, ezmqx_tns.TNSServerOptions{ExpiryTimeout: time.Second})
+	defer tnsServer.Close()
+	defer httpServer.Close()
+
+	registerTopic(t, httpServer.URL, "/topic", "localhost:5562")
+	registerTopic(t, httpServer.URL, "/topic/child", "localhost:5563")
+	time.Sleep(600 * time.Millisecond)
+	sendKeepAlive(t, httpServer.URL, "/topic")
+	time.Sleep(600 * time.Millisecond)
+	tnsServer.ExpireTopics()
+	topics := tnsServer.GetTopics()
+	if len(topics) != 1 || topics[0].Name != "/topic" {
+		t.Errorf("Topic is not expired: %v", topics)
+	}
+}
+
+func TestPublisherAndDiscoveryWithTNSServer(t *testing.T) {
+	tnsServer, httpServer := startTNSServer(t, ezmqx_tns.TNSServerOptions{})
+	defer tnsServer.Close()
+	defer httpServer.Close()
+
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, httpServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	configInstance.AddAmlModel(*amlFilePath)
+	publisher, result := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
+	if nil == publisher {
+		t.Fatalf("Get publisher failed: %d", result)
+	}
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+	topic, err := topicDiscovery.QueryV2(utils.TOPIC)
+	if nil != err {
+		t.Errorf("Query failed: %v", err)
+	} else if topic.GetEndPoint().GetPort() != utils.PORT {
+		t.Errorf("End point mismatch: %s", topic.GetEndPoint().ToString())
+	}
+	publisher.Terminate()
+	if 0 != len(tnsServer.GetTopics()) {
+		t.Errorf("Topic is not unregistered")
+	}
+}
+
+func TestKeepAliveReRegisterWithTNSServer(t *testing.T) {
+	tnsServer, httpServer := startTNSServer(t, ezmqx_tns.TNSServerOptions{KeepAliveInterval: 1})
+	defer tnsServer.Close()
+	defer httpServer.Close()
+
+	configInstance := ezmqx.GetConfigInstance()
+	statusChan := make(chan ezmqx.EZMQXKeepAliveStatus, 10)
+	topicChan := make(chan string, 10)
+	configInstance.SetKeepAliveStatusCallback(func(status ezmqx.EZMQXKeepAliveStatus, topic string, err error) {
+		if ezmqx.TOPIC_REREGISTERED == status {
+			topicChan <- topic
+		}
+		statusChan <- status
+	})
+	defer configInstance.SetKeepAliveStatusCallback(nil)
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, httpServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	configInstance.AddAmlModel(*amlFilePath)
+	publisher, result := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
+	if nil == publisher {
+		t.Fatalf("Get publisher failed: %d", result)
+	}
+	defer publisher.Terminate()
+	childPublisher, result := ezmqx.GetAMLPublisher(utils.TOPIC+"/child", ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT+1)
+	if nil == childPublisher {
+		t.Fatalf("Get publisher failed: %d", result)
+	}
+	defer childPublisher.Terminate()
+
+	// Simulate TNS restart by removing the topic from TNS
+	request, _ := http.NewRequest(http.MethodDelete, httpServer.URL+"/api/v1/tns/topic?name="+utils.TOPIC, nil)
+	response, err := http.DefaultClient.Do(request)
+	if nil != err {
+		t.Fatalf("Delete request failed: %v", err)
+	}
+	response.Body.Close()
+
+	expected := []ezmqx.EZMQXKeepAliveStatus{ezmqx.KEEPALIVE_FAILED, ezmqx.TOPIC_REREGISTERED, ezmqx.KEEPALIVE_RECOVERED}
+	for _, status := range expected {
+		select {
+		case received := <-statusChan:
+			if received != status {
+				t.Errorf("Keep alive status mismatch: %d, expected: %d", received, status)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Keep alive status timeout: %d", status)
+		}
+	}
+	// Only the topic unknown to TNS is registered again
+	if topic := <-topicChan; topic != utils.TOPIC || 0 != len(topicChan) {
+		t.Errorf("Registered topic mismatch: %s", topic)
+	}
+	if 2 != len(tnsServer.GetTopics()) {
+		t.Errorf("Topic is not registered again")
+	}
+}
+
+func TestKeepAliveTransportErrorWithTNSServer(t *testing.T) {
+	tnsServer, err := ezmqx_tns.NewTNSServer(ezmqx_tns.TNSServerOptions{KeepAliveInterval: 1})
+	if nil != err {
+		t.Fatalf("Create TNS server failed: %v", err)
+	}
+	defer tnsServer.Close()
+	var unavailable, registered, keptAlive int32
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if http.MethodPost == r.Method && strings.HasSuffix(r.URL.Path, "/topic") {
+			atomic.AddInt32(&registered, 1)
+		}
+		if strings.HasSuffix(r.URL.Path, "/keepalive") {
+			data, _ := ioutil.ReadAll(r.Body)
+			if bytes.Contains(data, []byte(utils.TOPIC)) {
+				atomic.StoreInt32(&keptAlive, 1)
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(data))
+		}
+		if 1 == atomic.LoadInt32(&unavailable) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		tnsServer.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+
+	configInstance := ezmqx.GetConfigInstance()
+	statusChan := make(chan ezmqx.EZMQXKeepAliveStatus, 10)
+	configInstance.SetKeepAliveStatusCallback(func(status ezmqx.EZMQXKeepAliveStatus, topic string, err error) {
+		statusChan <- status
+	})
+	defer configInstance.SetKeepAliveStatusCallback(nil)
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, httpServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+
+	publisher, result := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
+	if nil == publisher {
+		t.Fatalf("Get publisher failed: %d", result)
+	}
+	defer publisher.Terminate()
+
+	for i := 0; i < 50 && 0 == atomic.LoadInt32(&keptAlive); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if 0 == atomic.LoadInt32(&keptAlive) {
+		t.Fatalf("Keep alive is not sent")
+	}
+
+	// Topic is not registered again, while TNS is unavailable
+	atomic.StoreInt32(&unavailable, 1)
+	select {
+	case received := <-statusChan:
+		if ezmqx.KEEPALIVE_FAILED != received {
+			t.Errorf("Keep alive status mismatch: %d", received)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Keep alive status timeout")
+	}
+	atomic.StoreInt32(&unavailable, 0)
+	for received := ezmqx.KEEPALIVE_FAILED; ezmqx.KEEPALIVE_RECOVERED != received; {
+		select {
+		case received = <-statusChan:
+			if ezmqx.TOPIC_REREGISTERED == received {
+				t.Errorf("Topic is registered again for transport error")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Keep alive status timeout")
+		}
+	}
+	if 1 != atomic.LoadInt32(&registered) {
+		t.Errorf("Register request count mismatch: %d", atomic.LoadInt32(&registered))
+	}
+}
+
+func TestKeepAliveUnboundedRetryPolicyWithTNSServer(t *testing.T) {
+	tnsServer, err := ezmqx_tns.NewTNSServer(ezmqx_tns.TNSServerOptions{KeepAliveInterval: 1})
+	if nil != err {
+		t.Fatalf("Create TNS server failed: %v", err)
+	}
+	defer tnsServer.Close()
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/keepalive") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		tnsServer.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+
+	configInstance := ezmqx.GetConfigInstance()
+	statusChan := make(chan ezmqx.EZMQXKeepAliveStatus, 10)
+	configInstance.SetKeepAliveStatusCallback(func(status ezmqx.EZMQXKeepAliveStatus, topic string, err error) {
+		statusChan <- status
+	})
+	defer configInstance.SetKeepAliveStatusCallback(nil)
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, httpServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	configInstance.SetRetryPolicy(ezmqx.EZMQXRetryPolicy{MaxAttempts: -1, InitialBackoff: 10 * time.Millisecond})
+	defer configInstance.SetRetryPolicy(ezmqx.EZMQXRetryPolicy{})
+
+	publisher, result := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
+	if nil == publisher {
+		t.Fatalf("Get publisher failed: %d", result)
+	}
+	defer publisher.Terminate()
+
+	// Keep alive retries are bounded by keep alive interval
+	select {
+	case received := <-statusChan:
+		if ezmqx.KEEPALIVE_FAILED != received {
+			t.Errorf("Keep alive status mismatch: %d", received)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Keep alive is retried without limit")
+	}
+}
+
+func TestPublisherRegisterRetry(t *testing.T) {
+	tnsServer, httpServer, available, requests := startUnavailableTNSServer(t)
+	defer tnsServer.Close()
+	defer httpServer.Close()
+
+	configInstance := ezmqx.GetConfigInstance()
+	policy := ezmqx.EZMQXRetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, Jitter: 0.5}
+	if err := configInstance.SetRetryPolicy(policy); nil != err {
+		t.Fatalf("Set retry policy failed: %v", err)
+	}
+	defer configInstance.SetRetryPolicy(ezmqx.EZMQXRetryPolicy{})
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, httpServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	configInstance.AddAmlModel(*amlFilePath)
+	publisher, result := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
+	if nil != publisher {
+		t.Errorf("Publisher is created while TNS is unavailable")
+		publisher.Terminate()
+	} else if ezmqx.EZMQX_REST_ERROR != result {
+		t.Errorf("Unexpected result: %d", result)
+	}
+	if 3 != atomic.LoadInt32(requests) {
+		t.Errorf("Register request is not retried: %d", atomic.LoadInt32(requests))
+	}
+
+	atomic.StoreInt32(available, 1)
+	publisher, result = ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
+	if nil == publisher {
+		t.Fatalf("Get publisher failed: %d", result)
+	}
+	publisher.Terminate()
+}
+
+func TestPublisherRegisterInvalidResponse(t *testing.T) {
+	var requests int32
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if http.MethodPost == r.Method && strings.HasSuffix(r.URL.Path, "/topic") {
+			atomic.AddInt32(&requests, 1)
+		}
+		// Response without keep alive interval
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("{}"))
+	}))
+	defer httpServer.Close()
+
+	configInstance := ezmqx.GetConfigInstance()
+	policy := ezmqx.EZMQXRetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond}
+	if err := configInstance.SetRetryPolicy(policy); nil != err {
+		t.Fatalf("Set retry policy failed: %v", err)
+	}
+	defer configInstance.SetRetryPolicy(ezmqx.EZMQXRetryPolicy{})
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, httpServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	configInstance.AddAmlModel(*amlFilePath)
+	publisher, result := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
+	if nil != publisher {
+		t.Errorf("Publisher is created for invalid response")
+		publisher.Terminate()
+	} else if ezmqx.EZMQX_REST_ERROR != result {
+		t.Errorf("Unexpected result: %d", result)
+	}
+	if 1 != atomic.LoadInt32(&requests) {
+		t.Errorf("Invalid response is retried: %d", atomic.LoadInt32(&requests))
+	}
+}
+
+func TestPublisherPendingRegistration(t *testing.T) {
+	tnsServer, httpServer, available, _ := startUnavailableTNSServer(t)
+	defer tnsServer.Close()
+	defer httpServer.Close()
+
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.SetRetryPolicy(ezmqx.EZMQXRetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond})
+	defer configInstance.SetRetryPolicy(ezmqx.EZMQXRetryPolicy{})
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, httpServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	idList, _ := configInstance.AddAmlModel(*amlFilePath)
+	registered := make(chan error, 1)
+	options := ezmqx.EZMQXPublisherOptions{PendingRegistration: true, RegistrationCallback: func(topic string, err error) {
+		registered <- err
+	}}
+	publisher, err := ezmqx.GetAMLPublisherWithOptions(utils.TOPIC, ezmqx.AML_MODEL_ID, idList.Front().Value.(string), utils.PORT, options)
+	if nil == publisher {
+		t.Fatalf("Get publisher failed: %v", err)
+	}
+	if publisher.IsRegistered() {
+		t.Errorf("Publisher is registered while TNS is unavailable")
+	}
+	if result := publisher.Publish(utils.GetAMLObject()); ezmqx.EZMQX_OK != result {
+		t.Errorf("Publish failed while registration is pending: %d", result)
+	}
+
+	atomic.StoreInt32(available, 1)
+	select {
+	case err = <-registered:
+		if nil != err {
+			t.Errorf("Background registration failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Background registration timeout")
+	}
+	if !publisher.IsRegistered() || 1 != len(tnsServer.GetTopics()) {
+		t.Errorf("Topic is not registered")
+	}
+	publisher.Terminate()
+	if 0 != len(tnsServer.GetTopics()) {
+		t.Errorf("Topic is not unregistered")
+	}
+}
+
+func TestSetRetryPolicyNegative(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	invalid := []ezmqx.EZMQXRetryPolicy{{InitialBackoff: -1}, {Multiplier: 0.5}, {Jitter: 2}}
+	for _, policy := range invalid {
+		err := configInstance.SetRetryPolicy(policy)
+		if ezmqx.EZMQX_INVALID_PARAM != ezmqx.ErrorCodeOf(err) {
+			t.Errorf("Invalid retry policy is accepted: %+v", policy)
+		}
+	}
+}
+
+func TestDuplicatedTopicWithTNSServer(t *testing.T) {
+	tnsServer, httpServer := startTNSServer(t, ezmqx_tns.TNSServerOptions{})
+	defer tnsServer.Close()
+	defer httpServer.Close()
+	registerTopic(t, httpServer.URL, utils.TOPIC, "10.0.0.1:5562")
+
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, httpServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+
+	publisher, result := ezmqx.GetAMLPublisher(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT)
+	if nil != publisher || result != ezmqx.EZMQX_DUPLICATED_TOPIC {
+		t.Errorf("Topic registered by another publisher is published: %d", result)
+	}
+
+	options := ezmqx.EZMQXPublisherOptions{Takeover: true}
+	publisher, err := ezmqx.GetAMLPublisherWithOptions(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT, options)
+	if nil == publisher {
+		t.Fatalf("Take over topic failed: %v", err)
+	}
+	defer publisher.Terminate()
+	topics := tnsServer.GetTopics()
+	if 1 != len(topics) || topics[0].EndPoint != utils.IP_PORT {
+		t.Errorf("Topic is not taken over: %+v", topics)
+	}
+}
+
+func TestTopicMetadataWithTNSServer(t *testing.T) {
+	tnsServer, httpServer := startTNSServer(t, ezmqx_tns.TNSServerOptions{})
+	defer tnsServer.Close()
+	defer httpServer.Close()
+
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, httpServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+
+	metadata := ezmqx.EZMQXTopicMetadata{Description: "robot", Tags: map[string]string{"line": "1"}, Owner: "app", SchemaVersion: "1.0", Rate: 10}
+	options := ezmqx.EZMQXPublisherOptions{Metadata: metadata}
+	publisher, err := ezmqx.GetAMLPublisherWithOptions(utils.TOPIC, ezmqx.AML_FILE_PATH, utils.AML_FILE_PATH, utils.PORT, options)
+	if nil == publisher {
+		t.Fatalf("Get publisher failed: %v", err)
+	}
+	defer publisher.Terminate()
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+	topic, err := topicDiscovery.QueryV2(utils.TOPIC)
+	if nil != err {
+		t.Errorf("Query failed: %v", err)
+	} else if topic.GetDescription() != "robot" || topic.GetTags()["line"] != "1" || topic.GetOwner() != "app" ||
+		topic.GetSchemaVersion() != "1.0" || topic.GetRate() != 10 {
+		t.Errorf("Metadata mismatch: %+v", topic.GetMetadata())
+	}
+}
+
+func TestQueryByFilterWithTNSServer(t *testing.T) {
+	tnsServer, httpServer := startTNSServer(t, ezmqx_tns.TNSServerOptions{})
+	defer tnsServer.Close()
+	defer httpServer.Close()
+	registerTopic(t, httpServer.URL, "/topic/a", "10.0.0.1:5562")
+	registerTopic(t, httpServer.URL, "/topic/b", "10.0.0.2:5562")
+
+	// Server side filter
+	response, err := http.Get(httpServer.URL + "/api/v1/tns/topic?name=/topic&hierarchical=yes&host=10.0.0.2")
+	if nil != err {
+		t.Fatalf("Query request failed: %v", err)
+	}
+	var payload map[string][]ezmqx_tns.TNSTopic
+	json.NewDecoder(response.Body).Decode(&payload)
+	response.Body.Close()
+	if 1 != len(payload["topics"]) || payload["topics"][0].Name != "/topic/b" {
+		t.Errorf("Server side filter mismatch: %+v", payload)
+	}
+
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, httpServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+	topics, err := topicDiscovery.QueryByFilterV2(ezmqx.EZMQXTopicFilter{Topic: utils.TOPIC, Host: "10.0.0.1"})
+	if nil != err || 1 != topics.Len() {
+		t.Errorf("Query by filter failed: %v", err)
+	}
+	// Every topic is searched without topic
+	topics, err = topicDiscovery.QueryByFilterV2(ezmqx.EZMQXTopicFilter{Host: "10.0.0.2"})
+	if nil != err || 1 != topics.Len() {
+		t.Errorf("Query by filter without topic failed: %v", err)
+	}
+}
+
+func TestQueryPageWithTNSServer(t *testing.T) {
+	tnsServer, httpServer := startTNSServer(t, ezmqx_tns.TNSServerOptions{})
+	defer tnsServer.Close()
+	defer httpServer.Close()
+	names := []string{"/a", "/a/b", "/a/c", "/d/e", "/f"}
+	for i, name := range names {
+		registerTopic(t, httpServer.URL, name, "localhost:"+strconv.Itoa(5562+i))
+	}
+
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, httpServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+
+	page, err := topicDiscovery.HierarchicalQueryPageV2(ezmqx.TOPIC_ROOT, 0, 2)
+	if nil != err {
+		t.Fatalf("Query page failed: %v", err)
+	}
+	if page.GetTotal() != 5 || page.GetTopics().Len() != 2 || !page.HasNext() || page.GetNextOffset() != 2 {
+		t.Errorf("First page mismatch: total %d, count %d", page.GetTotal(), page.GetTopics().Len())
+	}
+	page, err = topicDiscovery.HierarchicalQueryPageV2(ezmqx.TOPIC_ROOT, 4, 2)
+	if nil != err || page.GetTopics().Len() != 1 || page.HasNext() {
+		t.Errorf("Last page mismatch: %v", err)
+	}
+	topics, err := topicDiscovery.HierarchicalQueryV2(ezmqx.TOPIC_ROOT)
+	if nil != err || topics.Len() != 5 {
+		t.Errorf("Root query failed: %v", err)
+	}
+	root, err := topicDiscovery.QueryTreeV2(ezmqx.TOPIC_ROOT)
+	if nil != err {
+		t.Fatalf("Query tree failed: %v", err)
+	}
+	if root.GetTopicCount() != 5 || root.GetChildCount() != 3 || root.GetChild("d").GetTopic() != nil {
+		t.Errorf("Topic tree mismatch")
+	}
+}
+
+func TestQueryTreeWithoutPagingWithTNSServer(t *testing.T) {
+	tnsServer, err := ezmqx_tns.NewTNSServer(ezmqx_tns.TNSServerOptions{})
+	if nil != err {
+		t.Fatalf("Create TNS server failed: %v", err)
+	}
+	defer tnsServer.Close()
+	// TNS server which ignores paging parameters
+	var requests int32
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if http.MethodGet == r.Method {
+			atomic.AddInt32(&requests, 1)
+			query := r.URL.Query()
+			query.Del("offset")
+			query.Del("limit")
+			r.URL.RawQuery = query.Encode()
+		}
+		tnsServer.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+	count := ezmqx.DEFAULT_TREE_PAGE_SIZE + 100
+	for i := 0; i < count; i++ {
+		registerTopic(t, httpServer.URL, "/topic/"+strconv.Itoa(i), "10.0.0.1:5562")
+	}
+
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, httpServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+
+	root, err := topicDiscovery.QueryTreeV2(ezmqx.TOPIC_ROOT)
+	if nil != err {
+		t.Fatalf("Query tree failed: %v", err)
+	}
+	if root.GetTopicCount() != count {
+		t.Errorf("Topic count mismatch: %d", root.GetTopicCount())
+	}
+	if 1 != atomic.LoadInt32(&requests) {
+		t.Errorf("Query request count mismatch: %d", atomic.LoadInt32(&requests))
+	}
+}
+
+func TestDiscoveryCacheWithTNSServer(t *testing.T) {
+	tnsServer, httpServer, available, requests := startUnavailableTNSServer(t)
+	defer tnsServer.Close()
+	defer httpServer.Close()
+	atomic.StoreInt32(available, 1)
+	registerTopic(t, httpServer.URL, "/topic/a", "10.0.0.1:5562")
+
+	configInstance := ezmqx.GetConfigInstance()
+	if err := configInstance.SetDiscoveryCacheOptions(ezmqx.EZMQXDiscoveryCacheOptions{TTL: -1}); nil == err {
+		t.Errorf("Negative TTL is accepted")
+	}
+	options := ezmqx.EZMQXDiscoveryCacheOptions{TTL: 200 * time.Millisecond, NegativeTTL: 20 * time.Millisecond, StaleWhileError: true}
+	if err := configInstance.SetDiscoveryCacheOptions(options); nil != err {
+		t.Fatalf("Set discovery cache options failed: %v", err)
+	}
+	defer configInstance.SetDiscoveryCacheOptions(ezmqx.EZMQXDiscoveryCacheOptions{})
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, httpServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+	query := func(topic string) error {
+		_, err := topicDiscovery.QueryV2(topic)
+		return err
+	}
+	expectRequests := func(expected int32) {
+		if expected != atomic.LoadInt32(requests) {
+			t.Errorf("Request count mismatch: expected %d, got %d", expected, atomic.LoadInt32(requests))
+		}
+	}
+
+	// Positive and negative responses are cached
+	if err := query("/topic/a"); nil != err {
+		t.Errorf("Query failed: %v", err)
+	}
+	query("/topic/a")
+	expectRequests(2)
+	if err := query("/topic/none"); ezmqx.EZMQX_NO_TOPIC_MATCHED != ezmqx.ErrorCodeOf(err) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	query("/topic/none")
+	expectRequests(3)
+	time.Sleep(30 * time.Millisecond)
+	query("/topic/none")
+	expectRequests(4)
+
+	// Explicit invalidation
+	topicDiscovery.InvalidateCache("/topic/a")
+	query("/topic/a")
+	expectRequests(5)
+
+	// Stale response is used while TNS is not reachable
+	time.Sleep(250 * time.Millisecond)
+	atomic.StoreInt32(available, 0)
+	if err := query("/topic/a"); nil != err {
+		t.Errorf("Stale response is not used: %v", err)
+	}
+	expectRequests(6)
+	topicDiscovery.InvalidateCache(ezmqx.TOPIC_ROOT)
+	if err := query("/topic/a"); ezmqx.EZMQX_REST_ERROR != ezmqx.ErrorCodeOf(err) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestDiscoveryCacheNotFoundWithTNSServer(t *testing.T) {
+	tnsServer, err := ezmqx_tns.NewTNSServer(ezmqx_tns.TNSServerOptions{})
+	if nil != err {
+		t.Fatalf("Create TNS server failed: %v", err)
+	}
+	defer tnsServer.Close()
+	var requests int32
+	// TNS server which responds 404 for unknown topic
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if "/topic/none" == r.URL.Query().Get("name") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		tnsServer.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+
+	configInstance := ezmqx.GetConfigInstance()
+	options := ezmqx.EZMQXDiscoveryCacheOptions{TTL: 200 * time.Millisecond, NegativeTTL: 20 * time.Millisecond}
+	if err := configInstance.SetDiscoveryCacheOptions(options); nil != err {
+		t.Fatalf("Set discovery cache options failed: %v", err)
+	}
+	defer configInstance.SetDiscoveryCacheOptions(ezmqx.EZMQXDiscoveryCacheOptions{})
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, httpServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+
+	// Not found response is cached for NegativeTTL
+	for i := 0; i < 2; i++ {
+		if _, err := topicDiscovery.QueryV2("/topic/none"); ezmqx.EZMQX_REST_ERROR != ezmqx.ErrorCodeOf(err) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	if 1 != atomic.LoadInt32(&requests) {
+		t.Errorf("Not found response is not cached: %d", atomic.LoadInt32(&requests))
+	}
+	time.Sleep(30 * time.Millisecond)
+	topicDiscovery.QueryV2("/topic/none")
+	if 2 != atomic.LoadInt32(&requests) {
+		t.Errorf("Expired response is used: %d", atomic.LoadInt32(&requests))
+	}
+}
+
+func TestTNSFailoverWithTNSServer(t *testing.T) {
+	badServer, badHTTPServer, _, badRequests := startUnavailableTNSServer(t)
+	defer badServer.Close()
+	defer badHTTPServer.Close()
+	goodServer, goodHTTPServer, goodAvailable, goodRequests := startUnavailableTNSServer(t)
+	defer goodServer.Close()
+	defer goodHTTPServer.Close()
+	atomic.StoreInt32(goodAvailable, 1)
+	registerTopic(t, goodHTTPServer.URL, utils.TOPIC, "10.0.0.1:5562")
+	atomic.StoreInt32(goodRequests, 0)
+
+	configInstance := ezmqx.GetConfigInstance()
+	if err := configInstance.SetTNSOptions(ezmqx.EZMQXTNSOptions{CoolDown: -1}); nil == err {
+		t.Errorf("Negative cool down is accepted")
+	}
+	if err := configInstance.SetTNSOptions(ezmqx.EZMQXTNSOptions{CoolDown: time.Hour}); nil != err {
+		t.Fatalf("Set TNS options failed: %v", err)
+	}
+	defer configInstance.SetTNSOptions(ezmqx.EZMQXTNSOptions{})
+	if err := configInstance.StartStandAloneModeWithTNS(utils.ADDRESS, []string{badHTTPServer.URL, goodHTTPServer.URL}); nil != err {
+		t.Fatalf("Start standalone mode failed: %v", err)
+	}
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+	for i := 0; i < 2; i++ {
+		if _, err := topicDiscovery.QueryV2(utils.TOPIC); nil != err {
+			t.Errorf("Query failed: %v", err)
+		}
+	}
+	// Failed server is skipped during cool down
+	if 1 != atomic.LoadInt32(badRequests) || 2 != atomic.LoadInt32(goodRequests) {
+		t.Errorf("Failover mismatch: %d, %d", atomic.LoadInt32(badRequests), atomic.LoadInt32(goodRequests))
+	}
+}
+
+func TestTNSRoundRobinWithTNSServer(t *testing.T) {
+	firstServer, firstHTTPServer, firstAvailable, firstRequests := startUnavailableTNSServer(t)
+	defer firstServer.Close()
+	defer firstHTTPServer.Close()
+	secondServer, secondHTTPServer, secondAvailable, secondRequests := startUnavailableTNSServer(t)
+	defer secondServer.Close()
+	defer secondHTTPServer.Close()
+	atomic.StoreInt32(firstAvailable, 1)
+	atomic.StoreInt32(secondAvailable, 1)
+
+	configInstance := ezmqx.GetConfigInstance()
+	if err := configInstance.SetTNSOptions(ezmqx.EZMQXTNSOptions{Selection: ezmqx.TNS_ROUND_ROBIN}); nil != err {
+		t.Fatalf("Set TNS options failed: %v", err)
+	}
+	defer configInstance.SetTNSOptions(ezmqx.EZMQXTNSOptions{})
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, firstHTTPServer.URL+ezmqx.TNS_ADDR_SEPARATOR+secondHTTPServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+	for i := 0; i < 4; i++ {
+		topicDiscovery.HierarchicalQueryV2(utils.TOPIC)
+	}
+	if 2 != atomic.LoadInt32(firstRequests) || 2 != atomic.LoadInt32(secondRequests) {
+		t.Errorf("Round robin mismatch: %d, %d", atomic.LoadInt32(firstRequests), atomic.LoadInt32(secondRequests))
+	}
+}
+
+func TestRestSecurityWithTNSServer(t *testing.T) {
+	tnsServer, err := ezmqx_tns.NewTNSServer(ezmqx_tns.TNSServerOptions{})
+	if nil != err {
+		t.Fatalf("Create TNS server failed: %v", err)
+	}
+	defer tnsServer.Close()
+	httpServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if "Bearer token" != r.Header.Get("Authorization") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		tnsServer.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+	dir, _ := ioutil.TempDir("", "ezmqx")
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	caData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: httpServer.Certificate().Raw})
+	ioutil.WriteFile(caFile, caData, 0600)
+
+	configInstance := ezmqx.GetConfigInstance()
+	if err := configInstance.SetRestSecurity(ezmqx.EZMQXRestSecurity{CAFile: filepath.Join(dir, "none.pem")}); ezmqx.EZMQX_INVALID_PARAM != ezmqx.ErrorCodeOf(err) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, httpServer.URL)
+	defer configInstance.Reset()
+	utils.Factory.SetFactory(ezmqx.RestClientFactory{})
+	defer utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+
+	// Server certificate is not trusted
+	if _, err := topicDiscovery.HierarchicalQueryV2(utils.TOPIC); nil == err {
+		t.Errorf("Query succeeded without CA")
+	}
+	// Authorization header is missing
+	configInstance.SetRestSecurity(ezmqx.EZMQXRestSecurity{CAFile: caFile})
+	if _, err := topicDiscovery.HierarchicalQueryV2(utils.TOPIC); nil == err {
+		t.Errorf("Query succeeded without token")
+	}
+	if err := configInstance.SetRestSecurity(ezmqx.EZMQXRestSecurity{CAFile: caFile, BearerToken: "token"}); nil != err {
+		t.Fatalf("Set REST security failed: %v", err)
+	}
+	defer configInstance.SetRestSecurity(ezmqx.EZMQXRestSecurity{})
+	if _, err := topicDiscovery.HierarchicalQueryV2(utils.TOPIC); nil != err {
+		t.Errorf("Query failed: %v", err)
+	}
+}
+
+func TestMetricsWithTNSServer(t *testing.T) {
+	tnsServer, httpServer, available, _ := startUnavailableTNSServer(t)
+	defer tnsServer.Close()
+	defer httpServer.Close()
+	atomic.StoreInt32(available, 1)
+	registerTopic(t, httpServer.URL, utils.TOPIC, "10.0.0.1:5562")
+
+	ezmqxCtx := ezmqx.NewEZMQXContext(ezmqx.EZMQXContextOptions{RestClientFactory: ezmqx.RestClientFactory{}})
+	if err := ezmqxCtx.GetConfig().StartStandAloneModeWithTNS(utils.ADDRESS, []string{httpServer.URL}); nil != err {
+		t.Fatalf("Start standalone mode failed: %v", err)
+	}
+	defer ezmqxCtx.GetConfig().Reset()
+	topicDiscovery, err := ezmqxCtx.NewTopicDiscovery()
+	if nil != err {
+		t.Fatalf("Create topic discovery failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := topicDiscovery.QueryV2(utils.TOPIC); nil != err {
+			t.Errorf("Query failed: %v", err)
+		}
+	}
+	atomic.StoreInt32(available, 0)
+	if _, err := topicDiscovery.QueryV2(utils.TOPIC); nil == err {
+		t.Errorf("Query of unavailable TNS server succeeded")
+	}
+
+	snapshot := ezmqxCtx.GetMetrics()
+	if 1 != len(snapshot.Requests) {
+		t.Fatalf("Request metrics mismatch: %+v", snapshot.Requests)
+	}
+	request := snapshot.Requests[0]
+	if "GET" != request.Method || "/api/v1/tns/topic" != request.Path || 2 != request.StatusCodes[http.StatusOK] || 1 != request.StatusCodes[http.StatusServiceUnavailable] || 3 != request.Count {
+		t.Errorf("Request metrics mismatch: %+v", request)
+	}
+	if request.Count != request.LatencyBuckets[len(request.LatencyBuckets)-1] {
+		t.Errorf("Latency buckets mismatch: %+v", request.LatencyBuckets)
+	}
+
+	recorder := httptest.NewRecorder()
+	ezmqxCtx.MetricsHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if ezmqx.METRICS_CONTENT_TYPE != recorder.Header().Get("Content-Type") {
+		t.Errorf("Content type mismatch: %s", recorder.Header().Get("Content-Type"))
+	}
+	body := recorder.Body.String()
+	for _, line := range []string{
+		"# TYPE ezmqx_rest_requests_total counter\n",
+		"ezmqx_rest_requests_total{method=\"GET\",path=\"/api/v1/tns/topic\",status=\"200\"} 2\n",
+		"ezmqx_rest_requests_total{method=\"GET\",path=\"/api/v1/tns/topic\",status=\"503\"} 1\n",
+		"ezmqx_rest_request_duration_seconds_count{method=\"GET\",path=\"/api/v1/tns/topic\"} " + strconv.FormatUint(request.Count, 10) + "\n",
+		"ezmqx_keepalive_total{result=\"failure\"} 0\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Metrics output does not contain %q:\n%s", line, body)
+		}
+	}
+}
@@ -18,6 +18,8 @@
 package ezmqx_unittests
 
 import (
+	"context"
+	"errors"
 	"go/ezmqx"
 	"go/ezmqx_unittests/utils"
 	"testing"
@@ -26,11 +28,11 @@ import (
 func TestGetEZMQXTopicDiscovery(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	_, result := ezmqx.GetEZMQXTopicDiscovery()
 	if result != ezmqx.EZMQX_OK {
 		t.Errorf("Error get EZMQX topic discovery failed")
 	}
-	configInstance.Reset()
 }
 
 func TestGetTopicDiscoveryNegative(t *testing.T) {
@@ -43,6 +45,7 @@ func TestGetTopicDiscoveryNegative(t *testing.T) {
 func TestQuery(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	defer configInstance.Reset()
 	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
 
 	//Set fake rest client
@@ -53,12 +56,12 @@ func TestQuery(t *testing.T) {
 	if result != ezmqx.EZMQX_OK {
 		t.Errorf("Error EZMQX topic query failed")
 	}
-	configInstance.Reset()
 }
 
 func TestQueryNegative(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	defer configInstance.Reset()
 	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
 	configInstance.Reset()
 	_, result := topicDiscovery.Query(utils.TOPIC)
@@ -73,12 +76,12 @@ func TestQueryNegative(t *testing.T) {
 	if result != ezmqx.EZMQX_TNS_NOT_AVAILABLE {
 		t.Errorf("Error EZMQX topic query failed")
 	}
-	configInstance.Reset()
 }
 
 func TestQueryNegative2(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	defer configInstance.Reset()
 	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
 
 	//Set fake rest client
@@ -89,12 +92,38 @@ func TestQueryNegative2(t *testing.T) {
 	if result != ezmqx.EZMQX_REST_ERROR {
 		t.Errorf("Error EZMQX topic query failed")
 	}
-	configInstance.Reset()
+}
+
+func TestQueryContextCancelled(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	defer configInstance.Reset()
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+
+	//Set fake rest client
+	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	utils.SetRestResponse(utils.TOPIC_DISCOVERY_URL, []byte(utils.VALID_TOPIC_DISCOVERY_RESPONSE))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := topicDiscovery.QueryContext(ctx, utils.TOPIC)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Error EZMQX topic query was not cancelled: %v", err)
+	}
+	_, err = topicDiscovery.HierarchicalQueryContext(ctx, utils.TOPIC)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Error EZMQX hierarchical query was not cancelled: %v", err)
+	}
+	_, err = topicDiscovery.QueryContext(context.Background(), utils.TOPIC)
+	if err != nil {
+		t.Errorf("Error EZMQX topic query failed: %v", err)
+	}
 }
 
 func TestHierarchicalQuery(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	defer configInstance.Reset()
 	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
 
 	//Set fake rest client
@@ -105,12 +134,12 @@ func TestHierarchicalQuery(t *testing.T) {
 	if result != ezmqx.EZMQX_OK {
 		t.Errorf("Error EZMQX topic query failed")
 	}
-	configInstance.Reset()
 }
 
 func TestQueryTopicValidation(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	defer configInstance.Reset()
 	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
 
 	//Set fake rest client
@@ -267,5 +296,155 @@ func TestQueryTopicValidation(t *testing.T) {
 		t.Errorf("Error EZMQX topic validation failed")
 	}
 
-	configInstance.Reset()
+}
+
+func TestQueryTopicPattern(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	defer configInstance.Reset()
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+
+	//Set fake rest client
+	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	// Literal levels before the first wild card are queried to TNS
+	utils.SetRestResponse(utils.TOPIC_DISCOVERY_H_URL, []byte(utils.PATTERN_TOPIC_DISCOVERY_RESPONSE))
+	utils.SetRestResponse(utils.PATTERN_B_TOPIC_DISCOVERY_H_URL, []byte(utils.PATTERN_B_TOPIC_DISCOVERY_RESPONSE))
+	utils.SetRestResponse(utils.PATTERN_C_TOPIC_DISCOVERY_H_URL, []byte(utils.EMPTY_TOPIC_DISCOVERY_RESPONSE))
+
+	// Child topics of matched topics are included for hierarchical query
+	patterns := map[string]int{
+		"/topic/*/temp":     3,
+		"/topic/b/*":        2,
+		"/topic/#":          4,
+		"/topic/*/temp/max": 1,
+		"/topic/c/*":        0,
+	}
+	for pattern, count := range patterns {
+		topics, err := topicDiscovery.HierarchicalQueryV2(pattern)
+		if nil != err {
+			t.Errorf("Query failed for pattern %s: %v", pattern, err)
+			continue
+		}
+		if topics.Len() != count {
+			t.Errorf("Topic count mismatch for pattern %s: %d", pattern, topics.Len())
+		}
+	}
+	topic, err := topicDiscovery.QueryV2("/topic/b/*")
+	if nil != err || topic.GetName() != "/topic/b/temp" {
+		t.Errorf("Query failed for pattern: %v", err)
+	}
+	_, err = topicDiscovery.QueryV2("/topic/c/*")
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_NO_TOPIC_MATCHED)) {
+		t.Errorf("Query for unmatched pattern: %v", err)
+	}
+
+	// Invalid patterns
+	invalidPatterns := []string{"/*/temp", "/#", "/topic/#/temp", "/topic/a*", "/topic/*/"}
+	for _, pattern := range invalidPatterns {
+		_, err = topicDiscovery.HierarchicalQueryV2(pattern)
+		if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_INVALID_TOPIC)) {
+			t.Errorf("Invalid pattern is accepted: %s", pattern)
+		}
+	}
+}
+
+func TestQueryByFilter(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	defer configInstance.Reset()
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+
+	//Set fake rest client, which ignores filters as old TNS server
+	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	filters := []struct {
+		filter ezmqx.EZMQXTopicFilter
+		query  string
+		count  int
+	}{
+		{ezmqx.EZMQXTopicFilter{Topic: utils.TOPIC}, "", 3},
+		{ezmqx.EZMQXTopicFilter{Topic: utils.TOPIC, DataModel: "GTC_Robot_0.0.1"}, "&datamodel=GTC_Robot_0.0.1", 2},
+		{ezmqx.EZMQXTopicFilter{Topic: utils.TOPIC, Secured: ezmqx.SECURED_ONLY}, "&secured=yes", 2},
+		{ezmqx.EZMQXTopicFilter{Topic: utils.TOPIC, Secured: ezmqx.UNSECURED_ONLY}, "&secured=no", 1},
+		{ezmqx.EZMQXTopicFilter{Topic: utils.TOPIC, Host: "10.0.0.1"}, "&host=10.0.0.1", 2},
+		{ezmqx.EZMQXTopicFilter{Topic: utils.TOPIC, Host: "10.0.0.1:5563"}, "&host=10.0.0.1%3A5563", 1},
+		{ezmqx.EZMQXTopicFilter{Topic: utils.TOPIC, Tags: map[string]string{"line": "3"}}, "&tag=line%3A3", 1},
+		{ezmqx.EZMQXTopicFilter{Topic: utils.TOPIC, DataModel: "GTC_Robot_0.0.1", Secured: ezmqx.SECURED_ONLY}, "&datamodel=GTC_Robot_0.0.1&secured=yes", 1},
+	}
+	for _, test := range filters {
+		utils.SetRestResponse(utils.TOPIC_DISCOVERY_H_URL+test.query, []byte(utils.FILTER_TOPIC_DISCOVERY_RESPONSE))
+		topics, err := topicDiscovery.QueryByFilterV2(test.filter)
+		if nil != err {
+			t.Errorf("Query by filter failed for %+v: %v", test.filter, err)
+			continue
+		}
+		if topics.Len() != test.count {
+			t.Errorf("Topic count mismatch for %+v: %d", test.filter, topics.Len())
+		}
+	}
+
+	// Every topic is searched without topic
+	rootFilters := []struct {
+		filter ezmqx.EZMQXTopicFilter
+		query  string
+		count  int
+	}{
+		{ezmqx.EZMQXTopicFilter{DataModel: "GTC_Robot_0.0.1"}, "&datamodel=GTC_Robot_0.0.1", 2},
+		{ezmqx.EZMQXTopicFilter{Topic: ezmqx.TOPIC_ROOT, Secured: ezmqx.SECURED_ONLY}, "&secured=yes", 2},
+	}
+	for _, test := range rootFilters {
+		utils.SetRestResponse(utils.ROOT_DISCOVERY_H_URL+test.query, []byte(utils.FILTER_TOPIC_DISCOVERY_RESPONSE))
+		topics, err := topicDiscovery.QueryByFilterV2(test.filter)
+		if nil != err {
+			t.Errorf("Query by filter failed for %+v: %v", test.filter, err)
+			continue
+		}
+		if topics.Len() != test.count {
+			t.Errorf("Topic count mismatch for %+v: %d", test.filter, topics.Len())
+		}
+	}
+	_, result := topicDiscovery.QueryByFilter(ezmqx.EZMQXTopicFilter{Topic: "topic/", DataModel: "GTC_Robot_0.0.1"})
+	if result != ezmqx.EZMQX_INVALID_PARAM {
+		t.Errorf("Query by filter with invalid topic: %d", result)
+	}
+}
+
+func TestQueryTree(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.ADDRESS, true, utils.TNS_ADDRESS)
+	defer configInstance.Reset()
+	topicDiscovery, _ := ezmqx.GetEZMQXTopicDiscovery()
+
+	//Set fake rest client, which does not support paging
+	utils.Factory.SetFactory(utils.FakeRestClientFactory{})
+	utils.SetRestResponse(utils.TREE_DISCOVERY_URL, []byte(utils.PATTERN_TOPIC_DISCOVERY_RESPONSE))
+	root, err := topicDiscovery.QueryTreeV2(ezmqx.TOPIC_ROOT)
+	if nil != err {
+		t.Fatalf("Query tree failed: %v", err)
+	}
+	if root.GetTopicCount() != 4 || root.GetChildCount() != 1 || nil != root.GetTopic() {
+		t.Errorf("Root node mismatch: %d topics, %d children", root.GetTopicCount(), root.GetChildCount())
+	}
+	nodeA := root.GetChild("topic").GetChild("a")
+	if nil == nodeA || nodeA.GetName() != "/topic/a" || nodeA.GetTopicCount() != 2 || nodeA.IsLeaf() {
+		t.Fatalf("Intermediate node mismatch")
+	}
+	temp := nodeA.GetChild("temp")
+	if nil == temp.GetTopic() || temp.GetTopic().GetName() != "/topic/a/temp" || temp.GetChild("max").GetLevel() != "max" {
+		t.Errorf("Topic node mismatch")
+	}
+	var names []string
+	root.Walk(func(node *ezmqx.EZMQXTopicNode, depth int) bool {
+		names = append(names, node.GetName())
+		return true
+	})
+	expected := []string{"/", "/topic", "/topic/a", "/topic/a/temp", "/topic/a/temp/max", "/topic/b", "/topic/b/pressure", "/topic/b/temp"}
+	if len(names) != len(expected) {
+		t.Fatalf("Walk mismatch: %v", names)
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Errorf("Walk order mismatch: %v", names)
+			break
+		}
+	}
 }
@@ -79,3 +79,22 @@ func TestGetEndPoint(t *testing.T) {
 		t.Errorf("Error Address mismatch")
 	}
 }
+
+func TestTopicMetadata(t *testing.T) {
+	var endPoint *ezmqx.EZMQXEndpoint = ezmqx.GetEZMQXEndPoint(utils.IP_PORT)
+	tags := map[string]string{"line": "1"}
+	metadata := ezmqx.EZMQXTopicMetadata{Description: "robot", Tags: tags, Owner: "app", SchemaVersion: "1.0", Rate: 10}
+	var instance *ezmqx.EZMQXTopic = ezmqx.GetEZMQXTopicWithMetadata(utils.TOPIC, utils.DATA_MODEL, false, endPoint, metadata)
+	if instance.GetDescription() != "robot" || instance.GetOwner() != "app" || instance.GetSchemaVersion() != "1.0" || instance.GetRate() != 10 {
+		t.Errorf("Metadata mismatch: %+v", instance.GetMetadata())
+	}
+	tags["line"] = "2"
+	instance.GetTags()["line"] = "3"
+	if instance.GetTags()["line"] != "1" {
+		t.Errorf("Tags are not copied")
+	}
+	instance = ezmqx.GetEZMQXTopic(utils.TOPIC, utils.DATA_MODEL, false, endPoint)
+	if 0 != len(instance.GetTags()) || 0 != len(instance.GetDescription()) {
+		t.Errorf("Topic without metadata has metadata")
+	}
+}
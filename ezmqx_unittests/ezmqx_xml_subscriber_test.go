@@ -19,6 +19,7 @@ package ezmqx_unittests
 
 import (
 	"container/list"
+	"errors"
 	"fmt"
 	"go/ezmqx"
 	"go/ezmqx_unittests/utils"
@@ -39,6 +40,7 @@ func xErrorCB(topic string, errorCode ezmqx.EZMQXErrorCode) {
 func TestGetXMLStandAloneSubscriber(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	amlFilePath := list.New()
 	amlFilePath.PushBack(utils.AML_FILE_PATH)
 	idList, _ := configInstance.AddAmlModel(*amlFilePath)
@@ -49,12 +51,12 @@ func TestGetXMLStandAloneSubscriber(t *testing.T) {
 		t.Errorf("subscriber is nil")
 	}
 	subscriber.Terminate()
-	configInstance.Reset()
 }
 
 func TestGetXMLStandAloneSubscriber1(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	amlFilePath := list.New()
 	amlFilePath.PushBack(utils.AML_FILE_PATH)
 	idList, _ := configInstance.AddAmlModel(*amlFilePath)
@@ -64,7 +66,6 @@ func TestGetXMLStandAloneSubscriber1(t *testing.T) {
 	if result != ezmqx.EZMQX_INVALID_TOPIC {
 		t.Errorf("subscriber is nil")
 	}
-	configInstance.Reset()
 }
 
 func TestGetSecuredXMLSubscriber(t *testing.T) {
@@ -161,6 +162,7 @@ func TestGetSecuredXMLSubscriber1(t *testing.T) {
 func TestGetSecuredXMLSubscriber2(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	amlFilePath := list.New()
 	amlFilePath.PushBack(utils.AML_FILE_PATH)
 	idList, _ := configInstance.AddAmlModel(*amlFilePath)
@@ -175,12 +177,12 @@ func TestGetSecuredXMLSubscriber2(t *testing.T) {
 		t.Errorf("subscriber is nil")
 	}
 	subscriber.Terminate()
-	configInstance.Reset()
 }
 
 func TestXMLSubscriberStandAlone(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	amlFilePath := list.New()
 	amlFilePath.PushBack(utils.AML_FILE_PATH)
 	idList, _ := configInstance.AddAmlModel(*amlFilePath)
@@ -202,7 +204,6 @@ func TestXMLSubscriberStandAlone(t *testing.T) {
 		t.Errorf("Received less event")
 	}
 	subscriber.Terminate()
-	configInstance.Reset()
 }
 
 func TestXMLSubscriberStandAlone1(t *testing.T) {
@@ -282,6 +283,7 @@ func TestXMLSubDockerMode1(t *testing.T) {
 func TestXSubTerminate(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	amlFilePath := list.New()
 	amlFilePath.PushBack(utils.AML_FILE_PATH)
 	idList, _ := configInstance.AddAmlModel(*amlFilePath)
@@ -304,12 +306,12 @@ func TestXSubTerminate(t *testing.T) {
 	if result != ezmqx.EZMQX_UNKNOWN_STATE {
 		t.Errorf("Termination failed")
 	}
-	configInstance.Reset()
 }
 
 func TestXGetTopics(t *testing.T) {
 	configInstance := ezmqx.GetConfigInstance()
 	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
 	amlFilePath := list.New()
 	amlFilePath.PushBack(utils.AML_FILE_PATH)
 	idList, _ := configInstance.AddAmlModel(*amlFilePath)
@@ -321,5 +323,87 @@ func TestXGetTopics(t *testing.T) {
 		t.Errorf("GetTopics failed")
 	}
 	subscriber.Terminate()
-	configInstance.Reset()
+}
+
+func TestXMLChannelSubscriberStandAlone(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	idList, _ := configInstance.AddAmlModel(*amlFilePath)
+	endPoint := ezmqx.GetEZMQXEndPoint1(utils.TEST_LOCAL_HOST, utils.PORT)
+	topic := ezmqx.GetEZMQXTopic(utils.TOPIC, idList.Front().Value.(string), false, endPoint)
+	options := ezmqx.EZMQXChannelOptions{BufferSize: 1, OverflowPolicy: ezmqx.OVERFLOW_DROP_NEWEST}
+	subscriber, err := ezmqx.GetXMLStandAloneSubscriberWithOptions(*topic, ezmqx.EZMQXXMLSubscriberOptions{Channel: &options})
+	if nil != err {
+		t.Fatalf("Get channel subscriber failed: %v", err)
+	}
+
+	// Routine to publish data on socket, nobody reads messages so that
+	// all the messages except the first one are dropped.
+	go utils.Publish()
+	<-utils.Exit_Chan
+	time.Sleep(1000 * time.Millisecond)
+
+	message := <-subscriber.Messages()
+	if message.Topic != utils.TOPIC || 0 == len(message.Data) {
+		t.Errorf("Received invalid message")
+	}
+	dropError := <-subscriber.Errors()
+	if ezmqx.ErrorCodeOf(dropError) != ezmqx.EZMQX_MESSAGE_DROPPED {
+		t.Errorf("Drop is not reported: %v", dropError)
+	}
+	// Error channel holds one error, drops of the other messages are counted
+	if count := subscriber.GetDroppedErrorCount(); utils.NUMBER_OF_EVENTS-2 != count {
+		t.Errorf("Dropped error count mismatch: %d", count)
+	}
+	subscriber.Terminate()
+	if _, ok := <-subscriber.Errors(); ok {
+		t.Errorf("Error channel is not closed after terminate")
+	}
+}
+
+func TestXMLSubscribeUnsubscribe(t *testing.T) {
+	configInstance := ezmqx.GetConfigInstance()
+	configInstance.StartStandAloneMode(utils.TEST_LOCAL_HOST, false, "")
+	defer configInstance.Reset()
+	amlFilePath := list.New()
+	amlFilePath.PushBack(utils.AML_FILE_PATH)
+	idList, _ := configInstance.AddAmlModel(*amlFilePath)
+	endPoint := ezmqx.GetEZMQXEndPoint1(utils.ADDRESS, utils.PORT)
+	topic := ezmqx.GetEZMQXTopic(utils.TOPIC, idList.Front().Value.(string), false, endPoint)
+	subscriber, _ := ezmqx.GetXMLStandAloneSubscriber(*topic, xmlSubCB, xErrorCB)
+	if nil == subscriber {
+		t.Fatalf("subscriber is nil")
+	}
+	// Topic on the same end point
+	topic1 := ezmqx.GetEZMQXTopic(utils.TOPIC+"/1", idList.Front().Value.(string), false, endPoint)
+	if err := subscriber.SubscribeTopic(*topic1); nil != err {
+		t.Errorf("SubscribeTopic failed: %v", err)
+	}
+	topics, _ := subscriber.GetTopics()
+	if topics.Len() != 2 {
+		t.Errorf("Topic count mismatch: %d", topics.Len())
+	}
+	if err := subscriber.Unsubscribe(utils.TOPIC); nil != err {
+		t.Errorf("Unsubscribe failed: %v", err)
+	}
+	topics, _ = subscriber.GetTopics()
+	if topics.Len() != 1 {
+		t.Errorf("Topic count mismatch: %d", topics.Len())
+	}
+	subscriber.Terminate()
+
+	// Secured subscriber does not allow adding topics
+	securedTopic := ezmqx.GetEZMQXTopic(utils.TOPIC, idList.Front().Value.(string), true, endPoint)
+	subscriber, _ = ezmqx.GetSecuredXMLSubscriber(*securedTopic, utils.SERVER_PUBLIC_KEY, utils.CLIENT_PUBLIC_KEY, utils.CLIENT_SECRET_KEY, xmlSubCB, xErrorCB)
+	if nil == subscriber {
+		t.Fatalf("subscriber is nil")
+	}
+	err := subscriber.SubscribeTopic(*topic1)
+	if !errors.Is(err, ezmqx.EZMQXErrorCode(ezmqx.EZMQX_INVALID_PARAM)) {
+		t.Errorf("SubscribeTopic on secured subscriber: %v", err)
+	}
+	subscriber.Terminate()
 }
@@ -55,6 +55,14 @@ var RUNNING_APP_INFO_RESPONSE = `{ "description": "services:  system-provisionin
 
 const TOPIC_DISCOVERY_H_URL = "http://192.168.0.1:80/tns-server/api/v1/tns/topic?name=/topic&hierarchical=yes"
 const TOPIC_DISCOVERY_URL = "http://192.168.0.1:80/tns-server/api/v1/tns/topic?name=/topic&hierarchical=no"
+const PATTERN_TOPIC_DISCOVERY_RESPONSE = `{ "topics": [  {"name":  "/topic/a/temp", "datamodel": "GTC_Robot_0.0.1", "endpoint": "localhost:5562", "secured": false }, {"name":  "/topic/b/temp", "datamodel": "GTC_Robot_0.0.1", "endpoint": "localhost:5563", "secured": false }, {"name":  "/topic/b/pressure", "datamodel": "GTC_Robot_0.0.1", "endpoint": "localhost:5563", "secured": false }, {"name":  "/topic/a/temp/max", "datamodel": "GTC_Robot_0.0.1", "endpoint": "localhost:5562", "secured": false } ] }`
+const PATTERN_B_TOPIC_DISCOVERY_H_URL = "http://192.168.0.1:80/tns-server/api/v1/tns/topic?name=/topic/b&hierarchical=yes"
+const PATTERN_B_TOPIC_DISCOVERY_RESPONSE = `{ "topics": [  {"name":  "/topic/b/temp", "datamodel": "GTC_Robot_0.0.1", "endpoint": "localhost:5563", "secured": false }, {"name":  "/topic/b/pressure", "datamodel": "GTC_Robot_0.0.1", "endpoint": "localhost:5563", "secured": false } ] }`
+const PATTERN_C_TOPIC_DISCOVERY_H_URL = "http://192.168.0.1:80/tns-server/api/v1/tns/topic?name=/topic/c&hierarchical=yes"
+const EMPTY_TOPIC_DISCOVERY_RESPONSE = `{ "topics": [] }`
+const FILTER_TOPIC_DISCOVERY_RESPONSE = `{ "topics": [  {"name":  "/topic/a", "datamodel": "GTC_Robot_0.0.1", "endpoint": "10.0.0.1:5562", "secured": false, "tags": {"line": "3"} }, {"name":  "/topic/b", "datamodel": "GTC_Robot_0.0.2", "endpoint": "10.0.0.1:5563", "secured": true, "tags": {"line": "1"} }, {"name":  "/topic/c", "datamodel": "GTC_Robot_0.0.1", "endpoint": "10.0.0.2:5562", "secured": true } ] }`
+const ROOT_DISCOVERY_H_URL = "http://192.168.0.1:80/tns-server/api/v1/tns/topic?name=/&hierarchical=yes"
+const TREE_DISCOVERY_URL = "http://192.168.0.1:80/tns-server/api/v1/tns/topic?name=/&hierarchical=yes&offset=0&limit=500"
 const VALID_TOPIC_DISCOVERY_RESPONSE = `{ "topics": [  {"name":  "topicName", "datamodel": "GTC_Robot_0.0.1", "endpoint": "localhost:5562", "secured": false } ] }`
 const INVALID_TOPIC_DISCOVERY_RESPONSE = `{ "topic": [  {"name":  "topicName", "datamodel": "GTC_Robot_0.0.1", "endpoint": "localhost:5562", "secured": false } ] }`
 
@@ -64,12 +72,16 @@ const VALID_PUB_TNS_RESPONSE = `{ "ka_interval": 200 }`
 const SUB_TOPIC_H_URL = "http://192.168.0.1:80/tns-server/api/v1/tns/topic?name=/topic&hierarchical=yes"
 const SUB_TOPIC_RESPONSE = `{ "topics": [  {"name":  "/topic", "datamodel": "GTC_Robot_0.0.1", "endpoint": "localhost:5562", "secured": false } ] }`
 const SUB_TOPIC_URL = "http://192.168.0.1:80/tns-server/api/v1/tns/topic?name=/topic&hierarchical=no"
-
-const SERVER_SECRET_KEY = "[:X%Q3UfY+kv2A^.wv:(qy2E=bk0L][cm=mS3Hcx";
-const SERVER_PUBLIC_KEY = "tXJx&1^QE2g7WCXbF.$$TVP.wCtxwNhR8?iLi&S<";
-const SERVER_PUBLIC_KEY2 = "xyzx&1^QE2g7WCXbF.$$TVP.wCtxwNhR8?iLiABc";
-const CLIENT_PUBLIC_KEY = "-QW?Ved(f:<::3d5tJ$[4Er&]6#9yr=vha/caBc(";
-const CLIENT_SECRET_KEY = "ZB1@RS6Kv^zucova$kH(!o>tZCQ.<!Q)6-0aWFmW";
+const RECONNECT_TOPIC_RESPONSE = `{ "topics": [  {"name":  "/topic", "datamodel": "GTC_Robot_0.0.1", "endpoint": "localhost:5564", "secured": false } ] }`
+const WATCH_TOPIC = "/topic/child"
+const RECONNECT_WATCH_TOPIC_RESPONSE = `{ "topics": [  {"name":  "/topic", "datamodel": "GTC_Robot_0.0.1", "endpoint": "localhost:5564", "secured": false }, {"name":  "/topic/child", "datamodel": "GTC_Robot_0.0.1", "endpoint": "localhost:5563", "secured": false } ] }`
+const WATCH_TOPIC_RESPONSE = `{ "topics": [  {"name":  "/topic", "datamodel": "GTC_Robot_0.0.1", "endpoint": "localhost:5562", "secured": false }, {"name":  "/topic/child", "datamodel": "GTC_Robot_0.0.1", "endpoint": "localhost:5563", "secured": false } ] }`
+
+const SERVER_SECRET_KEY = "[:X%Q3UfY+kv2A^.wv:(qy2E=bk0L][cm=mS3Hcx"
+const SERVER_PUBLIC_KEY = "tXJx&1^QE2g7WCXbF.$$TVP.wCtxwNhR8?iLi&S<"
+const SERVER_PUBLIC_KEY2 = "xyzx&1^QE2g7WCXbF.$$TVP.wCtxwNhR8?iLiABc"
+const CLIENT_PUBLIC_KEY = "-QW?Ved(f:<::3d5tJ$[4Er&]6#9yr=vha/caBc("
+const CLIENT_SECRET_KEY = "ZB1@RS6Kv^zucova$kH(!o>tZCQ.<!Q)6-0aWFmW"
 
 var Factory = ezmqx.GetRestFactory()
 
@@ -0,0 +1,209 @@
+/*******************************************************************************
+ * Copyright 2018 Samsung Electronics All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ *******************************************************************************/
+
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+const FAKE_ANCHOR_PREFIX = "/pharos-anchor/api/v1"
+const FAKE_CONFIG_PATH = "/api/v1/management/device/configuration"
+const FAKE_SEARCH_NODES_PATH = FAKE_ANCHOR_PREFIX + "/search/nodes"
+const FAKE_APPS_PATH = "/api/v1/management/apps"
+
+// Port mapping of a container.
+type FakePort struct {
+	PrivatePort int
+	PublicPort  int
+}
+
+// Service [container] of a pharos app.
+type FakeService struct {
+	ContainerId string
+	Name        string
+	Ports       []FakePort
+}
+
+// Pharos app.
+type FakeApp struct {
+	Id       string
+	State    string
+	Services []FakeService
+}
+
+type fakeResponse struct {
+	statusCode int
+	body       string
+}
+
+// Emulator of pharos node and anchor REST APIs used by docker mode.
+//
+// Node and anchor are served by the same HTTP server, anchor endpoint in
+// configuration response is AnchorURL().
+type FakePharos struct {
+	server       *httptest.Server
+	mutex        *sync.Mutex
+	nodeAddress  string
+	tnsIP        string
+	reverseProxy bool
+	apps         []FakeApp
+	responses    map[string]fakeResponse
+}
+
+// Start pharos emulator with TNS node on 127.0.0.1 without reverse proxy.
+func NewFakePharos() *FakePharos {
+	instance := &FakePharos{}
+	instance.mutex = &sync.Mutex{}
+	instance.nodeAddress = "127.0.0.1"
+	instance.tnsIP = "127.0.0.1"
+	instance.responses = make(map[string]fakeResponse)
+	instance.server = httptest.NewServer(http.HandlerFunc(instance.serve))
+	return instance
+}
+
+// Get URL of pharos node.
+func (instance *FakePharos) URL() string {
+	return instance.server.URL
+}
+
+// Get URL of pharos anchor.
+func (instance *FakePharos) AnchorURL() string {
+	return instance.server.URL + FAKE_ANCHOR_PREFIX
+}
+
+// Stop pharos emulator.
+func (instance *FakePharos) Close() {
+	instance.server.Close()
+}
+
+// Set node address in configuration response.
+func (instance *FakePharos) SetNodeAddress(address string) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	instance.nodeAddress = address
+}
+
+// Set TNS node in search nodes response of anchor.
+func (instance *FakePharos) SetTNSNode(ip string, reverseProxy bool) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	instance.tnsIP = ip
+	instance.reverseProxy = reverseProxy
+}
+
+// Add app to apps and app detail responses.
+func (instance *FakePharos) AddApp(app FakeApp) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	instance.apps = append(instance.apps, app)
+}
+
+// Script response of the given path, e.g. to return an error status.
+// It overrides the emulated response until cleared with empty body and status 0.
+func (instance *FakePharos) SetResponse(path string, statusCode int, body string) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	if 0 == statusCode {
+		delete(instance.responses, path)
+		return
+	}
+	instance.responses[path] = fakeResponse{statusCode: statusCode, body: body}
+}
+
+func (instance *FakePharos) serve(w http.ResponseWriter, r *http.Request) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	if scripted, exists := instance.responses[r.URL.Path]; exists {
+		w.WriteHeader(scripted.statusCode)
+		w.Write([]byte(scripted.body))
+		return
+	}
+	switch {
+	case r.URL.Path == FAKE_CONFIG_PATH:
+		writeFakeResponse(w, instance.configResponse())
+	case r.URL.Path == FAKE_SEARCH_NODES_PATH && 0 != len(r.URL.Query().Get("imageName")):
+		writeFakeResponse(w, instance.searchNodesResponse())
+	case r.URL.Path == FAKE_APPS_PATH:
+		writeFakeResponse(w, instance.appsResponse())
+	case strings.HasPrefix(r.URL.Path, FAKE_APPS_PATH+"/"):
+		app := instance.findApp(strings.TrimPrefix(r.URL.Path, FAKE_APPS_PATH+"/"))
+		if nil == app {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeFakeResponse(w, appDetailResponse(app))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (instance *FakePharos) configResponse() interface{} {
+	properties := []map[string]interface{}{
+		{"nodeaddress": instance.nodeAddress, "readOnly": true},
+		{"anchorendpoint": instance.AnchorURL(), "readOnly": true},
+	}
+	return map[string]interface{}{"properties": properties}
+}
+
+func (instance *FakePharos) searchNodesResponse() interface{} {
+	properties := []map[string]interface{}{
+		{"reverseproxy": map[string]interface{}{"enabled": instance.reverseProxy}, "readOnly": true},
+	}
+	node := map[string]interface{}{"id": "fake_node", "ip": instance.tnsIP, "status": "connected",
+		"config": map[string]interface{}{"properties": properties}}
+	return map[string]interface{}{"nodes": []interface{}{node}}
+}
+
+func (instance *FakePharos) appsResponse() interface{} {
+	apps := make([]map[string]interface{}, 0, len(instance.apps))
+	for _, app := range instance.apps {
+		apps = append(apps, map[string]interface{}{"id": app.Id, "state": app.State})
+	}
+	return map[string]interface{}{"apps": apps}
+}
+
+func (instance *FakePharos) findApp(id string) *FakeApp {
+	for i := range instance.apps {
+		if instance.apps[i].Id == id {
+			return &instance.apps[i]
+		}
+	}
+	return nil
+}
+
+func appDetailResponse(app *FakeApp) interface{} {
+	services := make([]map[string]interface{}, 0, len(app.Services))
+	for _, service := range app.Services {
+		ports := make([]map[string]interface{}, 0, len(service.Ports))
+		for _, port := range service.Ports {
+			ports = append(ports, map[string]interface{}{"IP": "0.0.0.0", "PrivatePort": port.PrivatePort, "PublicPort": port.PublicPort, "Type": "tcp"})
+		}
+		services = append(services, map[string]interface{}{"cid": service.ContainerId, "name": service.Name, "ports": ports,
+			"state": map[string]interface{}{"exitcode": "0", "status": "running"}})
+	}
+	return map[string]interface{}{"services": services, "state": app.State}
+}
+
+func writeFakeResponse(w http.ResponseWriter, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payload)
+}
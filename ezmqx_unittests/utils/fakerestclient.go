@@ -19,6 +19,7 @@ package utils
 
 import (
 	"go/ezmqx"
+	"sync"
 	"time"
 )
 
@@ -26,12 +27,17 @@ type FakeRestClient struct {
 }
 
 var restResponse = make(map[string][]byte)
+var restResponseMutex = &sync.RWMutex{}
 
 func GetRestResponse(url string) []byte {
+	restResponseMutex.RLock()
+	defer restResponseMutex.RUnlock()
 	return restResponse[url]
 }
 
 func SetRestResponse(url string, payload []byte) {
+	restResponseMutex.Lock()
+	defer restResponseMutex.Unlock()
 	restResponse[url] = payload
 }
 
@@ -42,17 +48,17 @@ func GetFakeClient(timeout time.Duration) *FakeRestClient {
 }
 
 func (instance *FakeRestClient) Get(url string) (*ezmqx.RestResponse, ezmqx.EZMQXErrorCode) {
-	return ezmqx.GetRestResponse(200, restResponse[url]), ezmqx.EZMQX_OK
+	return ezmqx.GetRestResponse(200, GetRestResponse(url)), ezmqx.EZMQX_OK
 }
 
 func (instance *FakeRestClient) Put(url string, data []byte) (*ezmqx.RestResponse, ezmqx.EZMQXErrorCode) {
-	return ezmqx.GetRestResponse(200, restResponse[url]), ezmqx.EZMQX_OK
+	return ezmqx.GetRestResponse(200, GetRestResponse(url)), ezmqx.EZMQX_OK
 }
 
 func (instance *FakeRestClient) Post(url string, data []byte) (*ezmqx.RestResponse, ezmqx.EZMQXErrorCode) {
-	return ezmqx.GetRestResponse(201, restResponse[url]), ezmqx.EZMQX_OK
+	return ezmqx.GetRestResponse(201, GetRestResponse(url)), ezmqx.EZMQX_OK
 }
 
 func (instance *FakeRestClient) Delete(url string, data []byte) (*ezmqx.RestResponse, ezmqx.EZMQXErrorCode) {
-	return ezmqx.GetRestResponse(200, restResponse[url]), ezmqx.EZMQX_OK
+	return ezmqx.GetRestResponse(200, GetRestResponse(url)), ezmqx.EZMQX_OK
 }